@@ -0,0 +1,277 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+)
+
+// argsPlaceholder is the literal string in [Command.Help] which is replaced with
+// the usage of the declared positional arguments.
+const argsPlaceholder = "{{ ARGS }}"
+
+type ArgVar[T any] struct {
+	// Name is the name of the argument in help output. By convention, it is all
+	// capital letters (e.g. "FILE").
+	Name  string
+	Usage string
+
+	// Optional marks the argument as optional. Optional arguments must come
+	// after all required arguments.
+	Optional bool
+
+	// Variadic accepts any number of values for the argument, calling the parser
+	// and setter for each. Only the last argument can be variadic. A variadic
+	// argument which is not optional requires at least one value.
+	Variadic bool
+
+	// Parser converts the string value to the target value. Setter sets the
+	// target to the parsed value, defaulting to assignment. Use a setter which
+	// appends for variadic arguments.
+	Parser ParserFunc[T]
+	Setter SetterFunc[T]
+
+	// Predict is the completion predictor. If no predictor is defined, it
+	// defaults to predicting something.
+	Predict complete.Predictor
+
+	Target *T
+}
+
+// argSpec is the type-erased declaration of a positional argument.
+type argSpec struct {
+	name      string
+	usage     string
+	optional  bool
+	variadic  bool
+	predictor complete.Predictor
+	set       func(s string) error
+}
+
+// Arg declares a positional argument on the flag set. Arguments are assigned in
+// the order in which they are declared. Once any arguments are declared,
+// [FlagSet.Parse] validates the number of arguments, and parses each into its
+// target.
+//
+// It panics if the target or parser are nil, if a required argument follows an
+// optional one, or if an argument follows a variadic one.
+func Arg[T any](f *FlagSet, i *ArgVar[T]) {
+	if i.Target == nil {
+		panic("missing target")
+	}
+
+	parser := i.Parser
+	if parser == nil {
+		panic("missing parser func")
+	}
+
+	setter := i.Setter
+	if setter == nil {
+		setter = func(cur *T, val T) { *cur = val }
+	}
+
+	if n := len(f.argSpecs); n > 0 {
+		last := f.argSpecs[n-1]
+		if last.variadic {
+			panic(fmt.Sprintf("argument %s follows variadic argument %s", i.Name, last.name))
+		}
+		if last.optional && !i.Optional {
+			panic(fmt.Sprintf("required argument %s follows optional argument %s", i.Name, last.name))
+		}
+	}
+
+	predictor := i.Predict
+	if predictor == nil {
+		predictor = predict.Something
+	}
+
+	f.argSpecs = append(f.argSpecs, &argSpec{
+		name:      i.Name,
+		usage:     i.Usage,
+		optional:  i.Optional,
+		variadic:  i.Variadic,
+		predictor: predictor,
+		set: func(s string) error {
+			v, err := parser(s)
+			if err != nil {
+				return err
+			}
+			setter(i.Target, v)
+			return nil
+		},
+	})
+}
+
+type StringArgVar struct {
+	Name     string
+	Usage    string
+	Optional bool
+	Predict  complete.Predictor
+	Target   *string
+}
+
+func (f *FlagSet) StringArg(i *StringArgVar) {
+	Arg(f, &ArgVar[string]{
+		Name:     i.Name,
+		Usage:    i.Usage,
+		Optional: i.Optional,
+		Predict:  i.Predict,
+		Target:   i.Target,
+		Parser:   func(s string) (string, error) { return s, nil },
+	})
+}
+
+type StringSliceArgVar struct {
+	Name     string
+	Usage    string
+	Optional bool
+	Predict  complete.Predictor
+	Target   *[]string
+}
+
+func (f *FlagSet) StringSliceArg(i *StringSliceArgVar) {
+	Arg(f, &ArgVar[[]string]{
+		Name:     i.Name,
+		Usage:    i.Usage,
+		Optional: i.Optional,
+		Variadic: true,
+		Predict:  i.Predict,
+		Target:   i.Target,
+		Parser:   func(s string) ([]string, error) { return []string{s}, nil },
+		Setter:   func(cur *[]string, val []string) { *cur = append(*cur, val...) },
+	})
+}
+
+// parseArgs validates the number of positional arguments and sets the target of
+// each declared argument. It does nothing if no arguments are declared.
+func (f *FlagSet) parseArgs() error {
+	if len(f.argSpecs) == 0 {
+		return nil
+	}
+
+	var merr error
+	args := f.args
+	for _, spec := range f.argSpecs {
+		if len(args) == 0 {
+			if !spec.optional {
+				merr = errors.Join(merr, fmt.Errorf("missing required argument %s", spec.name))
+			}
+			continue
+		}
+
+		values := args[:1]
+		if spec.variadic {
+			values = args
+		}
+		args = args[len(values):]
+
+		for _, v := range values {
+			if err := spec.set(v); err != nil {
+				merr = errors.Join(merr, fmt.Errorf("invalid value %q for argument %s: %w", v, spec.name, err))
+			}
+		}
+	}
+
+	if len(args) > 0 {
+		merr = errors.Join(merr, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " ")))
+	}
+	return merr
+}
+
+// ArgsUsage returns the usage of the declared positional arguments for the
+// usage line (e.g. "SOURCE [DEST...]"). Optional arguments are wrapped in
+// brackets and variadic arguments are followed by an ellipsis.
+func (f *FlagSet) ArgsUsage() string {
+	parts := make([]string, 0, len(f.argSpecs))
+	for _, spec := range f.argSpecs {
+		parts = append(parts, spec.label())
+	}
+	return strings.Join(parts, " ")
+}
+
+// argsHelp returns the help section for the declared positional arguments, or
+// the empty string if there are none.
+func (f *FlagSet) argsHelp() string {
+	if len(f.argSpecs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprint(&b, f.style.Bold("ARGUMENTS"))
+	fmt.Fprint(&b, "\n\n")
+	for _, spec := range f.argSpecs {
+		fmt.Fprintf(&b, "    %s\n", f.style.Highlight(spec.label()))
+		if spec.usage != "" {
+			fmt.Fprint(&b, wrapAtLengthWithPadding(spec.usage, f.lineLength(), 8))
+			fmt.Fprint(&b, "\n")
+		}
+		fmt.Fprint(&b, "\n")
+	}
+	return b.String()
+}
+
+// argsPredictor returns the completion predictor for the declared positional
+// arguments, or nil if there are none. Predictors do not know the position of
+// the argument being completed, so multiple arguments predict the union of
+// their predictions. It is safe to call on a nil flag set.
+func (f *FlagSet) argsPredictor() complete.Predictor {
+	if f == nil {
+		return nil
+	}
+
+	switch len(f.argSpecs) {
+	case 0:
+		return nil
+	case 1:
+		return f.argSpecs[0].predictor
+	}
+
+	predictors := make([]complete.Predictor, 0, len(f.argSpecs))
+	for _, spec := range f.argSpecs {
+		predictors = append(predictors, spec.predictor)
+	}
+	return predict.Or(predictors...)
+}
+
+// label returns the name of the argument as shown in the usage line.
+func (a *argSpec) label() string {
+	label := a.name
+	if a.variadic {
+		label += "..."
+	}
+	if a.optional {
+		label = "[" + label + "]"
+	}
+	return label
+}
+
+// expandHelp replaces the "{{ COMMAND }}" and "{{ ARGS }}" placeholders in the
+// help text with the command name and the usage of the positional arguments.
+func expandHelp(help, name string, flags *FlagSet) string {
+	var args string
+	if flags != nil {
+		args = flags.ArgsUsage()
+	}
+	if args == "" {
+		help = strings.ReplaceAll(help, " "+argsPlaceholder, "")
+	}
+	help = strings.ReplaceAll(help, argsPlaceholder, args)
+	return strings.ReplaceAll(help, "{{ COMMAND }}", name)
+}
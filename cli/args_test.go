@@ -0,0 +1,300 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/posener/complete/v2/predict"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+type copyArgs struct {
+	mode   string
+	source string
+	dests  []string
+	count  int
+}
+
+func TestArg_Parse(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		args      []string
+		want      *copyArgs
+		wantError string
+	}{
+		{
+			name: "required_only",
+			args: []string{"fast", "a.txt"},
+			want: &copyArgs{mode: "fast", source: "a.txt"},
+		},
+		{
+			name: "optional",
+			args: []string{"fast", "a.txt", "3"},
+			want: &copyArgs{mode: "fast", source: "a.txt", count: 3},
+		},
+		{
+			name: "variadic",
+			args: []string{"fast", "a.txt", "3", "b.txt", "c.txt"},
+			want: &copyArgs{mode: "fast", source: "a.txt", count: 3, dests: []string{"b.txt", "c.txt"}},
+		},
+		{
+			name:      "missing",
+			args:      []string{"fast"},
+			want:      &copyArgs{mode: "fast"},
+			wantError: "missing required argument SOURCE",
+		},
+		{
+			name:      "missing_all",
+			want:      &copyArgs{},
+			wantError: "missing required argument MODE\nmissing required argument SOURCE",
+		},
+		{
+			name:      "invalid",
+			args:      []string{"fast", "a.txt", "many"},
+			want:      &copyArgs{mode: "fast", source: "a.txt"},
+			wantError: `invalid value "many" for argument COUNT`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got copyArgs
+			set := NewFlagSet()
+			set.StringArg(&StringArgVar{
+				Name:   "MODE",
+				Target: &got.mode,
+			})
+			set.StringArg(&StringArgVar{
+				Name:   "SOURCE",
+				Target: &got.source,
+			})
+			Arg(set, &ArgVar[int]{
+				Name:     "COUNT",
+				Optional: true,
+				Parser:   strconv.Atoi,
+				Target:   &got.count,
+			})
+			set.StringSliceArg(&StringSliceArgVar{
+				Name:     "DEST",
+				Optional: true,
+				Target:   &got.dests,
+			})
+
+			err := set.Parse(tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantError); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tc.want, &got, cmp.AllowUnexported(copyArgs{})); diff != "" {
+				t.Errorf("args (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestArg_Parse_unexpected(t *testing.T) {
+	t.Parallel()
+
+	var name string
+	set := NewFlagSet()
+	set.StringArg(&StringArgVar{
+		Name:   "NAME",
+		Target: &name,
+	})
+
+	err := set.Parse([]string{"a", "b", "c"})
+	if diff := testutil.DiffErrString(err, "unexpected arguments: b c"); diff != "" {
+		t.Error(diff)
+	}
+
+	// Commands which do not declare arguments are not validated.
+	if err := NewFlagSet().Parse([]string{"a", "b", "c"}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestArg_panics(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		fn   func(set *FlagSet)
+		want string
+	}{
+		{
+			name: "after_variadic",
+			fn: func(set *FlagSet) {
+				set.StringSliceArg(&StringSliceArgVar{Name: "A", Target: new([]string)})
+				set.StringArg(&StringArgVar{Name: "B", Target: new(string)})
+			},
+			want: "argument B follows variadic argument A",
+		},
+		{
+			name: "required_after_optional",
+			fn: func(set *FlagSet) {
+				set.StringArg(&StringArgVar{Name: "A", Optional: true, Target: new(string)})
+				set.StringArg(&StringArgVar{Name: "B", Target: new(string)})
+			},
+			want: "required argument B follows optional argument A",
+		},
+		{
+			name: "missing_target",
+			fn: func(set *FlagSet) {
+				set.StringArg(&StringArgVar{Name: "A"})
+			},
+			want: "missing target",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				if got := fmt.Sprint(recover()); got != tc.want {
+					t.Errorf("expected panic %q to be %q", got, tc.want)
+				}
+			}()
+			tc.fn(NewFlagSet())
+		})
+	}
+}
+
+type argsCommand struct {
+	BaseCommand
+
+	source string
+}
+
+func (c *argsCommand) Desc() string { return "Copy a file" }
+
+func (c *argsCommand) Help() string { return "Usage: {{ COMMAND }} [options] {{ ARGS }}" }
+
+func (c *argsCommand) Flags() *FlagSet {
+	set := c.NewFlagSet()
+	set.StringArg(&StringArgVar{
+		Name:    "SOURCE",
+		Usage:   "The file to copy.",
+		Predict: predict.Files("*"),
+		Target:  &c.source,
+	})
+	return set
+}
+
+func (c *argsCommand) Run(ctx context.Context, args []string) error {
+	return c.Flags().Parse(args) //nolint:wrapcheck // Want passthrough
+}
+
+func TestArg_help(t *testing.T) {
+	t.Parallel()
+
+	set := NewFlagSet()
+	set.StringArg(&StringArgVar{
+		Name:   "MODE",
+		Usage:  "The copy mode.",
+		Target: new(string),
+	})
+	set.StringArg(&StringArgVar{
+		Name:   "SOURCE",
+		Usage:  "The file to copy.",
+		Target: new(string),
+	})
+	Arg(set, &ArgVar[int]{
+		Name:     "COUNT",
+		Usage:    "The number of copies.",
+		Optional: true,
+		Parser:   strconv.Atoi,
+		Target:   new(int),
+	})
+	set.StringSliceArg(&StringSliceArgVar{
+		Name:     "DEST",
+		Usage:    "The destinations.",
+		Optional: true,
+		Target:   new([]string),
+	})
+	if got, want := set.ArgsUsage(), "MODE SOURCE [COUNT] [DEST...]"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	help := set.Help()
+	for _, want := range []string{
+		"ARGUMENTS\n\n    MODE\n        The copy mode.\n\n    SOURCE\n",
+		"    [DEST...]\n        The destinations.",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected\n\n%s\n\nto include %q", help, want)
+		}
+	}
+
+	cmd := &argsCommand{}
+	if got, want := formatHelp(cmd.Help(), "cp", cmd.Flags()), "Usage: cp [options] SOURCE\n\nARGUMENTS"; !strings.HasPrefix(got, want) {
+		t.Errorf("expected %q to start with %q", got, want)
+	}
+	if got, want := expandHelp("Usage: {{ COMMAND }} {{ ARGS }}", "cp", NewFlagSet()), "Usage: cp"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	rootCmd := &RootCommand{
+		Name: "tool",
+		Commands: map[string]CommandFactory{
+			"cp": func() Command { return &argsCommand{} },
+		},
+	}
+	_, _, stderr := rootCmd.Pipe()
+
+	err := rootCmd.Run(t.Context(), []string{"cp"})
+	if diff := testutil.DiffErrString(err, "missing required argument SOURCE"); diff != "" {
+		t.Error(diff)
+	}
+	if got, want := runMain(t.Context(), rootCmd, []string{"cp"}), ExitCodeUsage; got != want {
+		t.Errorf("expected exit code %d to be %d", got, want)
+	}
+	if got, want := stderr.String(), "Usage: tool cp [options] SOURCE\n"; !strings.Contains(got, want) {
+		t.Errorf("expected %q to contain %q", got, want)
+	}
+}
+
+func TestArg_completion(t *testing.T) {
+	t.Parallel()
+
+	set := NewFlagSet()
+	set.StringArg(&StringArgVar{
+		Name:    "MODE",
+		Predict: predict.Set{"fast", "safe"},
+		Target:  new(string),
+	})
+	got := set.argsPredictor().Predict("")
+	for _, want := range []string{"fast", "safe"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("expected predictions %q to include %q", got, want)
+		}
+	}
+
+	node := buildCompletionTree(&argsCommand{}, "cp")
+	if got, want := node.args.kind, predictFiles; got != want {
+		t.Errorf("expected prediction kind %v to be %v", got, want)
+	}
+}
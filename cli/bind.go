@@ -0,0 +1,445 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/abcxyz/pkg/timeutil"
+)
+
+// BindStruct registers a flag for each tagged field of the struct pointed to by
+// ptr. Fields are bound with the following struct tags:
+//
+//   - flag: the name of the flag (required). Fields without this tag are
+//     skipped, and "-" explicitly skips the field.
+//   - alias: comma-separated aliases for the flag.
+//   - usage: the usage text for the flag.
+//   - example: the example value for the flag.
+//   - default: the default value, using the same syntax as the command line.
+//   - env: the environment variable from which to read the value.
+//   - hidden: if "true", the flag is hidden from help output.
+//   - allowfromfile: if "true", the value can be read from a file (see
+//     [Var.AllowFromFile]).
+//   - allowfromprompt: if "true", the value can be read from a prompt (see
+//     [Var.AllowFromPrompt]).
+//   - sensitive: if "true", the value is a secret which is redacted in output
+//     (see [Var.Sensitive]).
+//   - values: comma-separated valid values for string and []string fields,
+//     which are bound like [FlagSection.EnumVar].
+//   - type: "file" or "dir" for string and []string fields, which are bound
+//     like [FlagSection.FileVar] and [FlagSection.DirVar], or "bytesize" for
+//     uint64 and []uint64 fields, which are bound like
+//     [FlagSection.ByteSizeVar].
+//   - layout: the layout for [time.Time] fields, which defaults to
+//     [time.RFC3339].
+//
+// Supported field types are bool, float64, int, int64, string, uint, uint64,
+// []string, []uint64, map[string]string, [time.Duration], [time.Time],
+// [url.URL], []url.URL, [netip.Addr], []netip.Addr, [netip.Prefix],
+// []netip.Prefix, *[regexp.Regexp], []*regexp.Regexp, and [slog.Level].
+//
+// Untagged struct fields which contain tagged fields are bound recursively into
+// a new section of the flag set, named by the "section" tag or the upper-cased
+// field name. Embedded structs without a "section" tag are bound into this
+// section. Other struct fields, such as [time.Time], are skipped.
+//
+//	type Config struct {
+//		Project string `flag:"project" alias:"p" env:"PROJECT" usage:"The project."`
+//		Workers int    `flag:"workers" default:"4" usage:"The number of workers."`
+//
+//		Server struct {
+//			Addr netip.Addr `flag:"addr" default:"127.0.0.1" usage:"The address."`
+//		} `section:"SERVER OPTIONS"`
+//	}
+//
+//	f.BindStruct(&cfg)
+//
+// BindStruct panics if ptr is not a pointer to a struct or if a tagged field
+// has an unsupported type or an invalid default value.
+func (f *FlagSection) BindStruct(ptr any) {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("BindStruct requires a non-nil pointer to a struct, got %T", ptr))
+	}
+
+	f.bindStruct(rv.Elem())
+}
+
+// bindStruct binds the fields of the given addressable struct value.
+func (f *FlagSection) bindStruct(rv reflect.Value) {
+	typ := rv.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fv := rv.Field(i)
+
+		name, tagged := field.Tag.Lookup("flag")
+		if name == "-" {
+			continue
+		}
+
+		if !tagged {
+			// Exported fields of embedded structs are accessible even when the
+			// embedded type itself is unexported.
+			if field.Type.Kind() != reflect.Struct || (!field.IsExported() && !field.Anonymous) {
+				continue
+			}
+
+			// Structs without flags, such as [time.Time], are not bound, so they
+			// do not create empty sections.
+			if !hasFlagFields(field.Type) {
+				continue
+			}
+
+			section, ok := field.Tag.Lookup("section")
+			switch {
+			case ok:
+				f.set.NewSection(section).bindStruct(fv)
+			case field.Anonymous:
+				f.bindStruct(fv)
+			default:
+				f.set.NewSection(strings.ToUpper(field.Name)).bindStruct(fv)
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			panic(fmt.Sprintf("cannot bind unexported field %s to flag -%s", field.Name, name))
+		}
+
+		f.bindField(newFieldSpec(name, field.Tag), fv.Addr().Interface())
+	}
+}
+
+// hasFlagFields returns true if the struct type has any fields which are bound
+// to flags, including fields of nested structs.
+func hasFlagFields(typ reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		name, tagged := field.Tag.Lookup("flag")
+		switch {
+		case name == "-":
+		case tagged:
+			return true
+		case field.Type.Kind() == reflect.Struct && (field.IsExported() || field.Anonymous):
+			if hasFlagFields(field.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldSpec is the flag configuration parsed from a struct field's tags.
+type fieldSpec struct {
+	name            string
+	aliases         []string
+	usage           string
+	example         string
+	defaultValue    string
+	envVar          string
+	hidden          bool
+	allowFromFile   bool
+	allowFromPrompt bool
+	sensitive       bool
+	values          []string
+	kind            string
+	layout          string
+}
+
+func newFieldSpec(name string, tag reflect.StructTag) *fieldSpec {
+	s := &fieldSpec{
+		name:            name,
+		usage:           tag.Get("usage"),
+		example:         tag.Get("example"),
+		defaultValue:    tag.Get("default"),
+		envVar:          tag.Get("env"),
+		hidden:          tag.Get("hidden") == "true",
+		allowFromFile:   tag.Get("allowfromfile") == "true",
+		allowFromPrompt: tag.Get("allowfromprompt") == "true",
+		sensitive:       tag.Get("sensitive") == "true",
+		kind:            tag.Get("type"),
+		layout:          tag.Get("layout"),
+	}
+	if v := tag.Get("alias"); v != "" {
+		s.aliases = splitSliceValue(v)
+	}
+	if v := tag.Get("values"); v != "" {
+		s.values = splitSliceValue(v)
+	}
+	if s.layout == "" {
+		s.layout = time.RFC3339
+	}
+	return s
+}
+
+// bindField registers a flag for the given pointer to a struct field.
+func (f *FlagSection) bindField(s *fieldSpec, target any) {
+	identity := func(v string) string { return v }
+
+	switch t := target.(type) {
+	case *bool:
+		bindFlag(f, s, &Var[bool]{
+			Target:  t,
+			IsBool:  true,
+			Parser:  strconv.ParseBool,
+			Printer: strconv.FormatBool,
+		})
+	case *time.Duration:
+		bindFlag(f, s, &Var[time.Duration]{
+			Target:  t,
+			Parser:  time.ParseDuration,
+			Printer: timeutil.HumanDuration,
+		})
+	case *float64:
+		bindFlag(f, s, &Var[float64]{
+			Target:  t,
+			Parser:  func(v string) (float64, error) { return strconv.ParseFloat(v, 64) },
+			Printer: func(v float64) string { return strconv.FormatFloat(v, 'e', -1, 64) },
+		})
+	case *int:
+		bindFlag(f, s, &Var[int]{
+			Target: t,
+			Parser: func(v string) (int, error) {
+				i, err := strconv.ParseInt(v, 10, strconv.IntSize)
+				return int(i), err
+			},
+			Printer: strconv.Itoa,
+		})
+	case *int64:
+		bindFlag(f, s, &Var[int64]{
+			Target:  t,
+			Parser:  func(v string) (int64, error) { return strconv.ParseInt(v, 10, 64) },
+			Printer: func(v int64) string { return strconv.FormatInt(v, 10) },
+		})
+	case *uint:
+		bindFlag(f, s, &Var[uint]{
+			Target: t,
+			Parser: func(v string) (uint, error) {
+				i, err := strconv.ParseUint(v, 10, strconv.IntSize)
+				return uint(i), err
+			},
+			Printer: func(v uint) string { return strconv.FormatUint(uint64(v), 10) },
+		})
+	case *uint64:
+		if s.kind == "bytesize" {
+			bindFlag(f, s, &Var[uint64]{
+				Target:  t,
+				Parser:  parseByteSize,
+				Printer: formatByteSize,
+			})
+			return
+		}
+		bindFlag(f, s, &Var[uint64]{
+			Target:  t,
+			Parser:  func(v string) (uint64, error) { return strconv.ParseUint(v, 10, 64) },
+			Printer: func(v uint64) string { return strconv.FormatUint(v, 10) },
+		})
+	case *[]uint64:
+		if s.kind != "bytesize" {
+			panic(fmt.Sprintf(`flag -%s: []uint64 fields require the tag type:"bytesize"`, s.name))
+		}
+		bindFlag(f, s, &Var[[]uint64]{
+			Target:  t,
+			Parser:  sliceParser(parseByteSize, true),
+			Printer: slicePrinter(formatByteSize),
+			Setter:  sliceSetter[uint64](),
+		})
+	case *string:
+		parser, predictor := s.stringParser(f.workingDir)
+
+		// Like [FileVar] and [DirVar], path defaults are not checked or resolved.
+		var def string
+		if s.isPath() {
+			def, s.defaultValue = s.defaultValue, ""
+		}
+
+		bindFlag(f, s, &Var[string]{
+			Target:  t,
+			Default: def,
+			Predict: predictor,
+			Parser:  parser,
+			Printer: identity,
+		})
+	case *[]string:
+		parser, predictor := s.stringParser(f.workingDir)
+
+		var def []string
+		if s.isPath() {
+			def, s.defaultValue = splitSliceValue(s.defaultValue), ""
+		}
+
+		bindFlag(f, s, &Var[[]string]{
+			Target:  t,
+			Default: def,
+			Predict: predictor,
+			Parser:  sliceParser(parser, true),
+			Printer: slicePrinter(identity),
+			Setter:  sliceSetter[string](),
+		})
+	case *map[string]string:
+		// The command line accepts a single pair per flag, but the default may
+		// contain multiple comma-separated pairs.
+		var def map[string]string
+		if s.defaultValue != "" {
+			m, err := parseStringMap(s.defaultValue)
+			if err != nil {
+				panic(fmt.Sprintf("invalid default value %q for flag -%s: %s", s.defaultValue, s.name, err))
+			}
+			def = m
+		}
+		s.defaultValue = ""
+
+		bindFlag(f, s, &Var[map[string]string]{
+			Target:     t,
+			Default:    def,
+			Parser:     parseStringMapEntry,
+			Printer:    printStringMap,
+			Setter:     stringMapSetter(),
+			listParser: parseStringMap,
+		})
+	case *time.Time:
+		layout := s.layout
+		bindFlag(f, s, &Var[time.Time]{
+			Target:  t,
+			Parser:  func(v string) (time.Time, error) { return time.Parse(layout, v) },
+			Printer: func(v time.Time) string { return v.Format(layout) },
+		})
+	case *url.URL:
+		bindFlag(f, s, &Var[url.URL]{
+			Target:  t,
+			Parser:  parseURL,
+			Printer: printURL,
+		})
+	case *[]url.URL:
+		bindFlag(f, s, &Var[[]url.URL]{
+			Target:  t,
+			Parser:  sliceParser(parseURL, true),
+			Printer: slicePrinter(printURL),
+			Setter:  sliceSetter[url.URL](),
+		})
+	case *netip.Addr:
+		bindFlag(f, s, &Var[netip.Addr]{
+			Target:  t,
+			Parser:  parseIP,
+			Printer: printIP,
+		})
+	case *[]netip.Addr:
+		bindFlag(f, s, &Var[[]netip.Addr]{
+			Target:  t,
+			Parser:  sliceParser(parseIP, true),
+			Printer: slicePrinter(printIP),
+			Setter:  sliceSetter[netip.Addr](),
+		})
+	case *netip.Prefix:
+		bindFlag(f, s, &Var[netip.Prefix]{
+			Target:  t,
+			Parser:  parseCIDR,
+			Printer: printCIDR,
+		})
+	case *[]netip.Prefix:
+		bindFlag(f, s, &Var[[]netip.Prefix]{
+			Target:  t,
+			Parser:  sliceParser(parseCIDR, true),
+			Printer: slicePrinter(printCIDR),
+			Setter:  sliceSetter[netip.Prefix](),
+		})
+	case **regexp.Regexp:
+		bindFlag(f, s, &Var[*regexp.Regexp]{
+			Target:  t,
+			Parser:  parseRegexp,
+			Printer: printRegexp,
+		})
+	case *[]*regexp.Regexp:
+		bindFlag(f, s, &Var[[]*regexp.Regexp]{
+			Target:     t,
+			Parser:     sliceParser(parseRegexp, false),
+			listParser: sliceParser(parseRegexp, true),
+			Printer:    slicePrinter(printRegexp),
+			Setter:     sliceSetter[*regexp.Regexp](),
+		})
+	case *slog.Level:
+		s.usage = enumUsage(s.usage, logging.LevelNames())
+		bindFlag(f, s, &Var[slog.Level]{
+			Target:  t,
+			Predict: predict.Set(logging.LevelNames()),
+			Parser:  parseLogLevel,
+			Printer: logging.LevelString,
+		})
+	default:
+		panic(fmt.Sprintf("flag -%s: unsupported field type %T", s.name, reflect.ValueOf(target).Elem().Interface()))
+	}
+}
+
+// stringParser returns the parser and predictor for a string field based on the
+// "values" and "type" tags. Paths are resolved against the working directory.
+func (s *fieldSpec) stringParser(workingDir WorkingDirFunc) (ParserFunc[string], complete.Predictor) {
+	switch {
+	case len(s.values) > 0:
+		s.usage = enumUsage(s.usage, s.values)
+		return enumParser(s.values), predict.Set(s.values)
+	case s.kind == "file":
+		return pathParser(false, workingDir), predict.Files("*")
+	case s.kind == "dir":
+		return pathParser(true, workingDir), predict.Dirs("*")
+	case s.kind != "":
+		panic(fmt.Sprintf("flag -%s: unsupported type %q for string field", s.name, s.kind))
+	default:
+		return func(v string) (string, error) { return v, nil }, nil
+	}
+}
+
+// isPath returns true if the field is a file or directory path.
+func (s *fieldSpec) isPath() bool {
+	return s.kind == "file" || s.kind == "dir"
+}
+
+// bindFlag fills in the given variable from the field spec and registers it with
+// [Flag]. The default value is parsed with the variable's parser.
+func bindFlag[T any](f *FlagSection, s *fieldSpec, v *Var[T]) {
+	v.Name = s.name
+	v.Aliases = s.aliases
+	v.Usage = s.usage
+	v.Example = s.example
+	v.EnvVar = s.envVar
+	v.Hidden = s.hidden
+	v.AllowFromFile = s.allowFromFile
+	v.AllowFromPrompt = s.allowFromPrompt
+	v.Sensitive = s.sensitive
+
+	if s.defaultValue != "" {
+		def, err := v.Parser(s.defaultValue)
+		if err != nil {
+			panic(fmt.Sprintf("invalid default value %q for flag -%s: %s", s.defaultValue, s.name, err))
+		}
+		v.Default = def
+	}
+
+	Flag(f, v)
+}
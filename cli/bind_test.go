@@ -0,0 +1,328 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+type bindTestEmbedded struct {
+	Verbose bool `flag:"verbose" alias:"v" usage:"Enable verbose output."`
+}
+
+type bindTestConfig struct {
+	bindTestEmbedded
+
+	Project  string            `flag:"project" alias:"p" env:"PROJECT" usage:"The project."`
+	Workers  int               `flag:"workers" default:"4" usage:"The number of workers."`
+	Count    int64             `flag:"count" default:"-2"`
+	Retries  uint              `flag:"retries" default:"3"`
+	Max      uint64            `flag:"max"`
+	Limit    uint64            `flag:"limit" type:"bytesize" default:"1MiB"`
+	Ratio    float64           `flag:"ratio" default:"0.5"`
+	Timeout  time.Duration     `flag:"timeout" default:"30s"`
+	Since    time.Time         `flag:"since" layout:"2006-01-02"`
+	Format   string            `flag:"format" values:"json,text" default:"text"`
+	Tags     []string          `flag:"tag"`
+	Labels   map[string]string `flag:"label" default:"a=b,c=d"`
+	Endpoint url.URL           `flag:"endpoint"`
+	Pattern  *regexp.Regexp    `flag:"pattern" default:"^a.*"`
+	Secret   string            `flag:"secret" hidden:"true" allowfromfile:"true"`
+	Level    slog.Level        `flag:"level" default:"warn"`
+	Config   string            `flag:"config" type:"file" default:"does-not-exist.yaml"`
+	Includes []string          `flag:"include" type:"dir" default:"a,b"`
+	Ignored  string            `flag:"-"`
+
+	// Structs without flags are not bound.
+	Created  time.Time
+	Metadata struct {
+		Note string
+	}
+
+	Server struct {
+		Addr    netip.Addr     `flag:"addr" default:"127.0.0.1"`
+		Allowed []netip.Prefix `flag:"allow"`
+	} `section:"SERVER OPTIONS"`
+
+	Extra struct {
+		Name string `flag:"extra-name"`
+	}
+}
+
+func TestFlagSection_BindStruct(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		check   func(t *testing.T, cfg *bindTestConfig)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *bindTestConfig) {
+				t.Helper()
+
+				if got, want := cfg.Workers, 4; got != want {
+					t.Errorf("workers: expected %d to be %d", got, want)
+				}
+				if got, want := cfg.Count, int64(-2); got != want {
+					t.Errorf("count: expected %d to be %d", got, want)
+				}
+				if got, want := cfg.Retries, uint(3); got != want {
+					t.Errorf("retries: expected %d to be %d", got, want)
+				}
+				if got, want := cfg.Limit, uint64(1<<20); got != want {
+					t.Errorf("limit: expected %d to be %d", got, want)
+				}
+				if got, want := cfg.Ratio, 0.5; got != want {
+					t.Errorf("ratio: expected %f to be %f", got, want)
+				}
+				if got, want := cfg.Timeout, 30*time.Second; got != want {
+					t.Errorf("timeout: expected %s to be %s", got, want)
+				}
+				if got, want := cfg.Format, "text"; got != want {
+					t.Errorf("format: expected %q to be %q", got, want)
+				}
+				if diff := cmp.Diff(map[string]string{"a": "b", "c": "d"}, cfg.Labels); diff != "" {
+					t.Errorf("labels (-want, +got):\n%s", diff)
+				}
+				if got, want := cfg.Pattern.String(), "^a.*"; got != want {
+					t.Errorf("pattern: expected %q to be %q", got, want)
+				}
+				if got, want := cfg.Level, slog.LevelWarn; got != want {
+					t.Errorf("level: expected %s to be %s", got, want)
+				}
+				// Path defaults are not checked or resolved.
+				if got, want := cfg.Config, "does-not-exist.yaml"; got != want {
+					t.Errorf("config: expected %q to be %q", got, want)
+				}
+				if diff := cmp.Diff([]string{"a", "b"}, cfg.Includes); diff != "" {
+					t.Errorf("includes (-want, +got):\n%s", diff)
+				}
+				if got, want := cfg.Server.Addr, netip.MustParseAddr("127.0.0.1"); got != want {
+					t.Errorf("addr: expected %s to be %s", got, want)
+				}
+			},
+		},
+		{
+			name: "flags",
+			args: []string{
+				"-v",
+				"-p", "my-project",
+				"-workers", "8",
+				"-max", "12",
+				"-limit", "2GB",
+				"-since", "2024-01-02",
+				"-format", "json",
+				"-tag", "a,b", "-tag", "c",
+				"-label", "e=f",
+				"-endpoint", "https://example.com/path",
+				"-secret", "s3cret",
+				"-level", "debug",
+				"-addr", "::1",
+				"-allow", "10.0.0.0/8,192.168.0.0/16",
+				"-extra-name", "extra",
+			},
+			check: func(t *testing.T, cfg *bindTestConfig) {
+				t.Helper()
+
+				if !cfg.Verbose {
+					t.Errorf("verbose: expected true")
+				}
+				if got, want := cfg.Project, "my-project"; got != want {
+					t.Errorf("project: expected %q to be %q", got, want)
+				}
+				if got, want := cfg.Workers, 8; got != want {
+					t.Errorf("workers: expected %d to be %d", got, want)
+				}
+				if got, want := cfg.Max, uint64(12); got != want {
+					t.Errorf("max: expected %d to be %d", got, want)
+				}
+				if got, want := cfg.Limit, uint64(2e9); got != want {
+					t.Errorf("limit: expected %d to be %d", got, want)
+				}
+				if got, want := cfg.Since, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+					t.Errorf("since: expected %s to be %s", got, want)
+				}
+				if got, want := cfg.Format, "json"; got != want {
+					t.Errorf("format: expected %q to be %q", got, want)
+				}
+				if diff := cmp.Diff([]string{"a", "b", "c"}, cfg.Tags); diff != "" {
+					t.Errorf("tags (-want, +got):\n%s", diff)
+				}
+				// Values given on the command line replace the default.
+				if diff := cmp.Diff(map[string]string{"e": "f"}, cfg.Labels); diff != "" {
+					t.Errorf("labels (-want, +got):\n%s", diff)
+				}
+				if got, want := cfg.Endpoint.String(), "https://example.com/path"; got != want {
+					t.Errorf("endpoint: expected %q to be %q", got, want)
+				}
+				if got, want := cfg.Secret, "s3cret"; got != want {
+					t.Errorf("secret: expected %q to be %q", got, want)
+				}
+				if got, want := cfg.Level, slog.LevelDebug; got != want {
+					t.Errorf("level: expected %s to be %s", got, want)
+				}
+				if got, want := cfg.Server.Addr, netip.MustParseAddr("::1"); got != want {
+					t.Errorf("addr: expected %s to be %s", got, want)
+				}
+				if got, want := len(cfg.Server.Allowed), 2; got != want {
+					t.Errorf("allow: expected %d prefixes to be %d", got, want)
+				}
+				if got, want := cfg.Extra.Name, "extra"; got != want {
+					t.Errorf("extra-name: expected %q to be %q", got, want)
+				}
+			},
+		},
+		{
+			name: "env",
+			env:  map[string]string{"PROJECT": "env-project"},
+			check: func(t *testing.T, cfg *bindTestConfig) {
+				t.Helper()
+
+				if got, want := cfg.Project, "env-project"; got != want {
+					t.Errorf("project: expected %q to be %q", got, want)
+				}
+			},
+		},
+		{
+			name:    "invalid_enum",
+			args:    []string{"-format", "xml"},
+			wantErr: `invalid value "xml" for flag -format`,
+		},
+		{
+			name:    "ignored",
+			args:    []string{"-ignored", "x"},
+			wantErr: "flag provided but not defined: -ignored",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cfg bindTestConfig
+			set := NewFlagSet(WithLookupEnv(MapLookuper(tc.env)))
+			set.NewSection("OPTIONS").BindStruct(&cfg)
+
+			err := set.Parse(tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if tc.check != nil {
+				tc.check(t, &cfg)
+			}
+		})
+	}
+}
+
+func TestFlagSection_BindStruct_help(t *testing.T) {
+	t.Parallel()
+
+	var cfg bindTestConfig
+	set := NewFlagSet()
+	set.NewSection("OPTIONS").BindStruct(&cfg)
+
+	help := set.Help()
+	for _, want := range []string{
+		"OPTIONS",
+		"-p, -project",
+		"SERVER OPTIONS",
+		"-addr",
+		"EXTRA",
+		"-extra-name",
+		"Valid values include",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected help to contain %q:\n%s", want, help)
+		}
+	}
+	for _, notWant := range []string{
+		"-secret",
+		"CREATED",
+		"METADATA",
+	} {
+		if strings.Contains(help, notWant) {
+			t.Errorf("expected help to not contain %q:\n%s", notWant, help)
+		}
+	}
+}
+
+func TestFlagSection_BindStruct_panics(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		ptr  any
+		want string
+	}{
+		{
+			name: "not_pointer",
+			ptr:  struct{}{},
+			want: "requires a non-nil pointer to a struct",
+		},
+		{
+			name: "unsupported_type",
+			ptr: &struct {
+				C complex128 `flag:"c"`
+			}{},
+			want: "flag -c: unsupported field type complex128",
+		},
+		{
+			name: "invalid_default",
+			ptr: &struct {
+				N int `flag:"n" default:"abc"`
+			}{},
+			want: `invalid default value "abc" for flag -n`,
+		},
+		{
+			name: "unexported",
+			ptr: &struct {
+				n int `flag:"n"`
+			}{},
+			want: "cannot bind unexported field n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Fatal("expected panic")
+				}
+				if got := r.(string); !strings.Contains(got, tc.want) {
+					t.Errorf("expected panic %q to contain %q", got, tc.want)
+				}
+			}()
+
+			NewFlagSet().NewSection("OPTIONS").BindStruct(tc.ptr)
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clitest provides utilities for testing commands built with the cli
+// package, including comparing their output to golden files.
+//
+// Golden files are updated by running the tests with the UPDATE_GOLDEN
+// environment variable set:
+//
+//	UPDATE_GOLDEN=1 go test ./...
+//
+// If the test package defines its own boolean -update flag, that flag is
+// honored too. This package does not define the flag, so it never conflicts
+// with one defined by the test package.
+package clitest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/abcxyz/pkg/cli"
+)
+
+// updateGoldenEnv is the environment variable which updates golden files
+// instead of comparing against them.
+const updateGoldenEnv = "UPDATE_GOLDEN"
+
+// updateGolden returns true if golden files should be updated, because the
+// UPDATE_GOLDEN environment variable is true or the test package defines an
+// -update flag which is set.
+func updateGolden() bool {
+	if v, err := strconv.ParseBool(os.Getenv(updateGoldenEnv)); err == nil && v {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			v, _ := g.Get().(bool)
+			return v
+		}
+	}
+	return false
+}
+
+// Case is a single invocation of a command.
+type Case struct {
+	// Command is the command to run. It must embed [cli.BaseCommand] (or
+	// otherwise implement SetLookupEnv and SetWorkingDir).
+	Command cli.Command
+
+	// Args are the arguments passed to the command.
+	Args []string
+
+	// Line is the full invocation as a single string, which is split into
+	// arguments like a shell would (see [Split]). If the first argument is the
+	// name of the [cli.RootCommand], it is removed. Line is ignored if Args is
+	// non-empty.
+	Line string
+
+	// Env is the environment of the command. The process environment is never
+	// consulted.
+	Env map[string]string
+
+	// Stdin is the scripted input which is read from the command's stdin.
+	Stdin string
+
+	// Files are created in the working directory before the command is run,
+	// keyed by their slash-separated path relative to the working directory.
+	Files map[string]string
+
+	// Golden is the path to the golden file. The default is
+	// "testdata/<test name>.golden".
+	Golden string
+}
+
+// Result is the result of running a [Case].
+type Result struct {
+	// Stdout and Stderr are the output of the command. The working directory is
+	// replaced with "$WORK".
+	Stdout string
+	Stderr string
+
+	// Err is the error returned by the command, if any.
+	Err error
+
+	// WorkingDir is the temporary working directory in which the command ran.
+	WorkingDir string
+}
+
+// Run runs the case and compares the output and error to the golden file. If
+// golden files are being updated (see the package documentation), the golden
+// file is written instead. The result is returned for further assertions.
+func Run(tb testing.TB, c *Case) *Result {
+	tb.Helper()
+
+	res := Exec(tb, c)
+
+	golden := c.Golden
+	if golden == "" {
+		golden = filepath.Join("testdata", sanitizeName(tb.Name())+".golden")
+	}
+
+	got := res.format()
+	if updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			tb.Fatalf("failed to create golden directory: %s", err)
+		}
+		if err := os.WriteFile(golden, []byte(got), 0o600); err != nil {
+			tb.Fatalf("failed to write golden file: %s", err)
+		}
+		return res
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		tb.Fatalf("failed to read golden file (run with %s=1 to create it): %s", updateGoldenEnv, err)
+	}
+	if diff := cmp.Diff(string(want), got); diff != "" {
+		tb.Errorf("output does not match golden file %s (-want, +got):\n%s\n"+
+			"run with %s=1 to update the golden file", golden, diff, updateGoldenEnv)
+	}
+	return res
+}
+
+// Exec runs the case without comparing it to a golden file. The command runs
+// in a new temporary directory.
+func Exec(tb testing.TB, c *Case) *Result {
+	tb.Helper()
+
+	if c.Command == nil {
+		tb.Fatal("missing command")
+	}
+
+	dir, err := filepath.EvalSymlinks(tb.TempDir())
+	if err != nil {
+		tb.Fatalf("failed to resolve working directory: %s", err)
+	}
+
+	for name, contents := range c.Files {
+		pth := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(pth), 0o755); err != nil {
+			tb.Fatalf("failed to create directory for %s: %s", name, err)
+		}
+		if err := os.WriteFile(pth, []byte(contents), 0o600); err != nil {
+			tb.Fatalf("failed to create %s: %s", name, err)
+		}
+	}
+
+	envSetter, ok := c.Command.(interface{ SetLookupEnv(fn cli.LookupEnvFunc) })
+	if !ok {
+		tb.Fatalf("command %T does not support setting the environment", c.Command)
+	}
+	envSetter.SetLookupEnv(cli.MapLookuper(c.Env))
+
+	wdSetter, ok := c.Command.(interface{ SetWorkingDir(fn cli.WorkingDirFunc) })
+	if !ok {
+		tb.Fatalf("command %T does not support setting the working directory", c.Command)
+	}
+	wdSetter.SetWorkingDir(func() (string, error) { return dir, nil })
+
+	args := c.Args
+	if len(args) == 0 && c.Line != "" {
+		args, err = Split(c.Line)
+		if err != nil {
+			tb.Fatalf("failed to parse command line: %s", err)
+		}
+		if r, ok := c.Command.(*cli.RootCommand); ok && len(args) > 0 && args[0] == r.Name {
+			args = args[1:]
+		}
+	}
+
+	ctx := context.Background()
+	if t, ok := tb.(interface{ Context() context.Context }); ok {
+		ctx = t.Context()
+	}
+
+	var stdout, stderr strings.Builder
+	c.Command.SetStdin(strings.NewReader(c.Stdin))
+	c.Command.SetStdout(&stdout)
+	c.Command.SetStderr(&stderr)
+
+	err = c.Command.Run(ctx, args)
+
+	return &Result{
+		Stdout:     strings.ReplaceAll(stdout.String(), dir, "$WORK"),
+		Stderr:     strings.ReplaceAll(stderr.String(), dir, "$WORK"),
+		Err:        err,
+		WorkingDir: dir,
+	}
+}
+
+// format renders the result in the golden file format.
+func (r *Result) format() string {
+	var errStr string
+	if r.Err != nil {
+		errStr = strings.ReplaceAll(r.Err.Error(), r.WorkingDir, "$WORK")
+	}
+
+	var b strings.Builder
+	for _, section := range []struct {
+		name, value string
+	}{
+		{"stdout", r.Stdout},
+		{"stderr", r.Stderr},
+		{"error", errStr},
+	} {
+		fmt.Fprintf(&b, "-- %s --\n", section.name)
+		b.WriteString(section.value)
+		if section.value != "" && !strings.HasSuffix(section.value, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Split splits the command line into arguments. Arguments are separated by
+// whitespace, and may be quoted with single or double quotes. Within double
+// quotes, a backslash escapes a double quote or backslash. Outside of quotes, a
+// backslash escapes the next character.
+func Split(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			cur.WriteRune(runes[i])
+			inArg = true
+		case r == '\'' || r == '"':
+			inArg = true
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == r {
+					closed = true
+					break
+				}
+				if r == '"' && runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				cur.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated %c quote", r)
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// sanitizeName converts the test name into a file path. Subtests become
+// subdirectories.
+func sanitizeName(name string) string {
+	return filepath.FromSlash(strings.Map(func(r rune) rune {
+		switch {
+		case r == '/':
+			return r
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name))
+}
@@ -0,0 +1,284 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clitest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/abcxyz/pkg/testutil"
+)
+
+// Test packages commonly define their own -update flag for golden files, which
+// must not conflict with this package.
+var _ = flag.Bool("update", false, "update golden files")
+
+type greetCommand struct {
+	cli.BaseCommand
+
+	flagName   string
+	flagConfig string
+}
+
+func (c *greetCommand) Desc() string { return "Greet someone" }
+
+func (c *greetCommand) Help() string { return "Usage: {{ COMMAND }} [options]" }
+
+func (c *greetCommand) Flags() *cli.FlagSet {
+	set := c.NewFlagSet()
+	f := set.NewSection("OPTIONS")
+	f.StringVar(&cli.StringVar{
+		Name:   "name",
+		EnvVar: "GREET_NAME",
+		Target: &c.flagName,
+		Usage:  "The name to greet.",
+	})
+	f.StringVar(&cli.StringVar{
+		Name:          "config",
+		Target:        &c.flagConfig,
+		AllowFromFile: true,
+		Usage:         "The configuration.",
+	})
+	return set
+}
+
+func (c *greetCommand) Run(ctx context.Context, args []string) error {
+	if err := c.Flags().Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	name := c.flagName
+	if name == "" {
+		v, err := c.Prompt(ctx, "Name: ")
+		if err != nil {
+			return fmt.Errorf("failed to prompt: %w", err)
+		}
+		name = v
+	}
+	if name == "nobody" {
+		return fmt.Errorf("cannot greet nobody")
+	}
+
+	wd, err := c.WorkingDir()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	c.Outf("Hello, %s!", name)
+	c.Errf("running in %s with config %q", wd, c.flagConfig)
+	return nil
+}
+
+func testRoot() *cli.RootCommand {
+	return &cli.RootCommand{
+		Name: "tool",
+		Commands: map[string]cli.CommandFactory{
+			"greet": func() cli.Command { return &greetCommand{} },
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		c    *Case
+	}{
+		{
+			name: "args",
+			c: &Case{
+				Command: &greetCommand{},
+				Args:    []string{"-name", "Alice"},
+			},
+		},
+		{
+			name: "line",
+			c: &Case{
+				Command: testRoot(),
+				Line:    `tool greet -name "Bob Smith" -config @config.txt`,
+				Files: map[string]string{
+					"config.txt": "from file",
+				},
+			},
+		},
+		{
+			name: "env",
+			c: &Case{
+				Command: testRoot(),
+				Line:    "greet",
+				Env:     map[string]string{"GREET_NAME": "Carol"},
+			},
+		},
+		{
+			name: "stdin",
+			c: &Case{
+				Command: testRoot(),
+				Line:    "greet",
+				Stdin:   "Dave\n",
+			},
+		},
+		{
+			name: "error",
+			c: &Case{
+				Command: testRoot(),
+				Line:    "greet -name nobody",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			Run(t, tc.c)
+		})
+	}
+}
+
+func TestRun_mismatch(t *testing.T) {
+	t.Parallel()
+
+	golden := filepath.Join(t.TempDir(), "out.golden")
+	if err := os.WriteFile(golden, []byte("-- stdout --\nnope\n-- stderr --\n-- error --\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ft := &fakeTB{TB: t}
+	Run(ft, &Case{
+		Command: &greetCommand{},
+		Args:    []string{"-name", "Alice"},
+		Golden:  golden,
+	})
+
+	if got, want := len(ft.errors), 1; got != want {
+		t.Fatalf("expected %d errors to be %d: %q", got, want, ft.errors)
+	}
+}
+
+func TestRun_update(t *testing.T) {
+	t.Setenv(updateGoldenEnv, "1")
+
+	golden := filepath.Join(t.TempDir(), "testdata", "out.golden")
+	Run(t, &Case{
+		Command: &greetCommand{},
+		Args:    []string{"-name", "Alice"},
+		Golden:  golden,
+	})
+
+	b, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "Hello, Alice!"; !strings.Contains(got, want) {
+		t.Errorf("expected %q to contain %q", got, want)
+	}
+}
+
+func TestExec(t *testing.T) {
+	t.Parallel()
+
+	res := Exec(t, &Case{
+		Command: &greetCommand{},
+		Args:    []string{"-name", "Erin"},
+	})
+
+	if got, want := res.Stdout, "Hello, Erin!\n"; got != want {
+		t.Errorf("expected stdout %q to be %q", got, want)
+	}
+	if got, want := res.Stderr, "running in $WORK with config \"\"\n"; got != want {
+		t.Errorf("expected stderr %q to be %q", got, want)
+	}
+	if res.Err != nil {
+		t.Errorf("unexpected error: %s", res.Err)
+	}
+	if _, err := os.Stat(res.WorkingDir); err != nil {
+		t.Errorf("expected working directory to exist: %s", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "empty",
+			line: "  ",
+		},
+		{
+			name: "spaces",
+			line: " a  b\tc ",
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "quotes",
+			line: `a "b c" 'd "e"' f"g h"i ""`,
+			want: []string{"a", "b c", `d "e"`, "fg hi", ""},
+		},
+		{
+			name: "escapes",
+			line: `a\ b "c\"d\\e" 'f\g'`,
+			want: []string{"a b", `c"d\e`, `f\g`},
+		},
+		{
+			name:    "unterminated",
+			line:    `a "b`,
+			wantErr: "unterminated \" quote",
+		},
+		{
+			name:    "trailing_backslash",
+			line:    `a\`,
+			wantErr: "trailing backslash",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Split(tc.line)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("args (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeTB records errors instead of failing the test.
+type fakeTB struct {
+	testing.TB
+
+	errors []string
+}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
@@ -25,6 +25,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -53,7 +54,9 @@ type Command interface {
 	// flag information.
 	//
 	// Callers can insert the literal string "{{ COMMAND }}" which will be
-	// replaced with the actual subcommand structure.
+	// replaced with the actual subcommand structure, and "{{ ARGS }}" which will
+	// be replaced with the usage of the positional arguments declared with
+	// [Arg].
 	Help() string
 
 	// Flags returns the list of flags that are defined on the command.
@@ -124,6 +127,118 @@ type RootCommand struct {
 
 	// Commands is the list of sub commands.
 	Commands map[string]CommandFactory
+
+	// Aliases maps alternate names to the name of a command in
+	// [RootCommand.Commands]. Aliases are accepted anywhere the command name is
+	// accepted, and are shown in help output and completions.
+	Aliases map[string]string
+
+	// Categories groups commands under titled headings in help output, in the
+	// given order. Commands which do not belong to a category are listed last,
+	// under "OTHER COMMANDS". If there are no categories, all commands are
+	// listed alphabetically without a heading.
+	Categories []*CommandCategory
+
+	// Deprecated marks commands or aliases as deprecated, keyed by the deprecated
+	// name. Deprecated names are still accepted, but print a warning when used.
+	// To rename a command, make the old name a deprecated alias of the new
+	// command.
+	Deprecated map[string]*Deprecation
+
+	// PersistentFlags registers flags that are accepted by this command and
+	// every descendant command. Persistent flags can appear anywhere on the
+	// command line, including before the subcommand name. They are merged into
+	// each descendant's [FlagSet] (when created with [BaseCommand.NewFlagSet])
+	// and rendered in a separate section of the help output. Descendants can
+	// access the parsed values through the flag targets or via
+	// [PersistentFlagsFromContext].
+	//
+	// Descendant commands should not define flags with the same name as a
+	// persistent flag.
+	PersistentFlags func(set *FlagSet)
+
+	// EnablePlugins enables discovery of plugins, which are executables in $PATH
+	// named "<name>-<subcommand>" (e.g. "tool-foo" for "tool foo"). For nested
+	// commands, the command names are joined with dashes (e.g. "tool-child-foo").
+	// If a subcommand is not found in [RootCommand.Commands], a matching plugin
+	// is executed with the remaining arguments, inheriting the standard streams
+	// and environment. Persistent flags are consumed by this command and are not
+	// passed to plugins.
+	EnablePlugins bool
+
+	// Middleware wraps the execution of every subcommand dispatched by this
+	// command, including subcommands of nested [RootCommand], for running
+	// cross-cutting logic such as configuring logging or recovering panics. The
+	// first middleware is the outermost. Middleware of parent commands wraps the
+	// middleware of nested commands, and the chain runs once per invocation.
+	Middleware []Middleware
+
+	// parent is the parent command, if this is a nested subcommand. It is set
+	// when the parent dispatches to this command.
+	parent *RootCommand
+}
+
+// CommandCategory is a titled group of commands in the help output of a
+// [RootCommand].
+type CommandCategory struct {
+	// Title is the heading of the category (e.g. "CLUSTER COMMANDS"). By
+	// convention, titles are all capital letters.
+	Title string
+
+	// Commands are the names of the commands in the category, in the order in
+	// which they are listed. Names which are not in [RootCommand.Commands] are
+	// ignored.
+	Commands []string
+}
+
+// builtinCommands are hidden commands that are available on every top-level
+// [RootCommand]. Commands defined in [RootCommand.Commands] take precedence
+// over built-in commands of the same name.
+var builtinCommands = map[string]func(r *RootCommand) Command{
+	"completion": func(r *RootCommand) Command {
+		return &completionCommand{root: r}
+	},
+	"gen-docs": func(r *RootCommand) Command {
+		return &genDocsCommand{root: r}
+	},
+}
+
+// lookupCommand finds the command factory for the given name. User-defined
+// commands take precedence over built-in commands, and built-in commands are
+// only available on the top-level root. Plugins are only used if no other
+// command matches.
+func (r *RootCommand) lookupCommand(name string) (CommandFactory, bool) {
+	if cmd, ok := r.lookupDefinedCommand(name); ok {
+		return cmd, true
+	}
+
+	if p, ok := r.lookupPlugin(name); ok {
+		return func() Command { return &pluginCommand{plugin: p} }, true
+	}
+
+	return nil, false
+}
+
+// lookupDefinedCommand finds the command factory for the given name, excluding
+// plugins.
+func (r *RootCommand) lookupDefinedCommand(name string) (CommandFactory, bool) {
+	if cmd, ok := r.Commands[name]; ok {
+		return cmd, true
+	}
+
+	if target, ok := r.Aliases[name]; ok {
+		if cmd, ok := r.Commands[target]; ok {
+			return cmd, true
+		}
+	}
+
+	if r.parent == nil {
+		if fn, ok := builtinCommands[name]; ok {
+			return func() Command { return fn(r) }, true
+		}
+	}
+
+	return nil, false
 }
 
 // Desc is the root command description. It is used to satisfy the [Command]
@@ -138,35 +253,115 @@ func (r *RootCommand) Hidden() bool {
 	return r.Hide
 }
 
+// Flags returns the persistent flags for the command, including any persistent
+// flags inherited from parent commands. It is used to satisfy the [Command]
+// interface.
+func (r *RootCommand) Flags() *FlagSet {
+	set := r.NewFlagSet()
+	if r.PersistentFlags != nil {
+		r.PersistentFlags(set)
+	}
+	return set
+}
+
 // Help compiles structured help information. It is used to satisfy the
-// [Command] interface.
+// [Command] interface. Command descriptions are wrapped at the width of the
+// terminal, as described by [BaseCommand.ErrStyle].
 func (r *RootCommand) Help() string {
+	return r.help(r.ErrStyle())
+}
+
+// help compiles the help information with the given style, which may be nil
+// for unstyled output wrapped at the default length.
+func (r *RootCommand) help(style *Style) string {
 	var b strings.Builder
 
+	// Aliases are listed alongside the command name.
+	aliases := r.commandAliases()
+
 	longest := 0
 	names := make([]string, 0, len(r.Commands))
-	for name := range r.Commands {
-		names = append(names, name)
-		if l := len(name); l > longest {
+	labels := make(map[string]string, len(r.Commands))
+	descs := make(map[string]string, len(r.Commands))
+	for name, fn := range r.Commands {
+		if r.Deprecated[name].hidden() {
+			continue
+		}
+
+		label := strings.Join(append([]string{name}, aliases[name]...), ", ")
+		if l := len(label); l > longest {
 			longest = l
 		}
+
+		cmd := fn()
+		if cmd == nil || cmd.Hidden() {
+			continue
+		}
+
+		// Trim any trailing periods or spaces.
+		desc := strings.TrimRightFunc(cmd.Desc(), func(r rune) bool {
+			return unicode.IsSpace(r) || r == '\uFEFF' || r == '.' || r == '!' || r == '?'
+		})
+		if r.Deprecated[name] != nil {
+			desc += " (deprecated)"
+		}
+
+		names = append(names, name)
+		labels[name] = label
+		descs[name] = desc
 	}
 	sort.Strings(names)
 
-	fmt.Fprintf(&b, "Usage: %s COMMAND\n\n", r.Name)
-	for _, name := range names {
-		cmd := r.Commands[name]()
-		if cmd == nil {
-			continue
+	// Descriptions are aligned after the longest label and wrapped at the line
+	// length, leaving at least half the minimum line length for the text.
+	pad := 2 + longest + 4
+	length := max(lineLengthFor(style), pad+minLineLength/2)
+	writeCommands := func(list []string) {
+		for _, name := range list {
+			label := labels[name]
+			desc := strings.TrimLeft(wrapAtLengthWithPadding(descs[name], length, pad), " ")
+			fmt.Fprintf(&b, "  %s%s%s\n", style.Highlight(label), strings.Repeat(" ", pad-2-len(label)), desc)
 		}
+	}
+
+	fmt.Fprintf(&b, "Usage: %s COMMAND\n", r.Name)
+
+	if len(r.Categories) == 0 {
+		fmt.Fprint(&b, "\n")
+		writeCommands(names)
+	} else {
+		// Each command is listed in the first category which includes it.
+		listed := make(map[string]struct{}, len(names))
+		writeCategory := func(title string, members []string) {
+			var list []string
+			for _, name := range members {
+				if _, ok := labels[name]; !ok {
+					continue
+				}
+				if _, ok := listed[name]; ok {
+					continue
+				}
+				listed[name] = struct{}{}
+				list = append(list, name)
+			}
+			if len(list) == 0 {
+				return
+			}
 
-		if !cmd.Hidden() {
-			// Trim any trailing periods or spaces.
-			desc := strings.TrimRightFunc(cmd.Desc(), func(r rune) bool {
-				return unicode.IsSpace(r) || r == '\uFEFF' || r == '.' || r == '!' || r == '?'
-			})
-			fmt.Fprintf(&b, "  %-*s%s\n", longest+4, name, desc)
+			fmt.Fprintf(&b, "\n%s\n", style.Bold(title))
+			writeCommands(list)
 		}
+
+		for _, category := range r.Categories {
+			if category != nil {
+				writeCategory(category.Title, category.Commands)
+			}
+		}
+		writeCategory("OTHER COMMANDS", names)
+	}
+
+	if v := r.pluginsHelp(); v != "" {
+		fmt.Fprintf(&b, "\n%s", v)
 	}
 
 	return strings.TrimRightFunc(b.String(), unicode.IsSpace)
@@ -182,11 +377,20 @@ func (r *RootCommand) Run(ctx context.Context, args []string) error {
 		completer.Complete(r.Name)
 	}
 
+	// Persistent flags can appear anywhere on the command line, so pull them out
+	// before looking for the subcommand name.
+	persistent := r.Flags()
+	persistentArgs, args := extractFlags(persistent, args)
+	if err := persistent.Parse(persistentArgs); err != nil {
+		return withUsageHint(fmt.Errorf("failed to parse flags: %w", err), r, r.Name)
+	}
+	ctx = withPersistentFlags(ctx, persistent)
+
 	name, args := extractCommandAndArgs(args)
 
 	// Short-circuit top-level help.
 	if name == "" || name == "-h" || name == "-help" || name == "--help" {
-		r.Errf(formatHelp(r.Help(), r.Name, r.Flags()))
+		r.Errf(formatHelp(r.Help(), r.Name, persistent))
 		return nil
 	}
 
@@ -196,38 +400,126 @@ func (r *RootCommand) Run(ctx context.Context, args []string) error {
 		return nil
 	}
 
-	cmd, ok := r.Commands[name]
+	cmd, ok := r.lookupCommand(name)
 	if !ok {
-		return fmt.Errorf("unknown command %q: run \"%s -help\" for a list of "+
-			"commands", name, r.Name)
+		var err error
+		if v := formatSuggestions(suggest(name, r.visibleCommandNames()), strconv.Quote); v != "" {
+			err = fmt.Errorf("unknown command %q (%s): run \"%s -help\" for a list "+
+				"of commands", name, v, r.Name)
+		} else {
+			err = fmt.Errorf("unknown command %q: run \"%s -help\" for a list of "+
+				"commands", name, r.Name)
+		}
+		return &ExitError{Code: ExitCodeUsage, Err: err}
 	}
 	instance := cmd()
 
-	// Ensure the child inherits the streams from the root.
+	if dep := r.Deprecated[name]; dep != nil {
+		warnDeprecated(r.Stderr(), r.ErrStyle(), dep.describe(fmt.Sprintf("command %q", name), r.Aliases[name], strconv.Quote))
+	}
+
+	// Ensure the child inherits the streams and persistent flags from the root.
 	instance.SetStdin(r.stdin)
 	instance.SetStdout(r.stdout)
 	instance.SetStderr(r.stderr)
+	inheritPersistentFlags(instance, persistent)
+	inheritEnvironment(instance, &r.BaseCommand)
 
 	// If this is a subcommand, prefix the name with the parent and inherit some
 	// values.
 	if typ, ok := instance.(*RootCommand); ok {
 		typ.Name = r.Name + " " + typ.Name
 		typ.Version = r.Version
+		typ.parent = r
 		return typ.Run(ctx, args)
 	}
 
-	if err := instance.Run(ctx, args); err != nil {
+	inv := &Invocation{
+		Path:    r.Name + " " + r.canonicalName(name),
+		Command: instance,
+		Args:    args,
+		Flags:   persistent,
+	}
+	observeFlags(instance, func(set *FlagSet) {
+		inv.Flags = set
+	})
+	if err := buildHandler(runInvocation, r.middlewareChain())(ctx, inv); err != nil {
 		// Special case requesting help.
 		if errors.Is(err, flag.ErrHelp) {
 			instance.Errf(formatHelp(instance.Help(), r.Name+" "+name, instance.Flags()))
 			return nil
 		}
 		//nolint:wrapcheck // We want to bubble this error exactly as-is.
-		return err
+		return withUsageHint(err, instance, r.Name+" "+name)
 	}
 	return nil
 }
 
+// visibleCommandNames returns the names and aliases of all subcommands which
+// are not hidden. This requires instantiating every subcommand.
+func (r *RootCommand) visibleCommandNames() []string {
+	names := make([]string, 0, len(r.Commands))
+	for _, name := range r.commandNames() {
+		if cmd := r.Commands[r.canonicalName(name)](); cmd != nil && !cmd.Hidden() {
+			names = append(names, name)
+		}
+	}
+	for _, p := range r.plugins() {
+		if _, ok := r.lookupDefinedCommand(p.name); !ok {
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
+
+// commandNames returns the sorted names and aliases of all subcommands,
+// excluding deprecated names which are past their grace period.
+func (r *RootCommand) commandNames() []string {
+	names := make([]string, 0, len(r.Commands)+len(r.Aliases))
+	for name := range r.Commands {
+		if !r.Deprecated[name].hidden() {
+			names = append(names, name)
+		}
+	}
+	for _, list := range r.commandAliases() {
+		names = append(names, list...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// commandAliases returns the sorted aliases of each subcommand, keyed by the
+// command name. Aliases of unknown commands and deprecated aliases which are
+// past their grace period are excluded.
+func (r *RootCommand) commandAliases() map[string][]string {
+	aliases := make(map[string][]string, len(r.Aliases))
+	for alias, name := range r.Aliases {
+		if _, ok := r.Commands[name]; !ok {
+			continue
+		}
+		if r.Deprecated[alias].hidden() || r.Deprecated[name].hidden() {
+			continue
+		}
+		aliases[name] = append(aliases[name], alias)
+	}
+	for _, list := range aliases {
+		sort.Strings(list)
+	}
+	return aliases
+}
+
+// canonicalName resolves the given alias to the name of the command. Names
+// which are not aliases are returned unchanged.
+func (r *RootCommand) canonicalName(name string) string {
+	if _, ok := r.Commands[name]; ok {
+		return name
+	}
+	if target, ok := r.Aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
 // extractCommandAndArgs is a helper that pulls the subcommand and arguments.
 func extractCommandAndArgs(args []string) (string, []string) {
 	switch len(args) {
@@ -240,6 +532,115 @@ func extractCommandAndArgs(args []string) (string, []string) {
 	}
 }
 
+// extractFlags splits args into the flags (and their values) which are defined
+// on the given flag set, and all remaining arguments. Extraction stops at the
+// first "--".
+func extractFlags(set *FlagSet, args []string) (matched, rest []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name := strings.TrimPrefix(arg[1:], "-")
+		name, _, hasValue := strings.Cut(name, "=")
+
+		f := set.Lookup(name)
+		if f == nil {
+			rest = append(rest, arg)
+			continue
+		}
+		matched = append(matched, arg)
+
+		if hasValue {
+			continue
+		}
+		if typ, ok := f.Value.(Value); ok && typ.IsBoolFlag() {
+			continue
+		}
+		if i+1 < len(args) {
+			i++
+			matched = append(matched, args[i])
+		}
+	}
+	return matched, rest
+}
+
+// persistentFlagsInheritor is implemented by commands which accept persistent
+// flags from a parent. [BaseCommand] implements this interface.
+type persistentFlagsInheritor interface {
+	setPersistentFlags(set *FlagSet)
+}
+
+// inheritPersistentFlags passes the parent's persistent flags to the given
+// child command, if the command supports it.
+func inheritPersistentFlags(cmd Command, set *FlagSet) {
+	if typ, ok := cmd.(persistentFlagsInheritor); ok {
+		typ.setPersistentFlags(set)
+	}
+}
+
+// flagsObserver is implemented by commands which report the flag set they
+// parse. [BaseCommand] implements this interface.
+type flagsObserver interface {
+	setFlagsParsed(fn func(set *FlagSet))
+}
+
+// observeFlags arranges for fn to be called with the flag set parsed by the
+// given command, if the command supports it.
+func observeFlags(cmd Command, fn func(set *FlagSet)) {
+	if typ, ok := cmd.(flagsObserver); ok {
+		typ.setFlagsParsed(fn)
+	}
+}
+
+// environmentInheritor is implemented by commands which accept the environment
+// lookup and working directory functions from a parent. [BaseCommand]
+// implements this interface.
+type environmentInheritor interface {
+	inheritEnvironment(parent *BaseCommand)
+}
+
+// inheritEnvironment passes the parent's environment lookup and working
+// directory functions to the given child command, if the command supports it.
+func inheritEnvironment(cmd Command, parent *BaseCommand) {
+	if typ, ok := cmd.(environmentInheritor); ok {
+		typ.inheritEnvironment(parent)
+	}
+}
+
+// contextKey is a private string type to prevent collisions in the context
+// map.
+type contextKey string
+
+// persistentFlagsKey points to the value in the context where the parsed
+// persistent flags are stored.
+const persistentFlagsKey = contextKey("persistentFlags")
+
+// withPersistentFlags stores the persistent flags in the context.
+func withPersistentFlags(ctx context.Context, set *FlagSet) context.Context {
+	return context.WithValue(ctx, persistentFlagsKey, set)
+}
+
+// PersistentFlagsFromContext returns the parsed persistent flags of the nearest
+// [RootCommand], including any flags inherited from its parents. It returns nil
+// if the context was not created by [RootCommand.Run].
+//
+//	set := cli.PersistentFlagsFromContext(ctx)
+//	project := set.Lookup("project").Value.String()
+func PersistentFlagsFromContext(ctx context.Context) *FlagSet {
+	if set, ok := ctx.Value(persistentFlagsKey).(*FlagSet); ok {
+		return set
+	}
+	return nil
+}
+
 // formatHelp is a helper function that does variable replacement from the help
 // string.
 func formatHelp(help, name string, flags *FlagSet) string {
@@ -249,7 +650,7 @@ func formatHelp(help, name string, flags *FlagSet) string {
 			h = h + "\n\n" + v
 		}
 	}
-	return strings.ReplaceAll(h, "{{ COMMAND }}", name)
+	return expandHelp(h, name, flags)
 }
 
 // BaseCommand is the default command structure. All commands should embed this
@@ -258,19 +659,63 @@ type BaseCommand struct {
 	stdout, stderr io.Writer
 	stdin          io.Reader
 
-	lookupEnv LookupEnvFunc
+	lookupEnv  LookupEnvFunc
+	workingDir WorkingDirFunc
+
+	// persistentFlags are the persistent flags inherited from the parent
+	// command, if any.
+	persistentFlags *FlagSet
+
+	// flagsParsed, if set, is called with each flag set created by NewFlagSet
+	// after it is parsed.
+	flagsParsed func(set *FlagSet)
 }
 
-// NewFlagSet creates a new flag set that inherits properties from the command.
+// NewFlagSet creates a new flag set that inherits properties from the command,
+// including any persistent flags defined by parent commands.
 func (c *BaseCommand) NewFlagSet(o ...Option) *FlagSet {
 	opts := []Option{
 		WithLookupEnv(c.LookupEnv),
 		WithPromptAll(c.PromptAll),
+		WithPromptSecret(c.PromptSecret),
 		WithWorkingDir(c.WorkingDir),
+		WithStderr(c.Stderr()),
+		WithStyle(c.ErrStyle()),
 	}
 	opts = append(opts, o...)
 
-	return NewFlagSet(opts...)
+	set := NewFlagSet(opts...)
+	if c.persistentFlags != nil {
+		set.inherit(c.persistentFlags)
+	}
+	if fn := c.flagsParsed; fn != nil {
+		set.AfterParse(func(existingErr error) error {
+			fn(set)
+			return nil
+		})
+	}
+	return set
+}
+
+// inheritEnvironment sets the environment lookup and working directory
+// functions from the parent, unless they were already set on this command.
+func (c *BaseCommand) inheritEnvironment(parent *BaseCommand) {
+	if c.lookupEnv == nil {
+		c.lookupEnv = parent.lookupEnv
+	}
+	if c.workingDir == nil {
+		c.workingDir = parent.workingDir
+	}
+}
+
+// setPersistentFlags sets the persistent flags inherited from the parent.
+func (c *BaseCommand) setPersistentFlags(set *FlagSet) {
+	c.persistentFlags = set
+}
+
+// setFlagsParsed sets the function which is called with each parsed flag set.
+func (c *BaseCommand) setFlagsParsed(fn func(set *FlagSet)) {
+	c.flagsParsed = fn
 }
 
 // Flags returns the base command flags, which is always nil.
@@ -427,6 +872,18 @@ func (c *BaseCommand) Errf(format string, a ...any) {
 	fmt.Fprintf(c.Stderr(), format+"\n", a...)
 }
 
+// OutStyle returns the style for output written to [BaseCommand.Stdout]. See
+// [NewStyle] for when styling is enabled.
+func (c *BaseCommand) OutStyle() *Style {
+	return NewStyle(c.Stdout(), c.LookupEnv)
+}
+
+// ErrStyle returns the style for output written to [BaseCommand.Stderr]. See
+// [NewStyle] for when styling is enabled.
+func (c *BaseCommand) ErrStyle() *Style {
+	return NewStyle(c.Stderr(), c.LookupEnv)
+}
+
 // Stderr returns the stderr stream.
 func (c *BaseCommand) Stderr() io.Writer {
 	if v := c.stderr; v != nil {
@@ -490,9 +947,18 @@ func (c *BaseCommand) GetEnv(key string) string {
 // WorkingDir returns the absolute path of current working directory from where
 // the command was started. All symlinks are resolved to their real paths.
 func (c *BaseCommand) WorkingDir() (string, error) {
+	if v := c.workingDir; v != nil {
+		return v()
+	}
 	return workingDir()
 }
 
+// SetWorkingDir sets the function which returns the working directory. This is
+// mostly used for testing.
+func (c *BaseCommand) SetWorkingDir(fn WorkingDirFunc) {
+	c.workingDir = fn
+}
+
 // ExecutablePath returns the absolute path of the CLI executable binary. All
 // symlinks are resolved to their real values.
 func (c *BaseCommand) ExecutablePath() (string, error) {
@@ -523,11 +989,13 @@ func buildCompleteCommands(cmd Command) *complete.Command {
 		Args:  predict.Nothing,
 	}
 
+	f := cmd.Flags()
 	if typ, ok := cmd.(ArgPredictor); ok {
 		completer.Args = typ.PredictArgs()
+	} else if p := f.argsPredictor(); p != nil {
+		completer.Args = p
 	}
 
-	f := cmd.Flags()
 	if f != nil {
 		f.VisitAll(func(f *flag.Flag) {
 			typ, ok := f.Value.(Value)
@@ -553,16 +1021,23 @@ func buildCompleteCommands(cmd Command) *complete.Command {
 	// If this is a root command, recurse and build the child completions.
 	r, ok := cmd.(*RootCommand)
 	if ok {
-		for name, fn := range r.Commands {
-			instance := fn()
+		for _, name := range r.commandNames() {
+			instance := r.Commands[r.canonicalName(name)]()
 
 			// Ignore hidden commands from completions.
 			if instance.Hidden() {
 				continue
 			}
+			inheritPersistentFlags(instance, f)
 
 			completer.Sub[name] = buildCompleteCommands(instance)
 		}
+
+		for _, p := range r.plugins() {
+			if _, ok := completer.Sub[p.name]; !ok {
+				completer.Sub[p.name] = buildCompleteCommands(&pluginCommand{plugin: p})
+			}
+		}
 	}
 
 	return completer
@@ -112,6 +112,15 @@ func Example_completions() {
 	//
 	// This will automatically uninstall the completions.
 	//
+	// Alternatively, every top-level command has a hidden "completion" command
+	// which generates a static completion script for bash, zsh, or fish. Unlike
+	// the installation above, these scripts only invoke the binary for
+	// predictions that cannot be computed ahead of time:
+	//
+	//     source <(my-cli completion bash)
+	//     source <(my-cli completion zsh)
+	//     my-cli completion fish | source
+	//
 	// If users want to install the completions manually, you will need to provide
 	// them shell-specific instructions. The setup usually requires adding the
 	// following lines:
@@ -178,3 +178,97 @@ func Example_persistentFlags() {
 	//         is "http://localhost:8145". This option can also be specified with the
 	//         CLI_SERVER_ADDRESS environment variable.
 }
+
+type DeployCommand struct {
+	cli.BaseCommand
+}
+
+func (c *DeployCommand) Desc() string {
+	return "Deploy the service"
+}
+
+func (c *DeployCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+
+  Deploy the service to the project.
+`
+}
+
+func (c *DeployCommand) Flags() *cli.FlagSet {
+	// Persistent flags from the parent are merged into the flag set.
+	return c.NewFlagSet()
+}
+
+func (c *DeployCommand) Run(ctx context.Context, args []string) error {
+	if err := c.Flags().Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	project := cli.PersistentFlagsFromContext(ctx).Lookup("project").Value
+	c.Outf("Deploying to %s", project)
+	return nil
+}
+
+func Example_rootPersistentFlags() {
+	ctx := context.Background()
+
+	var project string
+
+	rootCmd := func() cli.Command {
+		return &cli.RootCommand{
+			Name:    "my-tool",
+			Version: "1.2.3",
+			PersistentFlags: func(set *cli.FlagSet) {
+				f := set.NewSection("GLOBAL OPTIONS")
+
+				f.StringVar(&cli.StringVar{
+					Name:    "project",
+					Example: "my-project",
+					Default: "default-project",
+					Target:  &project,
+					Usage:   "Project in which to operate.",
+				})
+			},
+			Commands: map[string]cli.CommandFactory{
+				"deploy": func() cli.Command {
+					return &DeployCommand{}
+				},
+			},
+		}
+	}
+
+	cmd := rootCmd()
+
+	// Help output is written to stderr by default. Redirect to stdout so the
+	// "Output" assertion works.
+	cmd.SetStdout(os.Stdout)
+	cmd.SetStderr(os.Stdout)
+
+	// Persistent flags can appear before or after the subcommand.
+	if err := cmd.Run(ctx, []string{"-project", "before", "deploy"}); err != nil {
+		panic(err)
+	}
+	if err := cmd.Run(ctx, []string{"deploy", "-project", "after"}); err != nil {
+		panic(err)
+	}
+
+	cmd.Outf("\nCommand-level help:")
+	if err := cmd.Run(ctx, []string{"deploy", "-h"}); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// Deploying to before
+	// Deploying to after
+	//
+	// Command-level help:
+	// Usage: my-tool deploy [options]
+	//
+	//   Deploy the service to the project.
+	//
+	// GLOBAL OPTIONS
+	//
+	//     -project="my-project"
+	//         Project in which to operate. The default value is "default-project".
+}
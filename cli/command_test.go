@@ -25,7 +25,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+
 	"github.com/abcxyz/pkg/logging"
+	"github.com/abcxyz/pkg/pointer"
 	"github.com/abcxyz/pkg/testutil"
 )
 
@@ -105,6 +108,109 @@ Usage: test COMMAND
 Usage: test COMMAND
 
   one    Test command
+`,
+		},
+		{
+			name: "aliases",
+			cmd: &RootCommand{
+				Name: "test",
+				Commands: map[string]CommandFactory{
+					"list": func() Command { return &TestCommand{} },
+					"two":  func() Command { return &TestCommand{} },
+				},
+				Aliases: map[string]string{
+					"ls":      "list",
+					"l":       "list",
+					"missing": "nope",
+				},
+			},
+			exp: `
+Usage: test COMMAND
+
+  list, l, ls    Test command
+  two            Test command
+`,
+		},
+		{
+			name: "deprecated",
+			cmd: &RootCommand{
+				Name: "test",
+				Commands: map[string]CommandFactory{
+					"one":   func() Command { return &TestCommand{} },
+					"two":   func() Command { return &TestCommand{} },
+					"three": func() Command { return &TestCommand{} },
+				},
+				Aliases: map[string]string{
+					"uno": "one",
+					"un":  "one",
+				},
+				Deprecated: map[string]*Deprecation{
+					"two":   {Replacement: "one"},
+					"three": {HideAfter: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+					"un":    {HideAfter: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+			exp: `
+Usage: test COMMAND
+
+  one, uno    Test command
+  two         Test command (deprecated)
+`,
+		},
+		{
+			name: "categories",
+			cmd: &RootCommand{
+				Name: "test",
+				Commands: map[string]CommandFactory{
+					"create":  func() Command { return &TestCommand{} },
+					"delete":  func() Command { return &TestCommand{} },
+					"login":   func() Command { return &TestCommand{} },
+					"version": func() Command { return &TestCommand{} },
+					"secret":  func() Command { return &TestCommand{Hide: true} },
+				},
+				Categories: []*CommandCategory{
+					{Title: "CLUSTER COMMANDS", Commands: []string{"delete", "create", "missing"}},
+					{Title: "AUTH COMMANDS", Commands: []string{"login", "create"}},
+					{Title: "HIDDEN COMMANDS", Commands: []string{"secret"}},
+				},
+			},
+			exp: `
+Usage: test COMMAND
+
+CLUSTER COMMANDS
+  delete     Test command
+  create     Test command
+
+AUTH COMMANDS
+  login      Test command
+
+OTHER COMMANDS
+  version    Test command
+`,
+		},
+		{
+			name: "wrapped",
+			cmd: &RootCommand{
+				BaseCommand: BaseCommand{
+					lookupEnv: MapLookuper(map[string]string{"COLUMNS": "40"}),
+				},
+				Name: "test",
+				Commands: map[string]CommandFactory{
+					"one": func() Command {
+						return &TestCommand{
+							Summary: "A command with a description which is longer than the terminal",
+						}
+					},
+					"two": func() Command { return &TestCommand{} },
+				},
+			},
+			exp: `
+Usage: test COMMAND
+
+  one    A command with a description
+         which is longer than the
+         terminal
+  two    Test command
 `,
 		},
 	}
@@ -160,6 +266,17 @@ func TestRootCommand_Run(t *testing.T) {
 					}
 				},
 			},
+			Aliases: map[string]string{
+				"def": "default",
+				"old": "default",
+			},
+			Deprecated: map[string]*Deprecation{
+				"old": {Message: "It will be removed in v2."},
+				"error": {
+					Replacement: "default",
+					HideAfter:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
 		}
 	}
 
@@ -211,6 +328,21 @@ func TestRootCommand_Run(t *testing.T) {
 			args: []string{"nope"},
 			err:  `unknown command "nope": run "test -help" for a list of commands`,
 		},
+		{
+			name: "unknown_command_suggestion",
+			args: []string{"defualt"},
+			err:  `unknown command "defualt" (did you mean "default"?): run "test -help" for a list of commands`,
+		},
+		{
+			name: "unknown_command_no_hidden_suggestion",
+			args: []string{"hiden"},
+			err:  `unknown command "hiden": run "test -help" for a list of commands`,
+		},
+		{
+			name: "unknown_child_command_suggestion",
+			args: []string{"child", "defaul"},
+			err:  `unknown command "defaul" (did you mean "default"?): run "test child -help"`,
+		},
 		{
 			name:      "runs_parent_command",
 			args:      []string{"default"},
@@ -221,6 +353,23 @@ func TestRootCommand_Run(t *testing.T) {
 			args: []string{"error"},
 			err:  `a bad thing happened`,
 		},
+		{
+			name:      "runs_alias",
+			args:      []string{"def"},
+			expStdout: `output from default command`,
+		},
+		{
+			name:      "runs_deprecated_alias",
+			args:      []string{"old"},
+			expStdout: `output from default command`,
+			expStderr: `WARNING: command "old" is deprecated, use "default" instead. It will be removed in v2.`,
+		},
+		{
+			name:      "runs_deprecated_hidden",
+			args:      []string{"error"},
+			err:       `a bad thing happened`,
+			expStderr: `WARNING: command "error" is deprecated, use "default" instead.`,
+		},
 		{
 			name:      "runs_hidden",
 			args:      []string{"hidden"},
@@ -270,6 +419,237 @@ func TestRootCommand_Run(t *testing.T) {
 	}
 }
 
+func TestRootCommand_PersistentFlags(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		args       []string
+		err        string
+		expProject string
+		expDebug   bool
+		expString  string
+		expStderr  string
+	}{
+		{
+			name:       "default",
+			args:       []string{"child", "default"},
+			expProject: "default-project",
+		},
+		{
+			name:       "before_command",
+			args:       []string{"-project", "p1", "child", "default"},
+			expProject: "p1",
+		},
+		{
+			name:       "between_commands",
+			args:       []string{"child", "-debug", "default"},
+			expProject: "default-project",
+			expDebug:   true,
+		},
+		{
+			name:       "after_command",
+			args:       []string{"child", "default", "-string", "s", "-p=p2", "--debug"},
+			expProject: "p2",
+			expDebug:   true,
+			expString:  "s",
+		},
+		{
+			name:       "after_double_dash",
+			args:       []string{"child", "default", "--", "-project", "p3"},
+			expProject: "default-project",
+		},
+		{
+			name: "invalid_value",
+			args: []string{"child", "-debug=banana", "default"},
+			err:  `invalid boolean value "banana" for -debug`,
+		},
+		{
+			name:       "top_level_help",
+			args:       []string{"-project", "p1"},
+			expProject: "p1",
+			expStderr:  "GLOBAL OPTIONS",
+		},
+		{
+			name:       "child_help",
+			args:       []string{"child", "default", "-h"},
+			expProject: "default-project",
+			expStderr: `OPTIONS
+
+    -string="my-string"
+        A literal string.
+
+CHILD OPTIONS
+
+    -debug
+        Enable debug mode. The default value is "false".
+
+GLOBAL OPTIONS
+
+    -p, -project="my-project"
+        Project ID. The default value is "default-project".`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var project, gotString string
+			var debug bool
+			var fromContext *FlagSet
+
+			cmd := &RootCommand{
+				Name: "test",
+				PersistentFlags: func(set *FlagSet) {
+					f := set.NewSection("GLOBAL OPTIONS")
+					f.StringVar(&StringVar{
+						Name:    "project",
+						Aliases: []string{"p"},
+						Example: "my-project",
+						Default: "default-project",
+						Target:  &project,
+						Usage:   "Project ID.",
+					})
+				},
+				Commands: map[string]CommandFactory{
+					"child": func() Command {
+						return &RootCommand{
+							Name: "child",
+							PersistentFlags: func(set *FlagSet) {
+								f := set.NewSection("CHILD OPTIONS")
+								f.BoolVar(&BoolVar{
+									Name:   "debug",
+									Target: &debug,
+									Usage:  "Enable debug mode.",
+								})
+							},
+							Commands: map[string]CommandFactory{
+								"default": func() Command {
+									return &TestCommand{
+										RunFunc: func(ctx context.Context, c *TestCommand) {
+											gotString = c.flagString
+											fromContext = PersistentFlagsFromContext(ctx)
+										},
+									}
+								},
+							},
+						}
+					},
+				},
+			}
+			_, _, stderr := cmd.Pipe()
+
+			err := cmd.Run(t.Context(), tc.args)
+			if diff := testutil.DiffErrString(err, tc.err); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+
+			if got, want := project, tc.expProject; got != want {
+				t.Errorf("project: expected %q to be %q", got, want)
+			}
+			if got, want := debug, tc.expDebug; got != want {
+				t.Errorf("debug: expected %t to be %t", got, want)
+			}
+			if got, want := gotString, tc.expString; got != want {
+				t.Errorf("string: expected %q to be %q", got, want)
+			}
+			if got, want := stderr.String(), tc.expStderr; !strings.Contains(got, want) {
+				t.Errorf("expected\n\n%s\n\nto contain\n\n%s\n\n", got, want)
+			}
+
+			if fromContext != nil {
+				if got, want := fromContext.Lookup("project").Value.String(), tc.expProject; got != want {
+					t.Errorf("project from context: expected %q to be %q", got, want)
+				}
+				if got, want := fromContext.Lookup("debug").Value.String(), fmt.Sprintf("%t", tc.expDebug); got != want {
+					t.Errorf("debug from context: expected %q to be %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractFlags(t *testing.T) {
+	t.Parallel()
+
+	set := NewFlagSet()
+	f := set.NewSection("OPTIONS")
+	f.StringVar(&StringVar{
+		Name:    "string",
+		Aliases: []string{"s"},
+		Target:  pointer.To(""),
+	})
+	f.BoolVar(&BoolVar{
+		Name:   "bool",
+		Target: pointer.To(false),
+	})
+
+	cases := []struct {
+		name       string
+		args       []string
+		expMatched []string
+		expRest    []string
+	}{
+		{
+			name: "empty",
+			args: nil,
+		},
+		{
+			name:    "no_matches",
+			args:    []string{"foo", "-other", "bar"},
+			expRest: []string{"foo", "-other", "bar"},
+		},
+		{
+			name:       "value_separate",
+			args:       []string{"foo", "-string", "value", "bar"},
+			expMatched: []string{"-string", "value"},
+			expRest:    []string{"foo", "bar"},
+		},
+		{
+			name:       "value_equals",
+			args:       []string{"--s=value", "foo"},
+			expMatched: []string{"--s=value"},
+			expRest:    []string{"foo"},
+		},
+		{
+			name:       "bool",
+			args:       []string{"-bool", "foo"},
+			expMatched: []string{"-bool"},
+			expRest:    []string{"foo"},
+		},
+		{
+			name:       "missing_value",
+			args:       []string{"foo", "-string"},
+			expMatched: []string{"-string"},
+			expRest:    []string{"foo"},
+		},
+		{
+			name:       "double_dash",
+			args:       []string{"-bool", "--", "-string", "value"},
+			expMatched: []string{"-bool"},
+			expRest:    []string{"--", "-string", "value"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			matched, rest := extractFlags(set, tc.args)
+			if diff := cmp.Diff(tc.expMatched, matched); diff != "" {
+				t.Errorf("matched (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expRest, rest); diff != "" {
+				t.Errorf("rest (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 //nolint:thelper // These aren't actually helpers, and we want the failures to show the correct line
 func TestBaseCommand_Prompt(t *testing.T) {
 	t.Parallel()
@@ -781,6 +1161,57 @@ func TestBaseCommand_WorkingDir(t *testing.T) {
 	}
 }
 
+func TestBaseCommand_SetWorkingDir(t *testing.T) {
+	t.Parallel()
+
+	var cmd RootCommand
+	cmd.SetWorkingDir(func() (string, error) { return "/my/dir", nil })
+
+	dir, err := cmd.WorkingDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dir, "/my/dir"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestRootCommand_Run_inheritsEnvironment(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	cmd := &RootCommand{
+		Name: "test",
+		Commands: map[string]CommandFactory{
+			"child": func() Command {
+				return &RootCommand{
+					Name: "child",
+					Commands: map[string]CommandFactory{
+						"leaf": func() Command {
+							return &TestCommand{
+								RunFunc: func(ctx context.Context, c *TestCommand) {
+									dir, _ := c.WorkingDir()
+									got = c.GetEnv("FOO") + " " + dir
+								},
+							}
+						},
+					},
+				}
+			},
+		},
+	}
+	cmd.Pipe()
+	cmd.SetLookupEnv(MapLookuper(map[string]string{"FOO": "bar"}))
+	cmd.SetWorkingDir(func() (string, error) { return "/my/dir", nil })
+
+	if err := cmd.Run(t.Context(), []string{"child", "leaf"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "bar /my/dir"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
 func TestBaseCommand_ExecutablePath(t *testing.T) {
 	t.Parallel()
 
@@ -797,14 +1228,19 @@ func TestBaseCommand_ExecutablePath(t *testing.T) {
 type TestCommand struct {
 	BaseCommand
 
-	Hide   bool
-	Output string
-	Error  error
+	Hide    bool
+	Summary string
+	Output  string
+	Error   error
+	RunFunc func(ctx context.Context, c *TestCommand)
 
 	flagString string
 }
 
 func (c *TestCommand) Desc() string {
+	if c.Summary != "" {
+		return c.Summary
+	}
 	return "Test command"
 }
 
@@ -838,6 +1274,10 @@ func (c *TestCommand) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	if fn := c.RunFunc; fn != nil {
+		fn(ctx, c)
+	}
+
 	if v := c.Output; v != "" {
 		c.Outf(v)
 	}
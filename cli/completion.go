@@ -0,0 +1,648 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+)
+
+// completionShells is the list of shells for which completion scripts can be
+// generated.
+var completionShells = []string{"bash", "fish", "zsh"}
+
+// Ensure [completionCommand] implements [Command] and [ArgPredictor].
+var (
+	_ Command      = (*completionCommand)(nil)
+	_ ArgPredictor = (*completionCommand)(nil)
+)
+
+// completionCommand is a built-in, hidden command that generates static shell
+// completion scripts for the command tree of a [RootCommand].
+type completionCommand struct {
+	BaseCommand
+
+	root *RootCommand
+}
+
+func (c *completionCommand) Desc() string {
+	return "Generate shell completion scripts"
+}
+
+func (c *completionCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} SHELL
+
+  Generate a completion script for the given shell and print it to stdout.
+  Supported shells are: ` + strings.Join(completionShells, ", ") + `.
+
+  To load completions in the current bash or zsh session:
+
+      source <({{ COMMAND }} bash)
+
+  To load completions in the current fish session:
+
+      {{ COMMAND }} fish | source
+
+  Flag values and arguments which cannot be predicted statically are resolved
+  by invoking the binary, so the binary must be on your $PATH.
+`
+}
+
+func (c *completionCommand) Hidden() bool {
+	return true
+}
+
+func (c *completionCommand) Flags() *FlagSet {
+	return c.NewFlagSet()
+}
+
+func (c *completionCommand) PredictArgs() complete.Predictor {
+	return predict.Set(completionShells)
+}
+
+func (c *completionCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	args = f.Args()
+
+	if len(args) != 1 {
+		return fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+
+	tree := buildCompletionTree(c.root, "")
+
+	switch shell := args[0]; shell {
+	case "bash":
+		c.Outf("%s", bashCompletion(c.root.Name, tree))
+	case "fish":
+		c.Outf("%s", fishCompletion(c.root.Name, tree))
+	case "zsh":
+		c.Outf("%s", zshCompletion(c.root.Name, tree))
+	default:
+		return fmt.Errorf("unsupported shell %q, valid shells are: %s",
+			shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+// predictionKind is the strategy a completion script uses to suggest values
+// for a flag or argument.
+type predictionKind int
+
+const (
+	// predictNone suggests nothing.
+	predictNone predictionKind = iota
+
+	// predictStatic suggests a fixed list of values known at generation time.
+	predictStatic
+
+	// predictFiles delegates to the shell's native file completion.
+	predictFiles
+
+	// predictDynamic re-invokes the binary with the COMP_LINE protocol to
+	// compute suggestions at completion time.
+	predictDynamic
+)
+
+// prediction is a shell-agnostic description of how to complete a value.
+type prediction struct {
+	kind   predictionKind
+	values []string
+}
+
+// completionFlag is a single, non-hidden flag and all of its names.
+type completionFlag struct {
+	// names are the dash-prefixed names of the flag, as they are given on the
+	// command line (e.g. "--output" for a flag set with [WithPOSIXFlags]).
+	names   []string
+	isBool  bool
+	predict prediction
+}
+
+// completionNode is a shell-agnostic representation of a command in the
+// command tree, used to generate static completion scripts.
+type completionNode struct {
+	// path is the space-separated list of subcommands that lead to this node.
+	// It is empty for the root.
+	path string
+
+	name  string
+	desc  string
+	subs  []*completionNode
+	flags []*completionFlag
+	args  prediction
+}
+
+// buildCompletionTree builds the completion tree for the given command,
+// recursing into nested [RootCommand].
+//
+// WARNING: Like [buildCompleteCommands], this function instantiates the entire
+// command tree and makes no attempt to detect cycles.
+func buildCompletionTree(cmd Command, path string) *completionNode {
+	node := &completionNode{
+		path: path,
+		desc: cmd.Desc(),
+	}
+	if idx := strings.LastIndex(path, " "); idx >= 0 {
+		node.name = path[idx+1:]
+	} else {
+		node.name = path
+	}
+
+	f := cmd.Flags()
+	if typ, ok := cmd.(ArgPredictor); ok {
+		node.args = classifyPredictor(typ.PredictArgs())
+	} else if p := f.argsPredictor(); p != nil {
+		node.args = classifyPredictor(p)
+	}
+
+	if f != nil {
+		f.VisitAll(func(fl *flag.Flag) {
+			typ, ok := fl.Value.(Value)
+			if !ok {
+				panic(fmt.Sprintf("flag is incorrect type %T", fl.Value))
+			}
+
+			// Do not process hidden flags.
+			if typ.Hidden() {
+				return
+			}
+
+			// Aliases are registered as their own flags, but they are included with
+			// the flag that declares them.
+			if slices.Contains(typ.Aliases(), fl.Name) {
+				return
+			}
+
+			names := make([]string, 0, len(typ.Aliases())+1)
+			for _, name := range append([]string{fl.Name}, typ.Aliases()...) {
+				names = append(names, f.dashedName(name))
+			}
+			sort.Strings(names)
+
+			cf := &completionFlag{
+				names:  names,
+				isBool: typ.IsBoolFlag(),
+			}
+			if !cf.isBool {
+				cf.predict = classifyPredictor(typ.Predictor())
+			}
+			node.flags = append(node.flags, cf)
+		})
+	}
+
+	if r, ok := cmd.(*RootCommand); ok {
+		for _, name := range r.commandNames() {
+			instance := r.Commands[r.canonicalName(name)]()
+
+			// Ignore hidden commands from completions.
+			if instance == nil || instance.Hidden() {
+				continue
+			}
+			inheritPersistentFlags(instance, f)
+
+			node.subs = append(node.subs, buildCompletionTree(instance, joinCompletionPath(path, name)))
+		}
+
+		for _, p := range r.plugins() {
+			if _, ok := r.lookupDefinedCommand(p.name); ok {
+				continue
+			}
+			node.subs = append(node.subs, buildCompletionTree(&pluginCommand{plugin: p}, joinCompletionPath(path, p.name)))
+		}
+	}
+
+	return node
+}
+
+// classifyPredictor inspects the given predictor and determines the best
+// strategy for representing it in a static completion script.
+func classifyPredictor(p complete.Predictor) prediction {
+	switch typ := p.(type) {
+	case nil:
+		return prediction{kind: predictNone}
+	case predict.Set:
+		values := make([]string, 0, len(typ))
+		for _, v := range typ {
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+		return staticPrediction(values)
+	case predict.FilesPredictor:
+		return prediction{kind: predictFiles}
+	default:
+		return prediction{kind: predictDynamic}
+	}
+}
+
+// staticPrediction returns a prediction for the given values. If there are no
+// values, it predicts nothing.
+func staticPrediction(values []string) prediction {
+	if len(values) == 0 {
+		return prediction{kind: predictNone}
+	}
+	return prediction{kind: predictStatic, values: values}
+}
+
+// walk calls fn for the node and all of its descendants, depth-first.
+func (n *completionNode) walk(fn func(n *completionNode)) {
+	fn(n)
+	for _, sub := range n.subs {
+		sub.walk(fn)
+	}
+}
+
+// subNames returns the names of the direct subcommands.
+func (n *completionNode) subNames() []string {
+	names := make([]string, 0, len(n.subs))
+	for _, sub := range n.subs {
+		names = append(names, sub.name)
+	}
+	return names
+}
+
+// flagNames returns all dash-prefixed flag names, including aliases.
+func (n *completionNode) flagNames() []string {
+	names := make([]string, 0, len(n.flags))
+	for _, f := range n.flags {
+		names = append(names, f.names...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// joinCompletionPath appends the subcommand name to the path.
+func joinCompletionPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + " " + name
+}
+
+// completionFuncName returns a shell-safe function name derived from the
+// binary name.
+func completionFuncName(name string) string {
+	return "_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// bashCompletion generates a bash completion script.
+func bashCompletion(name string, root *completionNode) string {
+	fn := completionFuncName(name)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", name)
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "# To load completions in the current shell session:\n")
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "#     source <(%s completion bash)\n", name)
+	fmt.Fprintf(&b, "\n")
+
+	fmt.Fprintf(&b, "%s_dynamic() {\n", fn)
+	fmt.Fprintf(&b, "    COMPREPLY=($(COMP_LINE=\"${COMP_LINE}\" COMP_POINT=\"${COMP_POINT}\" \"${COMP_WORDS[0]}\" 2>/dev/null))\n")
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintf(&b, "    local IFS=$'\\n'\n")
+	fmt.Fprintf(&b, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&b, "    local prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(&b, "    local cmd=\"\" word i\n")
+	fmt.Fprintf(&b, "    COMPREPLY=()\n\n")
+
+	// Resolve the current subcommand path.
+	fmt.Fprintf(&b, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(&b, "        word=\"${COMP_WORDS[i]}\"\n")
+	fmt.Fprintf(&b, "        case \"${cmd}:${word}\" in\n")
+	if patterns := subcommandPatterns(root, bashQuote); len(patterns) > 0 {
+		fmt.Fprintf(&b, "            %s) cmd=\"${cmd:+${cmd} }${word}\" ;;\n", strings.Join(patterns, "|"))
+	}
+	fmt.Fprintf(&b, "        esac\n")
+	fmt.Fprintf(&b, "    done\n\n")
+
+	bashReply := func(p prediction) string {
+		switch p.kind {
+		case predictStatic:
+			return fmt.Sprintf("COMPREPLY=($(compgen -W %s -- \"${cur}\"))", bashWordList(p.values))
+		case predictFiles:
+			return "compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -f -- \"${cur}\"))"
+		case predictDynamic:
+			return fn + "_dynamic"
+		case predictNone:
+		}
+		return ":"
+	}
+
+	// Complete flag values.
+	fmt.Fprintf(&b, "    case \"${cmd}:${prev}\" in\n")
+	root.walk(func(n *completionNode) {
+		for _, f := range n.flags {
+			if f.isBool {
+				continue
+			}
+
+			patterns := make([]string, 0, len(f.names))
+			for _, name := range f.names {
+				patterns = append(patterns, bashQuote(n.path+":"+name))
+			}
+			fmt.Fprintf(&b, "        %s)\n", strings.Join(patterns, "|"))
+			fmt.Fprintf(&b, "            %s\n", bashReply(f.predict))
+			fmt.Fprintf(&b, "            return ;;\n")
+		}
+	})
+	fmt.Fprintf(&b, "    esac\n\n")
+
+	// Complete flags, subcommands, and arguments.
+	fmt.Fprintf(&b, "    case \"${cmd}\" in\n")
+	root.walk(func(n *completionNode) {
+		fmt.Fprintf(&b, "        %s)\n", bashQuote(n.path))
+		fmt.Fprintf(&b, "            if [[ \"${cur}\" == -* ]]; then\n")
+		fmt.Fprintf(&b, "                %s\n", bashReply(staticPrediction(n.flagNames())))
+		fmt.Fprintf(&b, "            else\n")
+		if len(n.subs) > 0 {
+			fmt.Fprintf(&b, "                %s\n", bashReply(staticPrediction(n.subNames())))
+		} else {
+			fmt.Fprintf(&b, "                %s\n", bashReply(n.args))
+		}
+		fmt.Fprintf(&b, "            fi\n")
+		fmt.Fprintf(&b, "            ;;\n")
+	})
+	fmt.Fprintf(&b, "    esac\n")
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "complete -F %s %s", fn, bashQuote(name))
+	return b.String()
+}
+
+// zshCompletion generates a zsh completion script.
+func zshCompletion(name string, root *completionNode) string {
+	fn := completionFuncName(name)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n", name)
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "# zsh completion for %s\n", name)
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "# To load completions in the current shell session:\n")
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "#     source <(%s completion zsh)\n", name)
+	fmt.Fprintf(&b, "\n")
+
+	fmt.Fprintf(&b, "%s_dynamic() {\n", fn)
+	fmt.Fprintf(&b, "    local line=\"${(j: :)words[1,CURRENT]}\"\n")
+	fmt.Fprintf(&b, "    local -a opts\n")
+	fmt.Fprintf(&b, "    opts=(\"${(@f)$(COMP_LINE=\"${line}\" COMP_POINT=\"${#line}\" \"${words[1]}\" 2>/dev/null)}\")\n")
+	fmt.Fprintf(&b, "    opts=(\"${(@)opts:#}\")\n")
+	fmt.Fprintf(&b, "    compadd -- \"${opts[@]}\"\n")
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintf(&b, "    local cur=\"${words[CURRENT]}\"\n")
+	fmt.Fprintf(&b, "    local prev=\"${words[CURRENT-1]}\"\n")
+	fmt.Fprintf(&b, "    local cmd=\"\" word i\n\n")
+
+	// Resolve the current subcommand path.
+	fmt.Fprintf(&b, "    for ((i = 2; i < CURRENT; i++)); do\n")
+	fmt.Fprintf(&b, "        word=\"${words[i]}\"\n")
+	fmt.Fprintf(&b, "        case \"${cmd}:${word}\" in\n")
+	if patterns := subcommandPatterns(root, bashQuote); len(patterns) > 0 {
+		fmt.Fprintf(&b, "            (%s) cmd=\"${cmd:+${cmd} }${word}\" ;;\n", strings.Join(patterns, "|"))
+	}
+	fmt.Fprintf(&b, "        esac\n")
+	fmt.Fprintf(&b, "    done\n\n")
+
+	zshReply := func(p prediction) string {
+		switch p.kind {
+		case predictStatic:
+			quoted := make([]string, 0, len(p.values))
+			for _, v := range p.values {
+				quoted = append(quoted, bashQuote(v))
+			}
+			return "compadd -- " + strings.Join(quoted, " ")
+		case predictFiles:
+			return "_files"
+		case predictDynamic:
+			return fn + "_dynamic"
+		case predictNone:
+		}
+		return ":"
+	}
+
+	// Complete flag values.
+	fmt.Fprintf(&b, "    case \"${cmd}:${prev}\" in\n")
+	root.walk(func(n *completionNode) {
+		for _, f := range n.flags {
+			if f.isBool {
+				continue
+			}
+
+			patterns := make([]string, 0, len(f.names))
+			for _, name := range f.names {
+				patterns = append(patterns, bashQuote(n.path+":"+name))
+			}
+			fmt.Fprintf(&b, "        (%s)\n", strings.Join(patterns, "|"))
+			fmt.Fprintf(&b, "            %s\n", zshReply(f.predict))
+			fmt.Fprintf(&b, "            return ;;\n")
+		}
+	})
+	fmt.Fprintf(&b, "    esac\n\n")
+
+	// Complete flags, subcommands, and arguments.
+	fmt.Fprintf(&b, "    case \"${cmd}\" in\n")
+	root.walk(func(n *completionNode) {
+		fmt.Fprintf(&b, "        (%s)\n", bashQuote(n.path))
+		fmt.Fprintf(&b, "            if [[ \"${cur}\" == -* ]]; then\n")
+		fmt.Fprintf(&b, "                %s\n", zshReply(staticPrediction(n.flagNames())))
+		fmt.Fprintf(&b, "            else\n")
+		if len(n.subs) > 0 {
+			fmt.Fprintf(&b, "                %s\n", zshReply(staticPrediction(n.subNames())))
+		} else {
+			fmt.Fprintf(&b, "                %s\n", zshReply(n.args))
+		}
+		fmt.Fprintf(&b, "            fi\n")
+		fmt.Fprintf(&b, "            ;;\n")
+	})
+	fmt.Fprintf(&b, "    esac\n")
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "if [[ \"${funcstack[1]}\" == %s ]]; then\n", bashQuote(fn))
+	fmt.Fprintf(&b, "    %s \"$@\"\n", fn)
+	fmt.Fprintf(&b, "else\n")
+	fmt.Fprintf(&b, "    compdef %s %s\n", fn, bashQuote(name))
+	fmt.Fprintf(&b, "fi")
+	return b.String()
+}
+
+// fishCompletion generates a fish completion script.
+func fishCompletion(name string, root *completionNode) string {
+	fn := completionFuncName(name)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", name)
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "# To load completions in the current shell session:\n")
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "#     %s completion fish | source\n", name)
+	fmt.Fprintf(&b, "\n")
+
+	// Resolve the current subcommand path.
+	fmt.Fprintf(&b, "function %s_cmd\n", fn)
+	fmt.Fprintf(&b, "    set -l cmd ''\n")
+	fmt.Fprintf(&b, "    for word in (commandline -opc)[2..-1]\n")
+	fmt.Fprintf(&b, "        switch \"$cmd:$word\"\n")
+	if patterns := subcommandPatterns(root, fishQuote); len(patterns) > 0 {
+		fmt.Fprintf(&b, "            case %s\n", strings.Join(patterns, " "))
+		fmt.Fprintf(&b, "                set cmd (string trim -- \"$cmd $word\")\n")
+	}
+	fmt.Fprintf(&b, "        end\n")
+	fmt.Fprintf(&b, "    end\n")
+	fmt.Fprintf(&b, "    printf '%%s\\n' $cmd\n")
+	fmt.Fprintf(&b, "end\n\n")
+
+	fmt.Fprintf(&b, "function %s_using\n", fn)
+	fmt.Fprintf(&b, "    set -l cmd (%s_cmd)\n", fn)
+	fmt.Fprintf(&b, "    test \"$cmd\" = \"$argv[1]\"\n")
+	fmt.Fprintf(&b, "end\n\n")
+
+	fmt.Fprintf(&b, "function %s_dynamic\n", fn)
+	fmt.Fprintf(&b, "    set -l line (commandline -cp)\n")
+	fmt.Fprintf(&b, "    env COMP_LINE=\"$line\" COMP_POINT=(string length -- \"$line\") (commandline -opc)[1] 2>/dev/null\n")
+	fmt.Fprintf(&b, "end\n\n")
+
+	fishArgs := func(p prediction) string {
+		switch p.kind {
+		case predictStatic:
+			escaped := make([]string, 0, len(p.values))
+			for _, v := range p.values {
+				escaped = append(escaped, shellEscape(v))
+			}
+			return " -a " + fishQuote(strings.Join(escaped, " "))
+		case predictFiles:
+			return " -F"
+		case predictDynamic:
+			return " -a " + fishQuote("("+fn+"_dynamic)")
+		case predictNone:
+		}
+		return ""
+	}
+
+	quotedName := fishQuote(name)
+	fmt.Fprintf(&b, "complete -c %s -f\n", quotedName)
+	root.walk(func(n *completionNode) {
+		cond := fishQuote(fn + "_using " + fishQuote(n.path))
+
+		for _, sub := range n.subs {
+			fmt.Fprintf(&b, "complete -c %s -n %s -a %s", quotedName, cond, fishQuote(shellEscape(sub.name)))
+			if sub.desc != "" {
+				fmt.Fprintf(&b, " -d %s", fishQuote(sub.desc))
+			}
+			fmt.Fprintf(&b, "\n")
+		}
+
+		for _, f := range n.flags {
+			fmt.Fprintf(&b, "complete -c %s -n %s", quotedName, cond)
+			for _, name := range f.names {
+				// Long options with two dashes are "-l", and options with a single
+				// dash, including old-style long options, are "-o".
+				if long, ok := strings.CutPrefix(name, "--"); ok {
+					fmt.Fprintf(&b, " -l %s", fishQuote(long))
+				} else {
+					fmt.Fprintf(&b, " -o %s", fishQuote(strings.TrimPrefix(name, "-")))
+				}
+			}
+			if !f.isBool {
+				if f.predict.kind == predictFiles {
+					fmt.Fprintf(&b, " -r")
+				} else {
+					fmt.Fprintf(&b, " -x")
+				}
+				fmt.Fprintf(&b, "%s", fishArgs(f.predict))
+			}
+			fmt.Fprintf(&b, "\n")
+		}
+
+		if len(n.subs) == 0 {
+			if v := fishArgs(n.args); v != "" {
+				fmt.Fprintf(&b, "complete -c %s -n %s%s\n", quotedName, cond, v)
+			}
+		}
+	})
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// subcommandPatterns returns the list of "parent:child" case patterns for
+// every subcommand in the tree, quoted with the given function.
+func subcommandPatterns(root *completionNode, quote func(string) string) []string {
+	var patterns []string
+	root.walk(func(n *completionNode) {
+		for _, sub := range n.subs {
+			patterns = append(patterns, quote(n.path+":"+sub.name))
+		}
+	})
+	return patterns
+}
+
+// bashQuote single-quotes the string for bash and zsh.
+func bashQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// bashWordList returns an ANSI-C quoted, newline-separated list of words for
+// use with "compgen -W" when IFS is a newline. Since compgen expands the word
+// list, each word is escaped first.
+func bashWordList(words []string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+
+	escaped := make([]string, 0, len(words))
+	for _, w := range words {
+		escaped = append(escaped, r.Replace(shellEscape(w)))
+	}
+	return "$'" + strings.Join(escaped, `\n`) + "'"
+}
+
+// fishQuote single-quotes the string for fish.
+func fishQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// shellEscape backslash-escapes characters that the shell would otherwise
+// interpret when expanding a word, such as the word lists given to
+// "compgen -W" in bash or "complete -a" in fish.
+func shellEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(" \t'\"\\$`!*?~#()[]{}<>&|;", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
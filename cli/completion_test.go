@@ -0,0 +1,401 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestCompletionCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		args     []string
+		err      string
+		contains []string
+	}{
+		{
+			name: "no_args",
+			args: nil,
+			err:  "expected 1 argument, got 0",
+		},
+		{
+			name: "unknown_shell",
+			args: []string{"powershell"},
+			err:  `unsupported shell "powershell"`,
+		},
+		{
+			name: "bash",
+			args: []string{"bash"},
+			contains: []string{
+				`':sing'|':transport'|'transport:bus')`,
+				`'sing:-s'|'sing:-song')`,
+				`compgen -W $'Happy\\ Birthday\nit\\\'s'`,
+				`compgen -f -- "${cur}"`,
+				`_my_tool_dynamic`,
+				`complete -F _my_tool 'my-tool'`,
+			},
+		},
+		{
+			name: "zsh",
+			args: []string{"zsh"},
+			contains: []string{
+				`#compdef my-tool`,
+				`('sing:-s'|'sing:-song')`,
+				`compadd -- 'Happy Birthday' 'it'\''s'`,
+				`_files`,
+				`compdef _my_tool 'my-tool'`,
+			},
+		},
+		{
+			name: "fish",
+			args: []string{"fish"},
+			contains: []string{
+				`complete -c 'my-tool' -n '_my_tool_using \'\'' -a 'transport' -d 'Transportation'`,
+				`complete -c 'my-tool' -n '_my_tool_using \'sing\'' -o 'loud'`,
+				`-o 's' -o 'song' -x -a 'Happy\\ Birthday it\\\'s'`,
+				`-o 'file' -r -F`,
+				`-o 'now' -x -a '(_my_tool_dynamic)'`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := &RootCommand{
+				Name: "my-tool",
+				Commands: map[string]CommandFactory{
+					"sing": func() Command {
+						return &completionTestCommand{}
+					},
+					"hidden": func() Command {
+						return &TestCommand{Hide: true}
+					},
+					"transport": func() Command {
+						return &RootCommand{
+							Name:        "transport",
+							Description: "Transportation",
+							Commands: map[string]CommandFactory{
+								"bus": func() Command {
+									return &completionTestCommand{}
+								},
+							},
+						}
+					},
+				},
+			}
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Run(t.Context(), append([]string{"completion"}, tc.args...))
+			if diff := testutil.DiffErrString(err, tc.err); diff != "" {
+				t.Fatal(diff)
+			}
+
+			got := stdout.String()
+			for _, want := range tc.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected\n\n%s\n\nto contain %q", got, want)
+				}
+			}
+
+			for _, notWant := range []string{"hidden", "secret"} {
+				if strings.Contains(got, notWant) {
+					t.Errorf("expected\n\n%s\n\nto not contain %q", got, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestCompletionCommand_posix(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		shell    string
+		contains []string
+	}{
+		{
+			shell: "bash",
+			contains: []string{
+				`'sing:--song'|'sing:-s')`,
+				`compgen -W $'--file\n--loud\n--now\n--song\n-s'`,
+			},
+		},
+		{
+			shell: "zsh",
+			contains: []string{
+				`('sing:--song'|'sing:-s')`,
+				`compadd -- '--file' '--loud' '--now' '--song' '-s'`,
+			},
+		},
+		{
+			shell: "fish",
+			contains: []string{
+				`-l 'song' -o 's' -x`,
+				`-l 'file' -r -F`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.shell, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := &RootCommand{
+				Name: "my-tool",
+				Commands: map[string]CommandFactory{
+					"sing": func() Command {
+						return &completionTestCommand{posix: true}
+					},
+				},
+			}
+			_, stdout, _ := cmd.Pipe()
+
+			if err := cmd.Run(t.Context(), []string{"completion", tc.shell}); err != nil {
+				t.Fatal(err)
+			}
+
+			got := stdout.String()
+			for _, want := range tc.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected\n\n%s\n\nto contain %q", got, want)
+				}
+			}
+			if strings.Contains(got, "'-song'") || strings.Contains(got, ":-song'") {
+				t.Errorf("expected\n\n%s\n\nto not contain single-dash long flags", got)
+			}
+		})
+	}
+}
+
+func TestCompletionCommand_NestedRoot(t *testing.T) {
+	t.Parallel()
+
+	cmd := &RootCommand{
+		Name: "my-tool",
+		Commands: map[string]CommandFactory{
+			"transport": func() Command {
+				return &RootCommand{
+					Name: "transport",
+					Commands: map[string]CommandFactory{
+						"bus": func() Command {
+							return &completionTestCommand{}
+						},
+					},
+				}
+			},
+		},
+	}
+	cmd.Pipe()
+
+	err := cmd.Run(t.Context(), []string{"transport", "completion", "bash"})
+	if diff := testutil.DiffErrString(err, `unknown command "completion"`); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestBashCompletion_Replies(t *testing.T) {
+	t.Parallel()
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	cmd := &RootCommand{
+		Name: "my-tool",
+		Commands: map[string]CommandFactory{
+			"sing": func() Command {
+				return &completionTestCommand{}
+			},
+			"hidden": func() Command {
+				return &TestCommand{Hide: true}
+			},
+			"transport": func() Command {
+				return &RootCommand{
+					Name:        "transport",
+					Description: "Transportation",
+					Commands: map[string]CommandFactory{
+						"bus": func() Command {
+							return &completionTestCommand{}
+						},
+					},
+				}
+			},
+		},
+	}
+	_, stdout, _ := cmd.Pipe()
+	if err := cmd.Run(t.Context(), []string{"completion", "bash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	script := filepath.Join(t.TempDir(), "completion.bash")
+	if err := os.WriteFile(script, stdout.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		words []string
+		exp   []string
+	}{
+		{
+			name:  "top_level",
+			words: []string{"my-tool", ""},
+			exp:   []string{"sing", "transport"},
+		},
+		{
+			name:  "top_level_prefix",
+			words: []string{"my-tool", "tr"},
+			exp:   []string{"transport"},
+		},
+		{
+			name:  "nested",
+			words: []string{"my-tool", "transport", ""},
+			exp:   []string{"bus"},
+		},
+		{
+			name:  "flags",
+			words: []string{"my-tool", "transport", "bus", "-"},
+			exp:   []string{"-file", "-loud", "-now", "-s", "-song"},
+		},
+		{
+			name:  "flag_values",
+			words: []string{"my-tool", "sing", "-s", ""},
+			exp:   []string{"Happy Birthday", "it's"},
+		},
+		{
+			name:  "flag_values_prefix",
+			words: []string{"my-tool", "sing", "-song", "Ha"},
+			exp:   []string{"Happy Birthday"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			driver := `
+source "$1"; shift
+COMP_WORDS=("$@")
+COMP_CWORD=$((${#COMP_WORDS[@]} - 1))
+_my_tool
+printf '%s\n' "${COMPREPLY[@]}"
+`
+			args := append([]string{"-c", driver, "bash", script}, tc.words...)
+			out, err := exec.CommandContext(t.Context(), bash, args...).CombinedOutput()
+			if err != nil {
+				t.Fatalf("failed to run bash: %s\n\n%s", err, out)
+			}
+
+			got := strings.Split(strings.TrimSpace(string(out)), "\n")
+			if diff := cmp.Diff(tc.exp, got); diff != "" {
+				t.Errorf("completions (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+type completionTestCommand struct {
+	BaseCommand
+
+	// posix enables GNU-style flags.
+	posix bool
+
+	flagSong   string
+	flagFile   string
+	flagNow    string
+	flagLoud   bool
+	flagSecret string
+}
+
+func (c *completionTestCommand) Desc() string {
+	return "Sing a song"
+}
+
+func (c *completionTestCommand) Help() string {
+	return "Usage: {{ COMMAND }}"
+}
+
+func (c *completionTestCommand) PredictArgs() complete.Predictor {
+	return predict.Files("*.mp3")
+}
+
+func (c *completionTestCommand) Flags() *FlagSet {
+	var opts []Option
+	if c.posix {
+		opts = append(opts, WithPOSIXFlags())
+	}
+	set := c.NewFlagSet(opts...)
+
+	f := set.NewSection("OPTIONS")
+
+	f.StringVar(&StringVar{
+		Name:    "song",
+		Aliases: []string{"s"},
+		Target:  &c.flagSong,
+		Predict: predict.Set{"Happy Birthday", "it's"},
+		Usage:   "Name of the song.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "file",
+		Target:  &c.flagFile,
+		Predict: predict.Files("*"),
+		Usage:   "Path to a file.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:   "now",
+		Target: &c.flagNow,
+		Predict: complete.PredictFunc(func(prefix string) []string {
+			return []string{"now"}
+		}),
+		Usage: "Current time.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:   "loud",
+		Target: &c.flagLoud,
+		Usage:  "Sing loudly.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:   "secret",
+		Target: &c.flagSecret,
+		Hidden: true,
+		Usage:  "Hidden flag.",
+	})
+
+	return set
+}
+
+func (c *completionTestCommand) Run(ctx context.Context, args []string) error {
+	return nil
+}
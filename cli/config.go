@@ -0,0 +1,507 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceKind is the kind of place from which a flag value was resolved.
+type sourceKind int
+
+const (
+	sourceDefault sourceKind = iota
+	sourceConfigFile
+	sourceEnv
+	sourceFlag
+)
+
+// flagSource describes where the current value of a flag was resolved from.
+type flagSource struct {
+	kind sourceKind
+
+	// detail is the environment variable name or config file path.
+	detail string
+}
+
+// String implements [fmt.Stringer].
+func (s flagSource) String() string {
+	switch s.kind {
+	case sourceConfigFile:
+		return "config file " + s.detail
+	case sourceEnv:
+		return "environment variable " + s.detail
+	case sourceFlag:
+		return "command line"
+	case sourceDefault:
+	}
+	return "default"
+}
+
+// sourcedValue is implemented by flag values which track where their current
+// value was resolved from.
+type sourcedValue interface {
+	source() flagSource
+}
+
+// DumpValues returns a human-readable listing of the current value of every
+// flag and where that value was resolved from, one flag per line. It is meant
+// for debugging how flag values were resolved, for example:
+//
+//	-project="my-project" (config file /home/user/.config/my-tool/config.yaml)
+//	-region="us-east1" (environment variable REGION)
+//	-verbose="true" (command line)
+func (f *FlagSet) DumpValues() string {
+	var b strings.Builder
+
+	for _, set := range append(append([]*FlagSection{}, f.sections...), f.inherited...) {
+		names := append([]string{}, set.flagNames...)
+		sort.Strings(names)
+
+		for _, name := range names {
+			fl := f.flagSet.Lookup(name)
+			if fl == nil {
+				continue
+			}
+
+			source := flagSource{kind: sourceDefault}
+			if sv, ok := fl.Value.(sourcedValue); ok {
+				source = sv.source()
+			}
+			fmt.Fprintf(&b, "-%s=%q (%s)\n", name, fl.Value.String(), source)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// configSet is the collection of config files which provide flag defaults. It
+// is shared by a [FlagSet] and all of its sections.
+type configSet struct {
+	// files are ordered from lowest to highest precedence.
+	files []*configFile
+
+	// err is the joined set of errors from loading config files and parsing
+	// config values. It is returned by [FlagSet.Parse].
+	err error
+}
+
+// configFile is a loaded config file.
+type configFile struct {
+	path   string
+	values map[string]*configValue
+}
+
+// configValue is a value in a config file, converted to the string form used by
+// flag parsers.
+type configValue struct {
+	value string
+
+	// isList is true if the value is a list, whose elements are joined with
+	// commas in value.
+	isList bool
+}
+
+// lookup returns the value for the flag with the given names and the file which
+// provided it. The names are the flag name followed by its aliases, and the
+// first name present in a file is used. Later files take precedence over
+// earlier files.
+func (c *configSet) lookup(names ...string) (*configValue, *configFile, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+
+	for i := len(c.files) - 1; i >= 0; i-- {
+		for _, name := range names {
+			if v, ok := c.files[i].values[name]; ok {
+				return v, c.files[i], true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// WithConfigFiles loads flag defaults from the given config files. Files are
+// listed from lowest to highest precedence, typically the per-user file (see
+// [UserConfigFile]) followed by a project-local file. Files which do not exist
+// are skipped.
+//
+// Each top-level key in a config file is the name or an alias of a flag, and
+// its value uses the same syntax as the flag's environment variable. A list
+// sets multiple values of a slice or map flag, like repeating the flag on the
+// command line.
+//
+// The format is chosen by the file extension: ".yaml" or ".yml" for YAML,
+// ".json" for JSON, and ".toml" for TOML. Only a subset of TOML is supported:
+// top-level keys with string, integer, float, boolean, and single-line array
+// values. Tables, dotted keys, multi-line strings, and dates are not supported.
+//
+// Flag values are resolved in the following order, from highest to lowest
+// precedence: the command line, environment variables, config files, and the
+// default. Errors loading config files or parsing config values are returned
+// by [FlagSet.Parse].
+//
+//	set := cli.NewFlagSet(cli.WithConfigFiles(
+//		cli.UserConfigFile("my-tool"),
+//		".my-tool.yaml",
+//	))
+func WithConfigFiles(paths ...string) Option {
+	return func(fs *FlagSet) *FlagSet {
+		if fs.config == nil {
+			fs.config = &configSet{}
+		}
+
+		for _, pth := range paths {
+			if pth == "" {
+				continue
+			}
+
+			cf, err := loadConfigFile(pth)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					fs.config.err = errors.Join(fs.config.err,
+						fmt.Errorf("failed to load config file %s: %w", pth, err))
+				}
+				continue
+			}
+			fs.config.files = append(fs.config.files, cf)
+		}
+		return fs
+	}
+}
+
+// UserConfigFile returns the path to the per-user config file for the named
+// tool (e.g. "~/.config/my-tool/config.yaml" on Linux). It returns the empty
+// string if the user config directory cannot be determined.
+func UserConfigFile(tool string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, tool, "config.yaml")
+}
+
+// loadConfigFile reads and parses the config file at the given path.
+func loadConfigFile(pth string) (*configFile, error) {
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(pth)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(b, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse yaml: %w", err)
+		}
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to parse json: %w", err)
+		}
+	case ".toml":
+		raw, err = parseTOML(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse toml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q", ext)
+	}
+
+	cf := &configFile{
+		path:   pth,
+		values: make(map[string]*configValue, len(raw)),
+	}
+
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var merr error
+	for _, k := range keys {
+		v, err := configValueString(raw[k])
+		if err != nil {
+			merr = errors.Join(merr, fmt.Errorf("invalid value for %q: %w", k, err))
+			continue
+		}
+		_, isList := raw[k].([]any)
+		cf.values[k] = &configValue{value: v, isList: isList}
+	}
+	if merr != nil {
+		return nil, merr
+	}
+	return cf, nil
+}
+
+// configValueString converts a decoded config value into the string form used
+// by flag parsers. Lists are joined with commas, escaping any commas in their
+// elements.
+func configValueString(v any) (string, error) {
+	if list, ok := v.([]any); ok {
+		parts := make([]string, 0, len(list))
+		for _, elem := range list {
+			s, err := configScalarString(elem)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, strings.NewReplacer(`\`, `\\`, ",", `\,`).Replace(s))
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return configScalarString(v)
+}
+
+// configScalarString converts a single decoded config value into a string.
+func configScalarString(v any) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case int:
+		return strconv.Itoa(t), nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case uint64:
+		return strconv.FormatUint(t, 10), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case json.Number:
+		return t.String(), nil
+	case time.Time:
+		return t.Format(time.RFC3339Nano), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// parseTOML parses a flat subset of TOML consisting of top-level key/value
+// pairs, one per line. Keys are bare keys or quoted keys. Values are basic
+// strings (with TOML escape sequences), literal strings, integers (with
+// optional underscores and 0x, 0o, or 0b prefixes), floats, booleans, or
+// single-line arrays of those. Tables, dotted keys, inline tables, multi-line
+// strings and arrays, and dates are not supported.
+func parseTOML(s string) (map[string]any, error) {
+	result := make(map[string]any)
+
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported", i+1)
+		}
+
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+
+		key, err := parseTOMLKey(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if _, ok := result[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", i+1, key)
+		}
+
+		v, rest, err := parseTOMLValue(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("line %d: unexpected %q after value", i+1, rest)
+		}
+		result[key] = v
+	}
+
+	return result, nil
+}
+
+// parseTOMLValue parses a single TOML value from the start of s and returns the
+// value and the remaining unparsed input.
+func parseTOMLValue(s string) (any, string, error) {
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("missing value")
+	case s[0] == '"':
+		end := 1
+		for ; end < len(s); end++ {
+			if s[end] == '\\' {
+				end++
+				continue
+			}
+			if s[end] == '"' {
+				break
+			}
+		}
+		if end >= len(s) {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		v, err := unquoteTOML(s[1:end])
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid string %s: %w", s[:end+1], err)
+		}
+		return v, s[end+1:], nil
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case s[0] == '[':
+		list := make([]any, 0, 4)
+		rest := strings.TrimSpace(s[1:])
+		for {
+			if strings.HasPrefix(rest, "]") {
+				return list, rest[1:], nil
+			}
+
+			v, r, err := parseTOMLValue(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, ok := v.([]any); ok {
+				return nil, "", fmt.Errorf("nested arrays are not supported")
+			}
+			list = append(list, v)
+
+			rest = strings.TrimSpace(r)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", fmt.Errorf("unterminated array")
+			}
+		}
+	default:
+		end := strings.IndexAny(s, ",]# \t")
+		if end < 0 {
+			end = len(s)
+		}
+		token, rest := s[:end], s[end:]
+
+		switch token {
+		case "true":
+			return true, rest, nil
+		case "false":
+			return false, rest, nil
+		}
+
+		clean := strings.ReplaceAll(token, "_", "")
+		if v, err := strconv.ParseInt(clean, 0, 64); err == nil {
+			return v, rest, nil
+		}
+		if v, err := strconv.ParseFloat(clean, 64); err == nil {
+			return v, rest, nil
+		}
+		return nil, "", fmt.Errorf("invalid value %q", token)
+	}
+}
+
+// parseTOMLKey parses a bare or quoted TOML key.
+func parseTOMLKey(s string) (string, error) {
+	switch {
+	case s == "":
+		return "", fmt.Errorf("missing key")
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return unquoteTOML(s[1 : len(s)-1])
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return s[1 : len(s)-1], nil
+	}
+
+	for _, r := range s {
+		switch {
+		case r == '.':
+			return "", fmt.Errorf("dotted key %q is not supported", s)
+		case (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' && r != '-':
+			return "", fmt.Errorf("invalid key %q", s)
+		}
+	}
+	return s, nil
+}
+
+// unquoteTOML replaces the escape sequences in the contents of a TOML basic
+// string, which are \b, \t, \n, \f, \r, \", \\, \uXXXX, and \UXXXXXXXX.
+// Control characters other than tab must be escaped.
+func unquoteTOML(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			if (c < 0x20 && c != '\t') || c == 0x7f {
+				return "", fmt.Errorf("control character %q must be escaped", c)
+			}
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("incomplete escape sequence")
+		}
+		switch s[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"':
+			b.WriteByte('"')
+		case '\\':
+			b.WriteByte('\\')
+		case 'u', 'U':
+			size := 4
+			if s[i] == 'U' {
+				size = 8
+			}
+			if i+size >= len(s) {
+				return "", fmt.Errorf("incomplete escape sequence \\%s", s[i:])
+			}
+			n, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(n)) {
+				return "", fmt.Errorf("invalid escape sequence \\%s", s[i:i+1+size])
+			}
+			b.WriteRune(rune(n))
+			i += size
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,491 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+type configTestFlags struct {
+	project string
+	region  string
+	count   int
+	zones   []string
+	labels  map[string]string
+	verbose bool
+}
+
+func writeConfigFile(tb testing.TB, dir, name, contents string) string {
+	tb.Helper()
+
+	pth := filepath.Join(dir, name)
+	if err := os.WriteFile(pth, []byte(contents), 0o600); err != nil {
+		tb.Fatal(err)
+	}
+	return pth
+}
+
+func TestWithConfigFiles(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		files map[string]string
+		env   map[string]string
+		args  []string
+
+		want      *configTestFlags
+		wantError string
+	}{
+		{
+			name: "no_files",
+			want: &configTestFlags{
+				project: "default-project",
+				region:  "default-region",
+			},
+		},
+		{
+			name: "user_yaml",
+			files: map[string]string{
+				"user.yaml": "project: user-project\ncount: 3\nzones: [a, 'b,c']\nverbose: true\n",
+			},
+			want: &configTestFlags{
+				project: "user-project",
+				region:  "default-region",
+				count:   3,
+				zones:   []string{"a", "b,c"},
+				verbose: true,
+			},
+		},
+		{
+			name: "project_overrides_user",
+			files: map[string]string{
+				"user.yaml":    "project: user-project\nregion: user-region\n",
+				"project.json": `{"region": "project-region", "count": 7}`,
+			},
+			want: &configTestFlags{
+				project: "user-project",
+				region:  "project-region",
+				count:   7,
+			},
+		},
+		{
+			name: "env_overrides_files",
+			files: map[string]string{
+				"user.yaml":    "project: user-project\n",
+				"project.toml": "project = \"project-project\"\n",
+			},
+			env: map[string]string{"PROJECT": "env-project"},
+			want: &configTestFlags{
+				project: "env-project",
+				region:  "default-region",
+			},
+		},
+		{
+			name: "flag_overrides_all",
+			files: map[string]string{
+				"project.toml": "project = \"project-project\"\nzones = [\"a\", \"b\"]\n",
+			},
+			env:  map[string]string{"PROJECT": "env-project"},
+			args: []string{"-project", "flag-project", "-zones", "c"},
+			want: &configTestFlags{
+				project: "flag-project",
+				region:  "default-region",
+				zones:   []string{"c"},
+			},
+		},
+		{
+			name: "map_from_file",
+			files: map[string]string{
+				"user.yaml": "labels: [a=1, 'b=2,3']\n",
+			},
+			want: &configTestFlags{
+				project: "default-project",
+				region:  "default-region",
+				labels:  map[string]string{"a": "1", "b": "2,3"},
+			},
+		},
+		{
+			name: "map_from_env_single_entry",
+			env:  map[string]string{"LABELS": "a=b,c"},
+			want: &configTestFlags{
+				project: "default-project",
+				region:  "default-region",
+				labels:  map[string]string{"a": "b,c"},
+			},
+		},
+		{
+			name: "map_from_file_scalar",
+			files: map[string]string{
+				"user.yaml": "labels: a=b,c\n",
+			},
+			want: &configTestFlags{
+				project: "default-project",
+				region:  "default-region",
+				labels:  map[string]string{"a": "b,c"},
+			},
+		},
+		{
+			name: "map_flag_overrides_file",
+			files: map[string]string{
+				"user.yaml": "labels: [a=1, b=2]\n",
+			},
+			args: []string{"-labels", "c=3", "-labels", "d=4"},
+			want: &configTestFlags{
+				project: "default-project",
+				region:  "default-region",
+				labels:  map[string]string{"c": "3", "d": "4"},
+			},
+		},
+		{
+			name: "alias",
+			files: map[string]string{
+				"user.yaml": "r: alias-region\n",
+			},
+			want: &configTestFlags{
+				project: "default-project",
+				region:  "alias-region",
+			},
+		},
+		{
+			name: "name_overrides_alias",
+			files: map[string]string{
+				"user.yaml": "r: alias-region\nregion: name-region\n",
+			},
+			want: &configTestFlags{
+				project: "default-project",
+				region:  "name-region",
+			},
+		},
+		{
+			name: "unknown_keys_ignored",
+			files: map[string]string{
+				"user.yaml": "other-command-flag: true\n",
+			},
+			want: &configTestFlags{
+				project: "default-project",
+				region:  "default-region",
+			},
+		},
+		{
+			name: "invalid_value",
+			files: map[string]string{
+				"user.yaml": "count: banana\n",
+			},
+			wantError: `invalid value "banana" for flag -count from config file`,
+		},
+		{
+			name: "invalid_file",
+			files: map[string]string{
+				"user.yaml": "project: [\n",
+			},
+			wantError: "failed to parse yaml",
+		},
+		{
+			name: "nested_value",
+			files: map[string]string{
+				"project.json": `{"project": {"id": "foo"}}`,
+			},
+			wantError: `invalid value for "project": unsupported value type`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			// Files are always passed user first, then project. Missing files are
+			// skipped.
+			var paths []string
+			for _, name := range []string{"user.yaml", "project.json", "project.toml"} {
+				pth := filepath.Join(dir, name)
+				if contents, ok := tc.files[name]; ok {
+					writeConfigFile(t, dir, name, contents)
+				}
+				paths = append(paths, pth)
+			}
+
+			var got configTestFlags
+			set := NewFlagSet(
+				WithLookupEnv(MapLookuper(tc.env)),
+				WithConfigFiles(paths...))
+			f := set.NewSection("OPTIONS")
+			f.StringVar(&StringVar{
+				Name:    "project",
+				Default: "default-project",
+				EnvVar:  "PROJECT",
+				Target:  &got.project,
+				Usage:   "The project.",
+			})
+			f.StringVar(&StringVar{
+				Name:    "region",
+				Aliases: []string{"r"},
+				Default: "default-region",
+				Target:  &got.region,
+				Usage:   "The region.",
+			})
+			f.IntVar(&IntVar{
+				Name:   "count",
+				Target: &got.count,
+				Usage:  "The count.",
+			})
+			f.StringSliceVar(&StringSliceVar{
+				Name:   "zones",
+				Target: &got.zones,
+				Usage:  "The zones.",
+			})
+			f.StringMapVar(&StringMapVar{
+				Name:   "labels",
+				EnvVar: "LABELS",
+				Target: &got.labels,
+				Usage:  "The labels.",
+			})
+			f.BoolVar(&BoolVar{
+				Name:   "verbose",
+				Target: &got.verbose,
+				Usage:  "Be verbose.",
+			})
+
+			err := set.Parse(tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantError); diff != "" {
+				t.Fatal(diff)
+			}
+			if tc.want == nil {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want, &got,
+				cmp.AllowUnexported(configTestFlags{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("flags (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithConfigFiles_unsupportedExtension(t *testing.T) {
+	t.Parallel()
+
+	pth := writeConfigFile(t, t.TempDir(), "config.ini", "project=foo\n")
+
+	set := NewFlagSet(WithConfigFiles(pth))
+	set.NewSection("OPTIONS").StringVar(&StringVar{
+		Name:   "project",
+		Target: new(string),
+		Usage:  "The project.",
+	})
+	err := set.Parse(nil)
+	if diff := testutil.DiffErrString(err, `unsupported config file extension ".ini"`); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithConfigFiles_sources(t *testing.T) {
+	t.Parallel()
+
+	pth := writeConfigFile(t, t.TempDir(), "config.yaml", "project: file-project\nregion: file-region\n")
+
+	var got configTestFlags
+	set := NewFlagSet(
+		WithLookupEnv(MapLookuper(map[string]string{"PROJECT": "env-project"})),
+		WithConfigFiles(pth))
+	f := set.NewSection("OPTIONS")
+	f.StringVar(&StringVar{
+		Name:    "project",
+		Default: "default-project",
+		EnvVar:  "PROJECT",
+		Target:  &got.project,
+		Usage:   "The project.",
+	})
+	f.StringVar(&StringVar{
+		Name:    "region",
+		Aliases: []string{"r"},
+		Default: "default-region",
+		Target:  &got.region,
+		Usage:   "The region.",
+	})
+	f.IntVar(&IntVar{
+		Name:   "count",
+		Target: &got.count,
+		Usage:  "The count.",
+	})
+	f.StringSliceVar(&StringSliceVar{
+		Name:   "zones",
+		Target: &got.zones,
+		Usage:  "The zones.",
+	})
+	f.StringMapVar(&StringMapVar{
+		Name:   "labels",
+		EnvVar: "LABELS",
+		Target: &got.labels,
+		Usage:  "The labels.",
+	})
+	f.BoolVar(&BoolVar{
+		Name:   "verbose",
+		Target: &got.verbose,
+		Usage:  "Be verbose.",
+	})
+	set.MarkRequired("region")
+
+	if err := set.Parse([]string{"-verbose"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Help output is wrapped, so compare with normalized whitespace.
+	help := strings.Join(strings.Fields(set.Help()), " ")
+	for _, want := range []string{
+		`The current value "env-project" is from the environment variable PROJECT.`,
+		`The current value "file-region" is from the config file`,
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected\n\n%s\n\nto include %q", help, want)
+		}
+	}
+
+	want := strings.Join([]string{
+		`-count="0" (default)`,
+		`-labels="" (default)`,
+		`-project="env-project" (environment variable PROJECT)`,
+		`-region="file-region" (config file ` + pth + `)`,
+		`-verbose="true" (command line)`,
+		`-zones="" (default)`,
+	}, "\n")
+	if diff := cmp.Diff(want, set.DumpValues()); diff != "" {
+		t.Errorf("dump (-want, +got):\n%s", diff)
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		in        string
+		want      map[string]any
+		wantError string
+	}{
+		{
+			name: "values",
+			in: `
+# comment
+name = "hello \"world\"" # trailing comment
+escaped = "tab\there\u00e9\U0001F600"
+'literal-key' = 1
+literal = 'C:\path'
+"quoted-key" = true
+count = 1_000
+ratio = 0.5
+list = ["a", 'b', 3, ]
+empty = []
+`,
+			want: map[string]any{
+				"name":        `hello "world"`,
+				"escaped":     "tab\there\u00e9\U0001F600",
+				"literal-key": int64(1),
+				"literal":     `C:\path`,
+				"quoted-key":  true,
+				"count":       int64(1000),
+				"ratio":       0.5,
+				"list":        []any{"a", "b", int64(3)},
+				"empty":       []any{},
+			},
+		},
+		{
+			name:      "table",
+			in:        "[section]\nkey = 1\n",
+			wantError: "line 1: tables are not supported",
+		},
+		{
+			name:      "go_escape",
+			in:        `key = "\x41"`,
+			wantError: `invalid escape sequence \x`,
+		},
+		{
+			name:      "invalid_unicode_escape",
+			in:        `key = "\uD800"`,
+			wantError: `invalid escape sequence \uD800`,
+		},
+		{
+			name:      "short_unicode_escape",
+			in:        `key = "\u12"`,
+			wantError: "incomplete escape sequence",
+		},
+		{
+			name:      "dotted_key",
+			in:        "a.b = 1\n",
+			wantError: `line 1: dotted key "a.b" is not supported`,
+		},
+		{
+			name:      "invalid_key",
+			in:        "a b = 1\n",
+			wantError: `line 1: invalid key "a b"`,
+		},
+		{
+			name:      "missing_equals",
+			in:        "key\n",
+			wantError: "line 1: expected key = value",
+		},
+		{
+			name:      "duplicate",
+			in:        "key = 1\nkey = 2\n",
+			wantError: `line 2: duplicate key "key"`,
+		},
+		{
+			name:      "unterminated_string",
+			in:        `key = "foo`,
+			wantError: "unterminated string",
+		},
+		{
+			name:      "unterminated_array",
+			in:        `key = ["foo" "bar"]`,
+			wantError: "unterminated array",
+		},
+		{
+			name:      "invalid_value",
+			in:        `key = nope`,
+			wantError: `invalid value "nope"`,
+		},
+		{
+			name:      "trailing",
+			in:        `key = "a" "b"`,
+			wantError: `unexpected "\"b\"" after value`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseTOML(tc.in)
+			if diff := testutil.DiffErrString(err, tc.wantError); diff != "" {
+				t.Fatal(diff)
+			}
+			if tc.want != nil {
+				if diff := cmp.Diff(tc.want, got); diff != "" {
+					t.Errorf("toml (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// constraintKind is the type of rule enforced by a [flagConstraint].
+type constraintKind int
+
+const (
+	constraintRequired constraintKind = iota
+	constraintMutuallyExclusive
+	constraintOneRequired
+	constraintRequires
+)
+
+// flagConstraint is a declarative rule about which flags must or must not be
+// given together. Constraints are validated after parsing and rendered in help
+// output.
+type flagConstraint struct {
+	kind constraintKind
+
+	// names are the flags in the constraint. For [constraintRequires], the first
+	// name is the dependent flag and the remaining names are its dependencies.
+	names []string
+}
+
+// MarkRequired marks each of the named flags as required. A required flag must
+// be given on the command line, via its environment variable, or in a config
+// file.
+//
+//	set.MarkRequired("project", "region")
+func (f *FlagSet) MarkRequired(names ...string) {
+	for _, name := range names {
+		f.addConstraint(constraintRequired, name)
+	}
+}
+
+// MarkMutuallyExclusive marks the named flags as mutually exclusive. At most one
+// of the flags may be given on the command line. Values from environment
+// variables and config files are not considered, since a flag given on the
+// command line takes precedence over them.
+//
+//	set.MarkMutuallyExclusive("file", "url")
+func (f *FlagSet) MarkMutuallyExclusive(names ...string) {
+	f.addConstraint(constraintMutuallyExclusive, names...)
+}
+
+// MarkOneRequired marks the named flags as a group in which at least one flag
+// must be given. Combine with [FlagSet.MarkMutuallyExclusive] to require exactly
+// one of the flags.
+//
+//	set.MarkOneRequired("file", "url")
+func (f *FlagSet) MarkOneRequired(names ...string) {
+	f.addConstraint(constraintOneRequired, names...)
+}
+
+// MarkRequires declares that, when the named flag is given, all of the required
+// flags must also be given.
+//
+//	set.MarkRequires("tls-key", "tls-cert")
+func (f *FlagSet) MarkRequires(name string, requires ...string) {
+	f.addConstraint(constraintRequires, append([]string{name}, requires...)...)
+}
+
+// addConstraint adds a constraint for the given flag names. Flags do not need to
+// be defined yet, but they must be defined by the time the flags are parsed.
+func (f *FlagSet) addConstraint(kind constraintKind, names ...string) {
+	if len(names) == 0 {
+		panic("constraint requires at least one flag")
+	}
+	if kind != constraintRequired && len(names) < 2 {
+		panic(fmt.Sprintf("constraint on -%s requires at least two flags", names[0]))
+	}
+
+	f.constraints = append(f.constraints, &flagConstraint{
+		kind:  kind,
+		names: names,
+	})
+}
+
+// validateConstraints checks all declared constraints against the parsed flags
+// and returns the joined errors for any violated constraints.
+func (f *FlagSet) validateConstraints() error {
+	if len(f.constraints) == 0 {
+		return nil
+	}
+
+	// Aliases are registered as separate flags that share the same value, so
+	// track the values which were set rather than the names.
+	given := make(map[flag.Value]struct{})
+	f.flagSet.Visit(func(fl *flag.Flag) {
+		given[fl.Value] = struct{}{}
+	})
+
+	lookup := func(name string) *flag.Flag {
+		fl := f.flagSet.Lookup(name)
+		if fl == nil {
+			panic(fmt.Sprintf("constraint references undefined flag -%s", name))
+		}
+		return fl
+	}
+
+	// isGiven returns true if the flag was given on the command line.
+	isGiven := func(name string) bool {
+		_, ok := given[lookup(name).Value]
+		return ok
+	}
+
+	// isSet returns true if the flag was given on the command line, via its
+	// environment variable, or in a config file.
+	isSet := func(name string) bool {
+		fl := lookup(name)
+		if _, ok := given[fl.Value]; ok {
+			return true
+		}
+		sv, ok := fl.Value.(sourcedValue)
+		return ok && sv.source().kind != sourceDefault
+	}
+
+	var merr error
+	for _, c := range f.constraints {
+		check := isSet
+		if c.kind == constraintMutuallyExclusive {
+			check = isGiven
+		}
+
+		var set []string
+		for _, name := range c.names {
+			if check(name) {
+				set = append(set, name)
+			}
+		}
+
+		switch c.kind {
+		case constraintRequired:
+			if len(set) == 0 {
+				merr = errors.Join(merr, fmt.Errorf("missing required flag %s", f.dashedName(c.names[0])))
+			}
+		case constraintMutuallyExclusive:
+			if len(set) > 1 {
+				merr = errors.Join(merr, fmt.Errorf("only one of %s may be given, got %s",
+					f.dashedList(c.names, "or"), f.dashedList(set, "and")))
+			}
+		case constraintOneRequired:
+			if len(set) == 0 {
+				merr = errors.Join(merr, fmt.Errorf("one of %s is required",
+					f.dashedList(c.names, "or")))
+			}
+		case constraintRequires:
+			if len(set) > 0 && set[0] == c.names[0] {
+				var missing []string
+				for _, name := range c.names[1:] {
+					if !isSet(name) {
+						missing = append(missing, name)
+					}
+				}
+				if len(missing) > 0 {
+					merr = errors.Join(merr, fmt.Errorf("%s requires %s to also be given",
+						f.dashedName(c.names[0]), f.dashedList(missing, "and")))
+				}
+			}
+		}
+	}
+	return merr
+}
+
+// constraintsHelp returns the human-readable description of all declared
+// constraints, one per line, or the empty string if there are none.
+func (f *FlagSet) constraintsHelp() string {
+	lines := make([]string, 0, len(f.constraints))
+	for _, c := range f.constraints {
+		var line string
+		switch c.kind {
+		case constraintRequired:
+			line = fmt.Sprintf("%s is required.", f.dashedName(c.names[0]))
+		case constraintMutuallyExclusive:
+			line = fmt.Sprintf("Only one of %s may be given.", f.dashedList(c.names, "or"))
+		case constraintOneRequired:
+			line = fmt.Sprintf("One of %s is required.", f.dashedList(c.names, "or"))
+		case constraintRequires:
+			line = fmt.Sprintf("%s requires %s.", f.dashedName(c.names[0]), f.dashedList(c.names[1:], "and"))
+		}
+		lines = append(lines, wrapAtLengthWithPadding(line, f.lineLength(), 4))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dashedList returns the flag names as a human-readable list with dashes (e.g.
+// "-a, -b, or -c").
+func (f *FlagSet) dashedList(names []string, conjunction string) string {
+	dashed := make([]string, 0, len(names))
+	for _, name := range names {
+		dashed = append(dashed, f.dashedName(name))
+	}
+	return joinWithConjunction(dashed, conjunction)
+}
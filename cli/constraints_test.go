@@ -0,0 +1,268 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestFlagSet_constraints(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		env       map[string]string
+		setup     func(set *FlagSet)
+		args      []string
+		wantError string
+	}{
+		{
+			name:  "required_given",
+			setup: func(set *FlagSet) { set.MarkRequired("project", "region") },
+			args:  []string{"-project", "p", "-region", "r"},
+		},
+		{
+			name:      "required_missing",
+			setup:     func(set *FlagSet) { set.MarkRequired("project", "region") },
+			args:      []string{"-project", "p"},
+			wantError: "missing required flag -region",
+		},
+		{
+			name:  "required_from_env",
+			env:   map[string]string{"REGION": "us-east1"},
+			setup: func(set *FlagSet) { set.MarkRequired("region") },
+		},
+		{
+			name:  "required_alias",
+			setup: func(set *FlagSet) { set.MarkRequired("file") },
+			args:  []string{"-f", "foo.txt"},
+		},
+		{
+			name:  "mutually_exclusive_one",
+			setup: func(set *FlagSet) { set.MarkMutuallyExclusive("file", "url", "project") },
+			args:  []string{"-url", "https://example.com"},
+		},
+		{
+			name:  "mutually_exclusive_none",
+			setup: func(set *FlagSet) { set.MarkMutuallyExclusive("file", "url") },
+		},
+		{
+			name:      "mutually_exclusive_many",
+			setup:     func(set *FlagSet) { set.MarkMutuallyExclusive("file", "url", "project") },
+			args:      []string{"-f", "foo.txt", "-project", "p"},
+			wantError: "only one of -file, -url, or -project may be given, got -file and -project",
+		},
+		{
+			name:  "mutually_exclusive_env_not_given",
+			env:   map[string]string{"REGION": "us-east1"},
+			setup: func(set *FlagSet) { set.MarkMutuallyExclusive("region", "url") },
+			args:  []string{"-url", "https://example.com"},
+		},
+		{
+			name:  "one_required_from_env",
+			env:   map[string]string{"REGION": "us-east1"},
+			setup: func(set *FlagSet) { set.MarkOneRequired("region", "url") },
+		},
+		{
+			name:  "one_required_given",
+			setup: func(set *FlagSet) { set.MarkOneRequired("file", "url") },
+			args:  []string{"-file", "foo.txt"},
+		},
+		{
+			name:      "one_required_missing",
+			setup:     func(set *FlagSet) { set.MarkOneRequired("file", "url") },
+			wantError: "one of -file or -url is required",
+		},
+		{
+			name:  "requires_given",
+			setup: func(set *FlagSet) { set.MarkRequires("project", "region", "url") },
+			args:  []string{"-project", "p", "-region", "r", "-url", "u"},
+		},
+		{
+			name:  "requires_not_triggered",
+			setup: func(set *FlagSet) { set.MarkRequires("project", "region") },
+			args:  []string{"-url", "u"},
+		},
+		{
+			name:      "requires_missing",
+			setup:     func(set *FlagSet) { set.MarkRequires("project", "region", "url") },
+			args:      []string{"-project", "p"},
+			wantError: "-project requires -region and -url to also be given",
+		},
+		{
+			name: "joined",
+			setup: func(set *FlagSet) {
+				set.MarkRequired("project")
+				set.MarkOneRequired("file", "url")
+			},
+			args:      []string{"-region", "r", "arg"},
+			wantError: "missing required flag -project\none of -file or -url is required",
+		},
+		{
+			name:      "with_parse_error",
+			setup:     func(set *FlagSet) { set.MarkRequired("project") },
+			args:      []string{"-nope"},
+			wantError: "flag provided but not defined: -nope\nmissing required flag -project",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			set := NewFlagSet(WithLookupEnv(MapLookuper(tc.env)))
+			f := set.NewSection("OPTIONS")
+			f.StringVar(&StringVar{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Target:  new(string),
+				Usage:   "The file.",
+			})
+			f.StringVar(&StringVar{
+				Name:   "url",
+				Target: new(string),
+				Usage:  "The url.",
+			})
+			f.StringVar(&StringVar{
+				Name:   "project",
+				Target: new(string),
+				Usage:  "The project.",
+			})
+			f.StringVar(&StringVar{
+				Name:   "region",
+				EnvVar: "REGION",
+				Target: new(string),
+				Usage:  "The region.",
+			})
+			tc.setup(set)
+
+			err := set.Parse(tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantError); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestFlagSet_constraints_afterParse(t *testing.T) {
+	t.Parallel()
+
+	set := NewFlagSet()
+	set.NewSection("OPTIONS").StringVar(&StringVar{
+		Name:   "project",
+		Target: new(string),
+		Usage:  "The project.",
+	})
+	set.MarkRequired("project")
+
+	var gotErr error
+	set.AfterParse(func(existingErr error) error {
+		gotErr = existingErr
+		return nil
+	})
+
+	_ = set.Parse(nil)
+	if diff := testutil.DiffErrString(gotErr, "missing required flag -project"); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestFlagSet_constraints_helpRequested(t *testing.T) {
+	t.Parallel()
+
+	set := NewFlagSet()
+	set.NewSection("OPTIONS").StringVar(&StringVar{
+		Name:   "project",
+		Target: new(string),
+		Usage:  "The project.",
+	})
+	set.MarkRequired("project")
+
+	err := set.Parse([]string{"-h"})
+	if got, want := fmt.Sprint(err), "flag: help requested"; got != want {
+		t.Errorf("expected error %q to be %q", got, want)
+	}
+}
+
+func TestFlagSet_constraints_help(t *testing.T) {
+	t.Parallel()
+
+	set := NewFlagSet()
+	f := set.NewSection("OPTIONS")
+	f.StringVar(&StringVar{
+		Name:    "file",
+		Aliases: []string{"f"},
+		Target:  new(string),
+		Usage:   "The file.",
+	})
+	f.StringVar(&StringVar{
+		Name:   "url",
+		Target: new(string),
+		Usage:  "The url.",
+	})
+	f.StringVar(&StringVar{
+		Name:   "project",
+		Target: new(string),
+		Usage:  "The project.",
+	})
+	f.StringVar(&StringVar{
+		Name:   "region",
+		EnvVar: "REGION",
+		Target: new(string),
+		Usage:  "The region.",
+	})
+	set.MarkRequired("project")
+	set.MarkMutuallyExclusive("file", "url")
+	set.MarkOneRequired("file", "url")
+	set.MarkRequires("region", "project")
+
+	want := `FLAG CONSTRAINTS
+
+    -project is required.
+    Only one of -file or -url may be given.
+    One of -file or -url is required.
+    -region requires -project.`
+	if got := set.Help(); !strings.HasSuffix(got, want) {
+		t.Errorf("expected\n\n%s\n\nto end with\n\n%s", got, want)
+	}
+
+	other := NewFlagSet()
+	other.NewSection("OPTIONS").StringVar(&StringVar{
+		Name:   "file",
+		Target: new(string),
+		Usage:  "The file.",
+	})
+	if got := other.Help(); strings.Contains(got, "CONSTRAINTS") {
+		t.Errorf("expected\n\n%s\n\nto not include constraints", got)
+	}
+}
+
+func TestFlagSet_constraints_undefined(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected panic for undefined flag")
+		}
+	}()
+
+	set := NewFlagSet()
+	set.MarkRequired("nope")
+	_ = set.Parse(nil)
+}
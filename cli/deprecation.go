@@ -0,0 +1,108 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Deprecation describes a deprecated command, flag, or alias. Deprecated names
+// are still accepted, but using them prints a warning to [Stderr] which points
+// to the replacement.
+type Deprecation struct {
+	// Replacement is the name of the command or flag which should be used
+	// instead. For deprecated aliases, it defaults to the name of the command or
+	// flag being aliased. Flag names should not include the leading dash.
+	Replacement string
+
+	// Message is additional information which is appended to the warning, such
+	// as when the deprecated name will be removed.
+	Message string
+
+	// HideAfter hides the deprecated name from help output and completions after
+	// the given time, while still accepting it. The zero value never hides the
+	// deprecated name.
+	HideAfter time.Time
+}
+
+// hidden returns true if the deprecation is past its grace period. It is safe to
+// call on a nil deprecation.
+func (d *Deprecation) hidden() bool {
+	return d != nil && !d.HideAfter.IsZero() && time.Now().After(d.HideAfter)
+}
+
+// describe returns the sentence describing the deprecation of the given
+// display name (e.g. `command "foo"` or "flag -foo"). The replacement is
+// rendered with the given function.
+func (d *Deprecation) describe(what, fallback string, quote func(string) string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s is deprecated", what)
+
+	replacement := d.Replacement
+	if replacement == "" {
+		replacement = fallback
+	}
+	if replacement != "" {
+		fmt.Fprintf(&b, ", use %s instead", quote(replacement))
+	}
+	b.WriteString(".")
+
+	if v := strings.TrimSpace(d.Message); v != "" {
+		b.WriteString(" ")
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// warnDeprecated prints the deprecation warning to w, using the style to
+// highlight the warning.
+func warnDeprecated(w io.Writer, style *Style, msg string) {
+	if w == nil {
+		return
+	}
+	fmt.Fprintf(w, "%s %s\n", style.Warning("WARNING:"), msg)
+}
+
+// dashed prefixes the flag name with a dash.
+func dashed(name string) string {
+	return "-" + name
+}
+
+// deprecatedFlagValue wraps a flag value registered under a deprecated name. It
+// warns when the flag is set on the command line using that name.
+type deprecatedFlagValue[T any] struct {
+	*flagValue[T]
+
+	name        string
+	deprecation *Deprecation
+	fallback    string
+	stderr      io.Writer
+	style       *Style
+}
+
+func (d *deprecatedFlagValue[T]) Set(s string) error {
+	if err := d.flagValue.Set(s); err != nil {
+		return err
+	}
+	warnDeprecated(d.stderr, d.style, d.deprecation.describe("flag -"+d.name, d.fallback, dashed))
+	return nil
+}
+
+func (d *deprecatedFlagValue[T]) Hidden() bool {
+	return d.flagValue.Hidden() || d.deprecation.hidden()
+}
@@ -0,0 +1,217 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeprecation_describe(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		dep      *Deprecation
+		fallback string
+		want     string
+	}{
+		{
+			name: "no_replacement",
+			dep:  &Deprecation{},
+			want: `command "old" is deprecated.`,
+		},
+		{
+			name:     "fallback",
+			dep:      &Deprecation{},
+			fallback: "new",
+			want:     `command "old" is deprecated, use "new" instead.`,
+		},
+		{
+			name:     "replacement_and_message",
+			dep:      &Deprecation{Replacement: "other", Message: "It will be removed soon."},
+			fallback: "new",
+			want:     `command "old" is deprecated, use "other" instead. It will be removed soon.`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.dep.describe(`command "old"`, tc.fallback, strconv.Quote); got != tc.want {
+				t.Errorf("expected %q to be %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeprecation_hidden(t *testing.T) {
+	t.Parallel()
+
+	var nilDep *Deprecation
+	if nilDep.hidden() {
+		t.Errorf("expected nil deprecation to not be hidden")
+	}
+	if (&Deprecation{}).hidden() {
+		t.Errorf("expected zero deprecation to not be hidden")
+	}
+	if (&Deprecation{HideAfter: time.Now().Add(time.Hour)}).hidden() {
+		t.Errorf("expected future deprecation to not be hidden")
+	}
+	if !(&Deprecation{HideAfter: time.Now().Add(-time.Hour)}).hidden() {
+		t.Errorf("expected past deprecation to be hidden")
+	}
+}
+
+func TestFlag_deprecated(t *testing.T) {
+	t.Parallel()
+
+	past := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newSet := func(stderr *bytes.Buffer) (*FlagSet, *string, *bool) {
+		set := NewFlagSet(WithStderr(stderr), WithLookupEnv(MapLookuper(nil)))
+		sec := set.NewSection("OPTIONS")
+
+		var name string
+		Flag(sec, &Var[string]{
+			Name:    "project",
+			Aliases: []string{"p", "old-project", "ancient-project"},
+			Usage:   "The project.",
+			Target:  &name,
+			Parser:  func(s string) (string, error) { return s, nil },
+			Printer: func(s string) string { return s },
+			Deprecated: map[string]*Deprecation{
+				"old-project":     {Message: "It will be removed in v2."},
+				"ancient-project": {HideAfter: past},
+			},
+		})
+
+		var legacy bool
+		Flag(sec, &Var[bool]{
+			Name:    "legacy",
+			Usage:   "Use the legacy mode.",
+			IsBool:  true,
+			Target:  &legacy,
+			Parser:  strconv.ParseBool,
+			Printer: strconv.FormatBool,
+			Deprecated: map[string]*Deprecation{
+				"legacy": {Replacement: "project", HideAfter: past},
+			},
+		})
+
+		return set, &name, &legacy
+	}
+
+	cases := []struct {
+		name       string
+		args       []string
+		want       string
+		wantWarn   string
+		wantLegacy bool
+	}{
+		{
+			name: "canonical",
+			args: []string{"-project", "a"},
+			want: "a",
+		},
+		{
+			name: "alias",
+			args: []string{"-p", "a"},
+			want: "a",
+		},
+		{
+			name:     "deprecated_alias",
+			args:     []string{"-old-project", "a"},
+			want:     "a",
+			wantWarn: "WARNING: flag -old-project is deprecated, use -project instead. It will be removed in v2.\n",
+		},
+		{
+			name:     "hidden_deprecated_alias",
+			args:     []string{"-ancient-project", "a"},
+			want:     "a",
+			wantWarn: "WARNING: flag -ancient-project is deprecated, use -project instead.\n",
+		},
+		{
+			name:       "deprecated_flag",
+			args:       []string{"-legacy"},
+			wantWarn:   "WARNING: flag -legacy is deprecated, use -project instead.\n",
+			wantLegacy: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stderr bytes.Buffer
+			set, name, legacy := newSet(&stderr)
+			if err := set.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := *name; got != tc.want {
+				t.Errorf("expected %q to be %q", got, tc.want)
+			}
+			if got := *legacy; got != tc.wantLegacy {
+				t.Errorf("expected %t to be %t", got, tc.wantLegacy)
+			}
+			if got := stderr.String(); got != tc.wantWarn {
+				t.Errorf("expected warning %q to be %q", got, tc.wantWarn)
+			}
+		})
+	}
+
+	t.Run("help", func(t *testing.T) {
+		t.Parallel()
+
+		set, _, _ := newSet(&bytes.Buffer{})
+		help := set.Help()
+
+		if want := "-p, -old-project, -project"; !strings.Contains(help, want) {
+			t.Errorf("expected help to contain %q:\n%s", want, help)
+		}
+		if want := "The alias -old-project is deprecated, use -project instead."; !strings.Contains(strings.Join(strings.Fields(help), " "), want) {
+			t.Errorf("expected help to contain %q:\n%s", want, help)
+		}
+		for _, unwanted := range []string{"-ancient-project", "-legacy"} {
+			if strings.Contains(help, unwanted) {
+				t.Errorf("expected help to not contain %q:\n%s", unwanted, help)
+			}
+		}
+	})
+
+	t.Run("inherited", func(t *testing.T) {
+		t.Parallel()
+
+		var stderr bytes.Buffer
+		parent, name, _ := newSet(&stderr)
+		child := NewFlagSet()
+		child.inherit(parent)
+
+		if err := child.Parse([]string{"-ancient-project", "b"}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := *name, "b"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+		if got, want := stderr.String(), "WARNING: flag -ancient-project is deprecated, use -project instead.\n"; got != want {
+			t.Errorf("expected warning %q to be %q", got, want)
+		}
+	})
+}
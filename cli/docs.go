@@ -0,0 +1,517 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+)
+
+// docFormats is the list of supported documentation formats.
+var docFormats = []string{"man", "markdown"}
+
+// GenerateMarkdownDocs walks the command tree starting at the given root and
+// writes one Markdown file per command into dir. Files are named after the
+// full command path with spaces replaced by underscores (e.g.
+// "my-tool_transport_bus.md"). Hidden commands and hidden flags are omitted.
+func GenerateMarkdownDocs(root *RootCommand, dir string) error {
+	return generateDocs(root, dir, markdownFilename, renderMarkdown)
+}
+
+// GenerateManPages walks the command tree starting at the given root and writes
+// one roff-formatted man page per command into dir. Pages are placed in section
+// 1 and named after the full command path with spaces replaced by dashes (e.g.
+// "my-tool-transport-bus.1"). Hidden commands and hidden flags are omitted.
+func GenerateManPages(root *RootCommand, dir string) error {
+	return generateDocs(root, dir, manFilename, renderMan)
+}
+
+// generateDocs builds the documentation tree and writes each rendered command
+// into dir.
+func generateDocs(root *RootCommand, dir string, filename func(string) string, render func(*commandDoc) string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gosec // Generated docs are meant to be world-readable.
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	tree := buildCommandDoc(root, root.Name, nil)
+
+	var merr error
+	tree.walk(func(d *commandDoc) {
+		if merr != nil {
+			return
+		}
+
+		pth := filepath.Join(dir, filename(d.path))
+		if err := os.WriteFile(pth, []byte(render(d)), 0o644); err != nil { //nolint:gosec // Generated docs are meant to be world-readable.
+			merr = fmt.Errorf("failed to write %s: %w", pth, err)
+		}
+	})
+	return merr
+}
+
+// commandDoc is the format-agnostic documentation for a single command.
+type commandDoc struct {
+	// path is the full command path, including the binary name.
+	path string
+
+	name     string
+	version  string
+	desc     string
+	help     string
+	sections []*flagSectionDoc
+	subs     []*commandDoc
+	parent   *commandDoc
+}
+
+// flagSectionDoc is the documentation for a [FlagSection].
+type flagSectionDoc struct {
+	name  string
+	flags []*flagDoc
+}
+
+// flagDoc is the documentation for a single flag.
+type flagDoc struct {
+	name         string
+	aliases      []string
+	example      string
+	usage        string
+	defaultValue string
+	envVar       string
+	isBool       bool
+
+	// dashedName returns the name as it is given on the command line.
+	dashedName func(name string) string
+}
+
+// documentedValue is implemented by flag values which expose their original
+// usage, default, and environment variable for generated documentation.
+type documentedValue interface {
+	docUsage() string
+	docDefault() string
+	docEnvVar() string
+}
+
+// buildCommandDoc builds the documentation tree for the given command,
+// recursing into nested [RootCommand].
+func buildCommandDoc(cmd Command, path string, parent *commandDoc) *commandDoc {
+	// Nested root commands print their name in help output, so they need the
+	// full path just like when they are dispatched by the parent.
+	r, isRoot := cmd.(*RootCommand)
+	if isRoot {
+		r.Name = path
+	}
+
+	d := &commandDoc{
+		path:   path,
+		desc:   cmd.Desc(),
+		parent: parent,
+	}
+	if idx := strings.LastIndex(path, " "); idx >= 0 {
+		d.name = path[idx+1:]
+	} else {
+		d.name = path
+	}
+
+	// Documentation does not depend on the terminal, so root commands are not
+	// styled or wrapped at the terminal width.
+	help := cmd.Help()
+	if isRoot {
+		help = r.help(nil)
+	}
+
+	f := cmd.Flags()
+	d.help = expandHelp(strings.Trim(help, "\n"), path, f)
+	if f != nil {
+		d.sections = buildFlagSectionDocs(f)
+	}
+
+	if isRoot {
+		d.version = r.Version
+		if parent != nil && d.version == "" {
+			d.version = parent.version
+		}
+
+		names := make([]string, 0, len(r.Commands))
+		for name := range r.Commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			instance := r.Commands[name]()
+			if instance == nil || instance.Hidden() || r.Deprecated[name].hidden() {
+				continue
+			}
+			inheritPersistentFlags(instance, f)
+			d.subs = append(d.subs, buildCommandDoc(instance, path+" "+name, d))
+		}
+	} else if parent != nil {
+		d.version = parent.version
+	}
+
+	return d
+}
+
+// buildFlagSectionDocs builds the documentation for each section in the flag
+// set, omitting hidden flags and empty sections.
+func buildFlagSectionDocs(f *FlagSet) []*flagSectionDoc {
+	all := append(append([]*FlagSection{}, f.sections...), f.inherited...)
+
+	sections := make([]*flagSectionDoc, 0, len(all))
+	for _, set := range all {
+		names := append([]string{}, set.flagNames...)
+		sort.Strings(names)
+
+		sd := &flagSectionDoc{name: set.name}
+		for _, name := range names {
+			sub := set.flagSet.Lookup(name)
+			if sub == nil {
+				panic("inconsistency between flag structure and docs")
+			}
+
+			typ, ok := sub.Value.(Value)
+			if !ok {
+				panic(fmt.Sprintf("flag is incorrect type %T", sub.Value))
+			}
+
+			if typ.Hidden() {
+				continue
+			}
+
+			fd := &flagDoc{
+				name:    sub.Name,
+				aliases: append([]string{}, typ.Aliases()...),
+				example: typ.Example(),
+				usage:   sub.Usage,
+				isBool:  typ.IsBoolFlag(),
+
+				dashedName: f.dashedName,
+			}
+			if dv, ok := typ.(documentedValue); ok {
+				fd.usage = dv.docUsage()
+				fd.defaultValue = dv.docDefault()
+				fd.envVar = dv.docEnvVar()
+			}
+			sort.Slice(fd.aliases, func(i, j int) bool {
+				return len(fd.aliases[i]) < len(fd.aliases[j])
+			})
+
+			sd.flags = append(sd.flags, fd)
+		}
+
+		if len(sd.flags) > 0 {
+			sections = append(sections, sd)
+		}
+	}
+	return sections
+}
+
+// walk calls fn for the command and all of its descendants, depth-first.
+func (d *commandDoc) walk(fn func(d *commandDoc)) {
+	fn(d)
+	for _, sub := range d.subs {
+		sub.walk(fn)
+	}
+}
+
+// names returns all dash-prefixed names of the flag, aliases first.
+func (f *flagDoc) names() []string {
+	all := make([]string, 0, len(f.aliases)+1)
+	for _, v := range f.aliases {
+		all = append(all, f.dashedName(v))
+	}
+	return append(all, f.dashedName(f.name))
+}
+
+// markdownFilename returns the Markdown filename for the command path.
+func markdownFilename(path string) string {
+	return strings.ReplaceAll(path, " ", "_") + ".md"
+}
+
+// renderMarkdown renders the command documentation as Markdown.
+func renderMarkdown(d *commandDoc) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", d.path)
+	if d.desc != "" {
+		fmt.Fprintf(&b, "%s\n\n", d.desc)
+	}
+
+	if d.help != "" {
+		fmt.Fprintf(&b, "```text\n%s\n```\n\n", d.help)
+	}
+
+	for _, s := range d.sections {
+		fmt.Fprintf(&b, "## %s\n\n", s.name)
+
+		for _, f := range s.flags {
+			names := f.names()
+			for i, v := range names {
+				names[i] = "`" + v + "`"
+			}
+			fmt.Fprintf(&b, "### %s\n\n", strings.Join(names, ", "))
+
+			if f.usage != "" {
+				fmt.Fprintf(&b, "%s\n\n", f.usage)
+			}
+
+			if !f.isBool && f.example != "" {
+				fmt.Fprintf(&b, "- Example: `%s`\n", f.example)
+			}
+			if f.defaultValue != "" {
+				fmt.Fprintf(&b, "- Default: `%s`\n", f.defaultValue)
+			}
+			if f.envVar != "" {
+				fmt.Fprintf(&b, "- Environment variable: `%s`\n", f.envVar)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(d.subs) > 0 {
+		fmt.Fprintf(&b, "## Commands\n\n")
+		for _, sub := range d.subs {
+			fmt.Fprintf(&b, "- [%s](%s)", sub.name, markdownFilename(sub.path))
+			if sub.desc != "" {
+				fmt.Fprintf(&b, " - %s", sub.desc)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if p := d.parent; p != nil {
+		fmt.Fprintf(&b, "## See also\n\n")
+		fmt.Fprintf(&b, "- [%s](%s)", p.path, markdownFilename(p.path))
+		if p.desc != "" {
+			fmt.Fprintf(&b, " - %s", p.desc)
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// manFilename returns the man page filename for the command path.
+func manFilename(path string) string {
+	return strings.ReplaceAll(path, " ", "-") + ".1"
+}
+
+// renderMan renders the command documentation as a roff man page.
+func renderMan(d *commandDoc) string {
+	var b strings.Builder
+
+	page := strings.ReplaceAll(d.path, " ", "-")
+	root := strings.SplitN(d.path, " ", 2)[0]
+	source := root
+	if d.version != "" {
+		source += " " + d.version
+	}
+
+	fmt.Fprintf(&b, ".TH %s 1 \"\" %s %s\n",
+		roffQuote(strings.ToUpper(page)), roffQuote(source), roffQuote(root+" Manual"))
+
+	fmt.Fprintf(&b, ".SH NAME\n")
+	if d.desc != "" {
+		fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(page), roffEscape(d.desc))
+	} else {
+		fmt.Fprintf(&b, "%s\n", roffEscape(page))
+	}
+
+	if d.help != "" {
+		fmt.Fprintf(&b, ".SH DESCRIPTION\n")
+		fmt.Fprintf(&b, ".nf\n%s\n.fi\n", roffEscapeLines(d.help))
+	}
+
+	for _, s := range d.sections {
+		fmt.Fprintf(&b, ".SH %s\n", roffQuote(s.name))
+
+		for _, f := range s.flags {
+			names := f.names()
+			for i, v := range names {
+				names[i] = `\fB` + roffEscape(v) + `\fR`
+			}
+
+			fmt.Fprintf(&b, ".TP\n")
+			if f.isBool || f.example == "" {
+				fmt.Fprintf(&b, "%s\n", strings.Join(names, ", "))
+			} else {
+				fmt.Fprintf(&b, "%s=\\fI%s\\fR\n", strings.Join(names, ", "), roffEscape(f.example))
+			}
+
+			var details []string
+			if f.usage != "" {
+				details = append(details, roffEscape(f.usage))
+			}
+			if f.defaultValue != "" {
+				details = append(details, fmt.Sprintf("Default: \\fB%s\\fR", roffEscape(f.defaultValue)))
+			}
+			if f.envVar != "" {
+				details = append(details, fmt.Sprintf("Environment variable: \\fB%s\\fR", roffEscape(f.envVar)))
+			}
+			fmt.Fprintf(&b, "%s\n", strings.Join(details, "\n.br\n"))
+		}
+	}
+
+	if len(d.subs) > 0 {
+		fmt.Fprintf(&b, ".SH COMMANDS\n")
+		for _, sub := range d.subs {
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\n", roffEscape(sub.name))
+			if sub.desc != "" {
+				fmt.Fprintf(&b, "%s\n.br\n", roffEscape(sub.desc))
+			}
+			fmt.Fprintf(&b, "See \\fB%s\\fR(1).\n", roffEscape(strings.ReplaceAll(sub.path, " ", "-")))
+		}
+	}
+
+	if p := d.parent; p != nil {
+		fmt.Fprintf(&b, ".SH SEE ALSO\n")
+		fmt.Fprintf(&b, "\\fB%s\\fR(1)\n", roffEscape(strings.ReplaceAll(p.path, " ", "-")))
+	}
+
+	return b.String()
+}
+
+// roffEscape escapes text for use inside a roff line.
+func roffEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\e`, "-", `\-`)
+	return r.Replace(s)
+}
+
+// roffEscapeLines escapes multi-line text for use in a roff no-fill block.
+// Lines that would otherwise be interpreted as roff requests are prefixed with
+// a zero-width character.
+func roffEscapeLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = roffEscape(line)
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			line = `\&` + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roffQuote escapes and double-quotes a roff macro argument.
+func roffQuote(s string) string {
+	return `"` + strings.ReplaceAll(roffEscape(s), `"`, `\(dq`) + `"`
+}
+
+// Ensure [genDocsCommand] implements [Command] and [ArgPredictor].
+var (
+	_ Command      = (*genDocsCommand)(nil)
+	_ ArgPredictor = (*genDocsCommand)(nil)
+)
+
+// genDocsCommand is a built-in, hidden command that generates reference
+// documentation for the command tree of a [RootCommand].
+type genDocsCommand struct {
+	BaseCommand
+
+	root *RootCommand
+
+	flagFormat    string
+	flagOutputDir string
+}
+
+func (c *genDocsCommand) Desc() string {
+	return "Generate reference documentation"
+}
+
+func (c *genDocsCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+
+  Generate reference documentation for every command as Markdown files or man
+  pages. Hidden commands and flags are omitted.
+`
+}
+
+func (c *genDocsCommand) Hidden() bool {
+	return true
+}
+
+func (c *genDocsCommand) Flags() *FlagSet {
+	set := c.NewFlagSet()
+
+	f := set.NewSection("OPTIONS")
+
+	f.StringVar(&StringVar{
+		Name:    "format",
+		Example: "man",
+		Default: "markdown",
+		Target:  &c.flagFormat,
+		Predict: predict.Set(docFormats),
+		Usage: `Format of the generated documentation. Valid values include: ` +
+			strings.Join(docFormats, ", ") + `.`,
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "output-dir",
+		Aliases: []string{"o"},
+		Example: "./docs",
+		Default: "docs",
+		Target:  &c.flagOutputDir,
+		Predict: predict.Dirs("*"),
+		Usage:   "Directory in which to write the generated documentation.",
+	})
+
+	set.AfterParse(func(existingErr error) error {
+		if c.flagFormat != "man" && c.flagFormat != "markdown" {
+			return fmt.Errorf("invalid format %q, valid formats are: %s",
+				c.flagFormat, strings.Join(docFormats, ", "))
+		}
+		return nil
+	})
+
+	return set
+}
+
+func (c *genDocsCommand) PredictArgs() complete.Predictor {
+	return predict.Nothing
+}
+
+func (c *genDocsCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	if args := f.Args(); len(args) > 0 {
+		return fmt.Errorf("expected 0 arguments, got %q", args)
+	}
+
+	switch c.flagFormat {
+	case "man":
+		if err := GenerateManPages(c.root, c.flagOutputDir); err != nil {
+			return fmt.Errorf("failed to generate man pages: %w", err)
+		}
+	case "markdown":
+		if err := GenerateMarkdownDocs(c.root, c.flagOutputDir); err != nil {
+			return fmt.Errorf("failed to generate markdown docs: %w", err)
+		}
+	}
+
+	c.Errf("Wrote documentation to %s", c.flagOutputDir)
+	return nil
+}
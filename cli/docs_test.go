@@ -0,0 +1,349 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestGenerateMarkdownDocs(t *testing.T) {
+	t.Parallel()
+
+	cmd := &RootCommand{
+		Name:        "my-tool",
+		Description: "My tool",
+		Version:     "1.2.3",
+		Commands: map[string]CommandFactory{
+			"hidden": func() Command {
+				return &TestCommand{Hide: true}
+			},
+			"transport": func() Command {
+				return &RootCommand{
+					Name:        "transport",
+					Description: "Transportation",
+					Commands: map[string]CommandFactory{
+						"bus": func() Command {
+							return &docsTestCommand{}
+						},
+					},
+				}
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	if err := GenerateMarkdownDocs(cmd, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{
+		"my-tool.md",
+		"my-tool_transport.md",
+		"my-tool_transport_bus.md",
+	}, listDir(t, dir)); diff != "" {
+		t.Errorf("files (-want, +got):\n%s", diff)
+	}
+
+	got := readFile(t, filepath.Join(dir, "my-tool_transport_bus.md"))
+	want := "# my-tool transport bus\n" +
+		"\n" +
+		"Ride the bus\n" +
+		"\n" +
+		"```text\n" +
+		"Usage: my-tool transport bus [options]\n" +
+		"\n" +
+		"  Ride the bus.\n" +
+		".hidden starts with a dot\n" +
+		"```\n" +
+		"\n" +
+		"## BUS OPTIONS\n" +
+		"\n" +
+		"### `-r`, `-route`\n" +
+		"\n" +
+		"Route number. This can be read from a file on disk by setting the value to \"@\" followed by the filepath.\n" +
+		"\n" +
+		"- Example: `42`\n" +
+		"- Default: `1`\n" +
+		"- Environment variable: `BUS_ROUTE`\n" +
+		"\n" +
+		"### `-standing`\n" +
+		"\n" +
+		"Stand for the ride.\n" +
+		"\n" +
+		"- Default: `false`\n" +
+		"\n" +
+		"## See also\n" +
+		"\n" +
+		"- [my-tool transport](my-tool_transport.md) - Transportation\n"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("markdown (-want, +got):\n%s", diff)
+	}
+
+	got = readFile(t, filepath.Join(dir, "my-tool_transport.md"))
+	for _, want := range []string{
+		"Usage: my-tool transport COMMAND",
+		"- [bus](my-tool_transport_bus.md) - Ride the bus",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected\n\n%s\n\nto contain %q", got, want)
+		}
+	}
+}
+
+func TestBuildFlagSectionDocs_posix(t *testing.T) {
+	t.Parallel()
+
+	set := NewFlagSet(WithPOSIXFlags())
+	set.NewSection("OPTIONS").StringVar(&StringVar{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Target:  new(string),
+		Usage:   "The output.",
+	})
+
+	sections := buildFlagSectionDocs(set)
+	if got, want := len(sections), 1; got != want {
+		t.Fatalf("expected %d sections to be %d", got, want)
+	}
+	if diff := cmp.Diff([]string{"-o", "--output"}, sections[0].flags[0].names()); diff != "" {
+		t.Errorf("names (-want, +got):\n%s", diff)
+	}
+}
+
+func TestGenerateManPages(t *testing.T) {
+	t.Parallel()
+
+	cmd := &RootCommand{
+		Name:        "my-tool",
+		Description: "My tool",
+		Version:     "1.2.3",
+		Commands: map[string]CommandFactory{
+			"hidden": func() Command {
+				return &TestCommand{Hide: true}
+			},
+			"transport": func() Command {
+				return &RootCommand{
+					Name:        "transport",
+					Description: "Transportation",
+					Commands: map[string]CommandFactory{
+						"bus": func() Command {
+							return &docsTestCommand{}
+						},
+					},
+				}
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	if err := GenerateManPages(cmd, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{
+		"my-tool-transport-bus.1",
+		"my-tool-transport.1",
+		"my-tool.1",
+	}, listDir(t, dir)); diff != "" {
+		t.Errorf("files (-want, +got):\n%s", diff)
+	}
+
+	got := readFile(t, filepath.Join(dir, "my-tool-transport-bus.1"))
+	for _, want := range []string{
+		`.TH "MY\-TOOL\-TRANSPORT\-BUS" 1 "" "my\-tool 1.2.3" "my\-tool Manual"`,
+		`my\-tool\-transport\-bus \- Ride the bus`,
+		`.SH "BUS OPTIONS"`,
+		`\fB\-r\fR, \fB\-route\fR=\fI42\fR`,
+		`Default: \fB1\fR`,
+		`Environment variable: \fBBUS_ROUTE\fR`,
+		`\fB\-standing\fR`,
+		`\&.hidden starts with a dot`,
+		`\fBmy\-tool\-transport\fR(1)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected\n\n%s\n\nto contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "secret") {
+		t.Errorf("expected\n\n%s\n\nto not contain hidden flags", got)
+	}
+}
+
+func TestGenDocsCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		args  []string
+		err   string
+		files []string
+	}{
+		{
+			name:  "markdown",
+			args:  []string{"-format", "markdown"},
+			files: []string{"my-tool.md", "my-tool_transport.md", "my-tool_transport_bus.md"},
+		},
+		{
+			name:  "man",
+			args:  []string{"-format", "man"},
+			files: []string{"my-tool-transport-bus.1", "my-tool-transport.1", "my-tool.1"},
+		},
+		{
+			name: "invalid_format",
+			args: []string{"-format", "pdf"},
+			err:  `invalid format "pdf"`,
+		},
+		{
+			name: "extra_args",
+			args: []string{"foo"},
+			err:  `expected 0 arguments`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := filepath.Join(t.TempDir(), "docs")
+
+			cmd := &RootCommand{
+				Name:        "my-tool",
+				Description: "My tool",
+				Version:     "1.2.3",
+				Commands: map[string]CommandFactory{
+					"hidden": func() Command {
+						return &TestCommand{Hide: true}
+					},
+					"transport": func() Command {
+						return &RootCommand{
+							Name:        "transport",
+							Description: "Transportation",
+							Commands: map[string]CommandFactory{
+								"bus": func() Command {
+									return &docsTestCommand{}
+								},
+							},
+						}
+					},
+				},
+			}
+			cmd.Pipe()
+
+			args := append([]string{"gen-docs", "-output-dir", dir}, tc.args...)
+			err := cmd.Run(t.Context(), args)
+			if diff := testutil.DiffErrString(err, tc.err); diff != "" {
+				t.Fatal(diff)
+			}
+
+			if tc.files != nil {
+				if diff := cmp.Diff(tc.files, listDir(t, dir)); diff != "" {
+					t.Errorf("files (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func listDir(tb testing.TB, dir string) []string {
+	tb.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(tb testing.TB, pth string) string {
+	tb.Helper()
+
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(b)
+}
+
+type docsTestCommand struct {
+	BaseCommand
+
+	flagRoute    int
+	flagStanding bool
+	flagSecret   string
+}
+
+func (c *docsTestCommand) Desc() string {
+	return "Ride the bus"
+}
+
+func (c *docsTestCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+
+  Ride the bus.
+.hidden starts with a dot
+`
+}
+
+func (c *docsTestCommand) Flags() *FlagSet {
+	set := c.NewFlagSet(WithLookupEnv(MapLookuper(nil)))
+
+	f := set.NewSection("BUS OPTIONS")
+
+	f.IntVar(&IntVar{
+		Name:          "route",
+		Aliases:       []string{"r"},
+		Example:       "42",
+		Default:       1,
+		EnvVar:        "BUS_ROUTE",
+		Target:        &c.flagRoute,
+		AllowFromFile: true,
+		Usage:         "Route number.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:   "standing",
+		Target: &c.flagStanding,
+		Usage:  "Stand for the ride.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:   "secret",
+		Hidden: true,
+		Target: &c.flagSecret,
+		Usage:  "Hidden flag.",
+	})
+
+	return set
+}
+
+func (c *docsTestCommand) Run(ctx context.Context, args []string) error {
+	return nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows && !zos
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// disableEcho is not supported on this platform.
+func disableEcho(fd uintptr) (func() error, error) {
+	return nil, fmt.Errorf("disabling echo is not supported on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
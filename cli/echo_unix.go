@@ -0,0 +1,51 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || zos
+
+package cli
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// disableEcho disables echoing of typed characters on the terminal with the
+// given file descriptor. It returns a function which restores the previous
+// terminal state.
+func disableEcho(fd uintptr) (func() error, error) {
+	termios, err := unix.IoctlGetTermios(int(fd), ioctlReadTermios)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get terminal state: %w", err)
+	}
+
+	previous := *termios
+
+	// Keep ICANON so input is still line-buffered, and keep ECHONL so the user
+	// sees the newline when pressing enter.
+	termios.Lflag &^= unix.ECHO
+	termios.Lflag |= unix.ICANON | unix.ISIG | unix.ECHONL
+	termios.Iflag |= unix.ICRNL
+	if err := unix.IoctlSetTermios(int(fd), ioctlWriteTermios, termios); err != nil {
+		return nil, fmt.Errorf("failed to disable echo: %w", err)
+	}
+
+	return func() error {
+		if err := unix.IoctlSetTermios(int(fd), ioctlWriteTermios, &previous); err != nil {
+			return fmt.Errorf("failed to restore terminal state: %w", err)
+		}
+		return nil
+	}, nil
+}
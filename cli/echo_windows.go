@@ -0,0 +1,46 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package cli
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// disableEcho disables echoing of typed characters on the console with the
+// given handle. It returns a function which restores the previous console
+// mode.
+func disableEcho(fd uintptr) (func() error, error) {
+	var previous uint32
+	if err := windows.GetConsoleMode(windows.Handle(fd), &previous); err != nil {
+		return nil, fmt.Errorf("failed to get console mode: %w", err)
+	}
+
+	mode := previous &^ windows.ENABLE_ECHO_INPUT
+	mode |= windows.ENABLE_PROCESSED_INPUT | windows.ENABLE_LINE_INPUT
+	if err := windows.SetConsoleMode(windows.Handle(fd), mode); err != nil {
+		return nil, fmt.Errorf("failed to disable echo: %w", err)
+	}
+
+	return func() error {
+		if err := windows.SetConsoleMode(windows.Handle(fd), previous); err != nil {
+			return fmt.Errorf("failed to restore console mode: %w", err)
+		}
+		return nil
+	}, nil
+}
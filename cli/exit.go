@@ -0,0 +1,223 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// ExitCodeOK is the exit code for successful commands.
+	ExitCodeOK = 0
+
+	// ExitCodeError is the exit code for commands which failed, unless the error
+	// specifies a different code with [ExitError].
+	ExitCodeError = 1
+
+	// ExitCodeUsage is the exit code for commands which were invoked incorrectly,
+	// such as with an unknown flag or subcommand.
+	ExitCodeUsage = 2
+
+	// ExitCodeInterrupted is the exit code for commands which were interrupted
+	// by SIGINT (e.g. Ctrl+C). It matches the convention of shells, which is 128
+	// plus the signal number.
+	ExitCodeInterrupted = 130
+
+	// ExitCodeTerminated is the exit code for commands which were terminated by
+	// SIGTERM. It matches the convention of shells, which is 128 plus the signal
+	// number.
+	ExitCodeTerminated = 143
+)
+
+// ExitError is an error with an exit code and an optional hint for the user. It
+// can be returned (or wrapped) by [Command.Run] to control the exit code and
+// output of [Main].
+//
+// [FlagSet.Parse] returns an ExitError with [ExitCodeUsage] for invalid flags
+// and arguments and for violated flag constraints, and [RootCommand] sets the
+// hint to the command's short usage.
+type ExitError struct {
+	// Code is the exit code. If zero, it defaults to [ExitCodeError].
+	Code int
+
+	// Err is the underlying error. If nil, [Main] exits with the code without
+	// printing an error, which is useful if the error was already reported.
+	Err error
+
+	// Hint is additional help text which is printed after the error, such as
+	// how to fix it.
+	Hint string
+}
+
+// Error implements the error interface. It returns the message of the
+// underlying error.
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return "exit status " + strconv.Itoa(e.ExitCode())
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code, defaulting to [ExitCodeError].
+func (e *ExitError) ExitCode() int {
+	if e.Code == 0 {
+		return ExitCodeError
+	}
+	return e.Code
+}
+
+// Main runs the command with the arguments from [os.Args] and returns the exit
+// code. The context is canceled when the process receives an interrupt or
+// termination signal. It is typically called from main:
+//
+//	func main() {
+//		os.Exit(cli.Main(context.Background(), rootCmd()))
+//	}
+//
+// Errors are printed to the command's [Stderr], followed by the hint of any
+// [ExitError]. Usage errors include the short usage of the command. The exit
+// code is:
+//
+//   - [ExitCodeOK] if the command succeeded or help was requested.
+//   - [ExitCodeInterrupted] if the process received SIGINT, or
+//     [ExitCodeTerminated] if it received SIGTERM.
+//   - [ExitCodeInterrupted] if the error is or wraps [context.Canceled].
+//   - The code of the [ExitError], if the error is or wraps one.
+//   - [ExitCodeError] for all other errors.
+func Main(ctx context.Context, cmd Command) int {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signalCh)
+
+	go func() {
+		select {
+		case sig := <-signalCh:
+			cancel(&signalError{signal: sig})
+		case <-ctx.Done():
+		}
+	}()
+
+	return runMain(ctx, cmd, os.Args[1:])
+}
+
+// signalError is the cause of the context cancellation when [Main] receives a
+// signal.
+type signalError struct {
+	signal os.Signal
+}
+
+// Error implements the error interface.
+func (e *signalError) Error() string {
+	return "received signal " + e.signal.String()
+}
+
+// exitCode returns the exit code for the signal.
+func (e *signalError) exitCode() int {
+	if e.signal == syscall.SIGTERM {
+		return ExitCodeTerminated
+	}
+	return ExitCodeInterrupted
+}
+
+// runMain runs the command with the given arguments and handles the result as
+// described by [Main].
+func runMain(ctx context.Context, cmd Command, args []string) int {
+	err := cmd.Run(ctx, args)
+	if err == nil {
+		return ExitCodeOK
+	}
+
+	// Commands which are not a [RootCommand] return [flag.ErrHelp] when help is
+	// requested.
+	if errors.Is(err, flag.ErrHelp) {
+		cmd.Errf(formatHelp(cmd.Help(), commandName(cmd), cmd.Flags()))
+		return ExitCodeOK
+	}
+
+	var sigErr *signalError
+	if errors.As(context.Cause(ctx), &sigErr) {
+		return sigErr.exitCode()
+	}
+	if errors.Is(err, context.Canceled) {
+		return ExitCodeInterrupted
+	}
+
+	// Subcommands of a [RootCommand] already have the hint set by the root.
+	err = withUsageHint(err, cmd, commandName(cmd))
+
+	var style *Style
+	if s, ok := cmd.(interface{ ErrStyle() *Style }); ok {
+		style = s.ErrStyle()
+	}
+
+	// An [ExitError] without an underlying error only sets the exit code, for
+	// example when the error was already reported.
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Err != nil || err != error(exitErr) {
+		cmd.Errf("%s %s", style.Error("error:"), err)
+	}
+
+	if exitErr != nil {
+		if v := strings.TrimSpace(exitErr.Hint); v != "" {
+			cmd.Errf("\n%s", style.Dim(v))
+		}
+		return exitErr.ExitCode()
+	}
+	return ExitCodeError
+}
+
+// withUsageHint sets the hint of usage errors to the short usage of the given
+// command, unless the error already has a hint.
+func withUsageHint(err error, cmd Command, name string) error {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) && exitErr.Code == ExitCodeUsage && exitErr.Hint == "" {
+		exitErr.Hint = shortUsage(cmd, name)
+	}
+	return err
+}
+
+// shortUsage returns the first line of the command's help, which is the usage
+// line by convention, and instructions for getting more help.
+func shortUsage(cmd Command, name string) string {
+	help := expandHelp(strings.TrimSpace(cmd.Help()), name, cmd.Flags())
+	if line, _, _ := strings.Cut(help, "\n"); line != "" {
+		return line + "\n\nRun \"" + name + " -help\" for more information."
+	}
+	return "Run \"" + name + " -help\" for more information."
+}
+
+// commandName returns the name of the command for help output.
+func commandName(cmd Command) string {
+	if r, ok := cmd.(*RootCommand); ok {
+		return r.Name
+	}
+	return filepath.Base(os.Args[0])
+}
@@ -0,0 +1,284 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestExitError(t *testing.T) {
+	t.Parallel()
+
+	inner := fmt.Errorf("oops")
+	err := fmt.Errorf("wrapped: %w", &ExitError{Err: inner, Hint: "try again"})
+
+	if got, want := err.Error(), "wrapped: oops"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if !errors.Is(err, inner) {
+		t.Errorf("expected error to wrap %v", inner)
+	}
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected error to be an ExitError")
+	}
+	if got, want := exitErr.ExitCode(), ExitCodeError; got != want {
+		t.Errorf("expected exit code %d to be %d", got, want)
+	}
+
+	if got, want := (&ExitError{Code: 7}).Error(), "exit status 7"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestRunMain(t *testing.T) {
+	t.Parallel()
+
+	canceledCtx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	interruptedCtx, cancelInterrupted := context.WithCancelCause(t.Context())
+	cancelInterrupted(&signalError{signal: os.Interrupt})
+
+	terminatedCtx, cancelTerminated := context.WithCancelCause(t.Context())
+	cancelTerminated(&signalError{signal: syscall.SIGTERM})
+
+	rootCmd := func() *RootCommand {
+		return &RootCommand{
+			Name: "test",
+			Commands: map[string]CommandFactory{
+				"ok": func() Command {
+					return &TestCommand{Output: "done"}
+				},
+				"fail": func() Command {
+					return &TestCommand{Error: fmt.Errorf("a bad thing happened")}
+				},
+				"custom": func() Command {
+					return &TestCommand{Error: fmt.Errorf("wrapped: %w", &ExitError{
+						Code: 42,
+						Err:  fmt.Errorf("not found"),
+						Hint: "Create it first.",
+					})}
+				},
+				"silent": func() Command {
+					return &TestCommand{Error: &ExitError{Code: 5}}
+				},
+				"canceled": func() Command {
+					return &TestCommand{Error: fmt.Errorf("failed: %w", context.Canceled)}
+				},
+			},
+		}
+	}
+
+	cases := []struct {
+		name       string
+		ctx        context.Context //nolint:containedctx // Test-only
+		cmd        Command
+		args       []string
+		wantCode   int
+		wantStderr string
+	}{
+		{
+			name:     "success",
+			args:     []string{"ok"},
+			wantCode: ExitCodeOK,
+		},
+		{
+			name:       "root_help",
+			args:       []string{"-help"},
+			wantCode:   ExitCodeOK,
+			wantStderr: "Usage: test COMMAND\n",
+		},
+		{
+			name:       "leaf_help",
+			cmd:        &TestCommand{},
+			args:       []string{"-help"},
+			wantCode:   ExitCodeOK,
+			wantStderr: "Usage: cli.test",
+		},
+		{
+			name:       "error",
+			args:       []string{"fail"},
+			wantCode:   ExitCodeError,
+			wantStderr: "error: a bad thing happened\n",
+		},
+		{
+			name:     "unknown_flag",
+			args:     []string{"ok", "-nope"},
+			wantCode: ExitCodeUsage,
+			wantStderr: "error: failed to parse flags: flag provided but not defined: -nope\n" +
+				"\n" +
+				"Usage: test ok\n" +
+				"\n" +
+				"Run \"test ok -help\" for more information.\n",
+		},
+		{
+			name:     "unknown_command",
+			args:     []string{"nope"},
+			wantCode: ExitCodeUsage,
+			wantStderr: "error: unknown command \"nope\": run \"test -help\" for a list " +
+				"of commands\n",
+		},
+		{
+			name:       "custom_code",
+			args:       []string{"custom"},
+			wantCode:   42,
+			wantStderr: "error: wrapped: not found\n\nCreate it first.\n",
+		},
+		{
+			name:     "silent",
+			args:     []string{"silent"},
+			wantCode: 5,
+		},
+		{
+			name:     "leaf_usage_error",
+			cmd:      &TestCommand{},
+			args:     []string{"-nope"},
+			wantCode: ExitCodeUsage,
+			wantStderr: "error: failed to parse flags: flag provided but not defined: -nope\n" +
+				"\n" +
+				"Usage: cli.test",
+		},
+		{
+			name:     "canceled_error",
+			args:     []string{"canceled"},
+			wantCode: ExitCodeInterrupted,
+		},
+		{
+			name:       "canceled_context",
+			ctx:        canceledCtx,
+			args:       []string{"fail"},
+			wantCode:   ExitCodeError,
+			wantStderr: "error: a bad thing happened\n",
+		},
+		{
+			name:     "interrupted",
+			ctx:      interruptedCtx,
+			args:     []string{"canceled"},
+			wantCode: ExitCodeInterrupted,
+		},
+		{
+			name:     "terminated",
+			ctx:      terminatedCtx,
+			args:     []string{"canceled"},
+			wantCode: ExitCodeTerminated,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = t.Context()
+			}
+
+			cmd := tc.cmd
+			if cmd == nil {
+				cmd = rootCmd()
+			}
+			_, _, stderr := cmd.(interface {
+				Pipe() (stdin, stdout, stderr *bytes.Buffer)
+			}).Pipe()
+
+			if got, want := runMain(ctx, cmd, tc.args), tc.wantCode; got != want {
+				t.Errorf("expected exit code %d to be %d (stderr: %q)", got, want, stderr.String())
+			}
+			if got, want := stderr.String(), tc.wantStderr; !strings.HasPrefix(got, want) || (want == "" && got != "") {
+				t.Errorf("expected stderr %q to start with %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFlagSet_Parse_usageErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		args       []string
+		afterParse error
+		wantCode   int
+	}{
+		{
+			name:     "success",
+			wantCode: ExitCodeOK,
+		},
+		{
+			name:     "unknown_flag",
+			args:     []string{"-nope"},
+			wantCode: ExitCodeUsage,
+		},
+		{
+			name:     "constraint",
+			args:     []string{"-a", "x", "-b", "y"},
+			wantCode: ExitCodeUsage,
+		},
+		{
+			name:       "after_parse",
+			afterParse: fmt.Errorf("failed to connect"),
+			wantCode:   ExitCodeError,
+		},
+		{
+			name:       "after_parse_with_usage",
+			args:       []string{"-nope"},
+			afterParse: fmt.Errorf("failed to connect"),
+			wantCode:   ExitCodeUsage,
+		},
+		{
+			name:       "after_parse_exit_error",
+			afterParse: &ExitError{Code: 42},
+			wantCode:   42,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			set := NewFlagSet()
+			f := set.NewSection("OPTIONS")
+			f.StringVar(&StringVar{Name: "a", Target: new(string)})
+			f.StringVar(&StringVar{Name: "b", Target: new(string)})
+			set.MarkMutuallyExclusive("a", "b")
+			set.AfterParse(func(existingErr error) error {
+				return tc.afterParse
+			})
+
+			err := set.Parse(tc.args)
+
+			code := ExitCodeOK
+			if err != nil {
+				code = ExitCodeError
+				var exitErr *ExitError
+				if errors.As(err, &exitErr) {
+					code = exitErr.ExitCode()
+				}
+			}
+			if code != tc.wantCode {
+				t.Errorf("expected exit code %d to be %d: %v", code, tc.wantCode, err)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -40,7 +41,15 @@ import (
 	"github.com/abcxyz/pkg/timeutil"
 )
 
-const maxLineLength = 80
+const (
+	// defaultLineLength is the length at which help output is wrapped when the
+	// width of the terminal is unknown.
+	defaultLineLength = 80
+
+	// minLineLength is the minimum length at which help output is wrapped, so
+	// help remains readable on very narrow terminals.
+	minLineLength = 40
+)
 
 type (
 	// LookupEnvFunc is the signature of a function for looking up environment
@@ -97,12 +106,26 @@ type FlagSet struct {
 	flagSet  *flag.FlagSet
 	sections []*FlagSection
 
+	// inherited are the persistent flag sections inherited from parent
+	// commands. They share values with the parent and are rendered after all
+	// other sections.
+	inherited []*FlagSection
+
 	lookupEnv  LookupEnvFunc
 	workingDir WorkingDirFunc
 	promptAll  PromptAllFunc
+	secret     PromptAllFunc
+	config     *configSet
+	stderr     io.Writer
+	style      *Style
+	posix      bool
 
+	constraints     []*flagConstraint
 	afterParseFuncs []AfterParseFunc
 	args            []string
+
+	// argSpecs are the declared positional arguments, in order.
+	argSpecs []*argSpec
 }
 
 // Option is an option to the flagset.
@@ -137,6 +160,29 @@ func WithWorkingDir(fn WorkingDirFunc) Option {
 	}
 }
 
+// WithStderr sets the writer to which warnings, such as the use of deprecated
+// flags, are printed. The default is [os.Stderr].
+func WithStderr(w io.Writer) Option {
+	return func(fs *FlagSet) *FlagSet {
+		if w != nil {
+			fs.stderr = w
+		}
+		return fs
+	}
+}
+
+// WithStyle sets the style used to render help output. The default is no
+// styling. [BaseCommand.NewFlagSet] uses [BaseCommand.ErrStyle], since help is
+// printed to stderr.
+func WithStyle(s *Style) Option {
+	return func(fs *FlagSet) *FlagSet {
+		if s != nil {
+			fs.style = s
+		}
+		return fs
+	}
+}
+
 // WithWorkingDir sets the prompt function.
 func WithPromptAll(fn PromptAllFunc) Option {
 	return func(fs *FlagSet) *FlagSet {
@@ -147,6 +193,18 @@ func WithPromptAll(fn PromptAllFunc) Option {
 	}
 }
 
+// WithPromptSecret sets the prompt function for sensitive flags, which should
+// not echo the input. The default is the prompt function set with
+// [WithPromptAll].
+func WithPromptSecret(fn PromptAllFunc) Option {
+	return func(fs *FlagSet) *FlagSet {
+		if fn != nil {
+			fs.secret = fn
+		}
+		return fs
+	}
+}
+
 // NewFlagSet creates a new root flag set.
 func NewFlagSet(opts ...Option) *FlagSet {
 	f := flag.NewFlagSet("", flag.ContinueOnError)
@@ -159,6 +217,7 @@ func NewFlagSet(opts ...Option) *FlagSet {
 		flagSet:    f,
 		lookupEnv:  os.LookupEnv,
 		workingDir: workingDir,
+		stderr:     os.Stderr,
 		promptAll: func(ctx context.Context, msg string, args ...any) (string, error) {
 			var val string
 			fmt.Printf(msg, args...)
@@ -171,6 +230,10 @@ func NewFlagSet(opts ...Option) *FlagSet {
 		fs = opt(fs)
 	}
 
+	if fs.secret == nil {
+		fs.secret = fs.promptAll
+	}
+
 	return fs
 }
 
@@ -181,12 +244,18 @@ type FlagSection struct {
 	name      string
 	flagNames []string
 
+	// set is the flag set which owns this section.
+	set *FlagSet
+
 	// fields inherited from the parent
 	flagSet *flag.FlagSet
 
 	lookupEnv  LookupEnvFunc
 	workingDir WorkingDirFunc
 	promptAll  PromptAllFunc
+	secret     PromptAllFunc
+	config     *configSet
+	stderr     io.Writer
 }
 
 // NewSection creates a new flag section. By convention, section names should be
@@ -194,15 +263,68 @@ type FlagSection struct {
 func (f *FlagSet) NewSection(name string) *FlagSection {
 	fs := &FlagSection{
 		name:       name,
+		set:        f,
 		flagSet:    f.flagSet,
 		lookupEnv:  f.lookupEnv,
 		workingDir: f.workingDir,
 		promptAll:  f.promptAll,
+		secret:     f.secret,
+		config:     f.config,
+		stderr:     f.stderr,
 	}
 	f.sections = append(f.sections, fs)
 	return fs
 }
 
+// inherit merges the sections and flags of the given parent flag set into this
+// flag set. The flags share values with the parent, so parsing a flag on either
+// set updates the same target. Flags which are already defined on this flag set
+// are skipped.
+func (f *FlagSet) inherit(parent *FlagSet) {
+	for _, set := range append(append([]*FlagSection{}, parent.sections...), parent.inherited...) {
+		sec := &FlagSection{
+			name:       set.name,
+			set:        f,
+			flagSet:    f.flagSet,
+			lookupEnv:  f.lookupEnv,
+			workingDir: f.workingDir,
+			promptAll:  f.promptAll,
+			secret:     f.secret,
+			config:     f.config,
+			stderr:     f.stderr,
+		}
+
+		for _, name := range set.flagNames {
+			pf := parent.flagSet.Lookup(name)
+			if pf == nil || f.flagSet.Lookup(name) != nil {
+				continue
+			}
+
+			sec.flagNames = append(sec.flagNames, name)
+			f.flagSet.Var(pf.Value, name, pf.Usage)
+
+			// Register every alias, including those hidden from help, using the
+			// parent's value so deprecation warnings are preserved.
+			var aliases []string
+			if typ, ok := pf.Value.(Value); ok {
+				aliases = typ.Aliases()
+			}
+			if typ, ok := pf.Value.(interface{ registeredAliases() []string }); ok {
+				aliases = typ.registeredAliases()
+			}
+			for _, alias := range aliases {
+				if af := parent.flagSet.Lookup(alias); af != nil && f.flagSet.Lookup(alias) == nil {
+					f.flagSet.Var(af.Value, alias, "")
+				}
+			}
+		}
+
+		if len(sec.flagNames) > 0 {
+			f.inherited = append(f.inherited, sec)
+		}
+	}
+}
+
 // AfterParse defines a post-parse function. This can be used to set flag
 // defaults that should not be set until after parsing, such as defaulting flag
 // values to the value of other flags. These functions are called after flags
@@ -238,7 +360,19 @@ func (f *FlagSet) Lookup(name string) *flag.Flag {
 func (f *FlagSet) Parse(args []string) error {
 	// Call the normal parse function first, so that Args and everything are
 	// properly set for any after functions.
-	merr := f.flagSet.Parse(args)
+	//
+	// Errors from parsing flags and arguments and from the flag constraints are
+	// usage errors, which are collected separately from other errors.
+	var merr, usageErr error
+	if f.config != nil {
+		merr = f.config.err
+	}
+	if f.posix {
+		var err error
+		args, err = f.normalizePOSIXArgs(args)
+		usageErr = errors.Join(usageErr, err)
+	}
+	usageErr = errors.Join(usageErr, f.withFlagSuggestions(f.redactFlagError(f.flagSet.Parse(args))))
 
 	// "Recursively" parse flags. By default, Go stops parsing after the first
 	// non-flag argument.
@@ -249,13 +383,21 @@ func (f *FlagSet) Parse(args []string) error {
 			break
 		}
 		finalArgs = append(finalArgs, f.flagSet.Arg(0))
-		merr = errors.Join(merr, f.flagSet.Parse(args[i:]))
+		usageErr = errors.Join(usageErr, f.withFlagSuggestions(f.redactFlagError(f.flagSet.Parse(args[i:]))))
 		i += 1 + len(args[i:]) - len(f.flagSet.Args())
 	}
 	finalArgs = append(finalArgs, f.flagSet.Args()...)
 
 	f.args = finalArgs
 
+	// Arguments and flag constraints are not validated when help was
+	// requested, since they are likely missing.
+	if !errors.Is(usageErr, flag.ErrHelp) {
+		usageErr = errors.Join(usageErr, f.parseArgs())
+		usageErr = errors.Join(usageErr, f.validateConstraints())
+	}
+	merr = errors.Join(merr, usageErr)
+
 	for _, fn := range f.afterParseFuncs {
 		func() {
 			defer func() {
@@ -268,9 +410,52 @@ func (f *FlagSet) Parse(args []string) error {
 		}()
 	}
 
+	// Other errors, such as errors returned by after parse functions, are
+	// returned as-is, unless there was also a usage error. An after parse
+	// function can still return an error with a specific exit code.
+	var exitErr *ExitError
+	if usageErr != nil && !errors.As(merr, &exitErr) {
+		return &ExitError{Code: ExitCodeUsage, Err: merr}
+	}
 	return merr
 }
 
+// undefinedFlagPrefix is the prefix of the error message returned by the flag
+// package when parsing a flag that is not defined.
+const undefinedFlagPrefix = "flag provided but not defined: -"
+
+// withFlagSuggestions appends suggestions for similarly-named flags if the
+// given error is an undefined flag error. Hidden flags are never suggested.
+func (f *FlagSet) withFlagSuggestions(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	name, ok := strings.CutPrefix(err.Error(), undefinedFlagPrefix)
+	if !ok {
+		return err
+	}
+
+	// The flag package always reports the name with a single dash.
+	if f.posix {
+		err = fmt.Errorf("%s%s", strings.TrimSuffix(undefinedFlagPrefix, "-"), f.dashedName(name))
+	}
+
+	var candidates []string
+	f.flagSet.VisitAll(func(fl *flag.Flag) {
+		if typ, ok := fl.Value.(Value); ok && typ.Hidden() {
+			return
+		}
+		candidates = append(candidates, fl.Name)
+	})
+
+	v := formatSuggestions(suggest(name, candidates), f.dashedName)
+	if v == "" {
+		return err
+	}
+	return fmt.Errorf("%w (%s)", err, v)
+}
+
 // Args implements flag.FlagSet#Parsed.
 func (f *FlagSet) Parsed() bool {
 	return f.flagSet.Parsed()
@@ -289,11 +474,12 @@ func (f *FlagSet) VisitAll(fn func(*flag.Flag)) {
 // Help returns formatted help output.
 func (f *FlagSet) Help() string {
 	var b strings.Builder
+	fmt.Fprint(&b, f.argsHelp())
 
-	for _, set := range f.sections {
+	for _, set := range append(append([]*FlagSection{}, f.sections...), f.inherited...) {
 		sort.Strings(set.flagNames)
 
-		fmt.Fprint(&b, set.name)
+		fmt.Fprint(&b, f.style.Bold(set.name))
 		fmt.Fprint(&b, "\n\n")
 
 		for _, name := range set.flagNames {
@@ -319,23 +505,30 @@ func (f *FlagSet) Help() string {
 			})
 			all := make([]string, 0, len(aliases)+1)
 			for _, v := range aliases {
-				all = append(all, "-"+v)
+				all = append(all, f.style.Highlight(f.dashedName(v)))
 			}
-			all = append(all, "-"+sub.Name)
+			all = append(all, f.style.Highlight(f.dashedName(sub.Name)))
 
 			// Handle boolean flags
 			if typ.IsBoolFlag() {
 				fmt.Fprintf(&b, "    %s\n", strings.Join(all, ", "))
 			} else {
-				fmt.Fprintf(&b, "    %s=%q\n", strings.Join(all, ", "), typ.Example())
+				fmt.Fprintf(&b, "    %s=%s\n", strings.Join(all, ", "),
+					f.style.Dim(strconv.Quote(typ.Example())))
 			}
 
-			indented := wrapAtLengthWithPadding(sub.Usage, 8)
+			indented := wrapAtLengthWithPadding(sub.Usage, f.lineLength(), 8)
 			fmt.Fprint(&b, indented)
 			fmt.Fprint(&b, "\n\n")
 		}
 	}
 
+	if v := f.constraintsHelp(); v != "" {
+		fmt.Fprint(&b, f.style.Bold("FLAG CONSTRAINTS"))
+		fmt.Fprint(&b, "\n\n")
+		fmt.Fprint(&b, v)
+	}
+
 	return strings.TrimRight(b.String(), "\n")
 }
 
@@ -415,6 +608,19 @@ type Var[T any] struct {
 	// like stdin by setting the input value to "-".
 	AllowFromPrompt bool
 
+	// Sensitive marks the value as a secret, such as a password or token. The
+	// value is redacted everywhere it is printed, including help output, error
+	// messages, and [FlagSet.DumpValues], and prompts do not echo the input.
+	// Use [flag.Getter] to get the value of a sensitive flag, since the String
+	// method of the flag value is also redacted.
+	//
+	// Sensitive values cannot be given directly on the command line, where other
+	// users can see them in the process list, unless AllowSensitiveArgs is true.
+	// They can still be given with the environment variable, a config file, or a
+	// file or prompt if AllowFromFile or AllowFromPrompt are true.
+	Sensitive          bool
+	AllowSensitiveArgs bool
+
 	// Parser and Printer are the generic functions for converting string values
 	// to/from the target value. These are populated by the individual flag
 	// helpers.
@@ -432,6 +638,17 @@ type Var[T any] struct {
 	// Implementations that do special processing (such as appending to a slice),
 	// may override this to customize the behavior.
 	Setter SetterFunc[T]
+
+	// listParser, if set, parses lists from config files, whose elements are
+	// joined with commas (see [configValueString]) instead of given by repeating
+	// the flag. It defaults to Parser.
+	listParser ParserFunc[T]
+
+	// Deprecated marks the flag name or any of its aliases as deprecated, keyed
+	// by the deprecated name. Deprecated names are still accepted, but print a
+	// warning when used. To rename a flag, make the old name a deprecated alias
+	// of the new flag.
+	Deprecated map[string]*Deprecation
 }
 
 // Flag is a lower-level API for creating a flag on a flag section. Callers
@@ -454,6 +671,28 @@ func Flag[T any](f *FlagSection, i *Var[T]) {
 		panic("missing printer func")
 	}
 
+	listParser := i.listParser
+	if listParser == nil {
+		listParser = parser
+	}
+
+	// Sensitive values are redacted before any other parsers are added, so
+	// errors from reading files and prompts are preserved.
+	var sensitive *sensitivePolicy
+	if i.Sensitive {
+		parser = redactParser(i.Name, parser)
+		listParser = redactParser(i.Name, listParser)
+		printer = redactPrinter(printer)
+		sensitive = &sensitivePolicy{
+			name:        i.Name,
+			envVar:      i.EnvVar,
+			allowArgs:   i.AllowSensitiveArgs,
+			allowFile:   i.AllowFromFile,
+			allowPrompt: i.AllowFromPrompt,
+		}
+	}
+	configParser := parser
+
 	predictor := i.Predict
 	if predictor == nil {
 		if i.IsBool {
@@ -472,7 +711,11 @@ func Flag[T any](f *FlagSection, i *Var[T]) {
 	}
 
 	if i.AllowFromPrompt {
-		parser = fromPromptParser(i.Name, parser, f.promptAll)
+		prompt := f.promptAll
+		if i.Sensitive {
+			prompt = f.secret
+		}
+		parser = fromPromptParser(i.Name, parser, prompt)
 		usage += " This value be read from a prompt or pipe by setting the value " +
 			"to \"-\"."
 	}
@@ -482,10 +725,30 @@ func Flag[T any](f *FlagSection, i *Var[T]) {
 		setter = func(cur *T, val T) { *cur = val }
 	}
 
+	// Resolve the initial value. Environment variables take precedence over
+	// config files, which take precedence over the default.
 	initial := i.Default
+	source := flagSource{kind: sourceDefault}
+	if cv, cf, ok := f.config.lookup(append([]string{i.Name}, i.Aliases...)...); ok {
+		v, parse := cv.value, configParser
+		if cv.isList {
+			parse = listParser
+		}
+		if t, err := parse(v); err != nil {
+			if i.Sensitive {
+				v = redactedValue
+			}
+			f.config.err = errors.Join(f.config.err, fmt.Errorf(
+				"invalid value %q for flag -%s from config file %s: %w", v, i.Name, cf.path, err))
+		} else {
+			initial = t
+			source = flagSource{kind: sourceConfigFile, detail: cf.path}
+		}
+	}
 	if v, ok := f.lookupEnv(i.EnvVar); ok {
 		if t, err := parser(v); err == nil {
 			initial = t
+			source = flagSource{kind: sourceEnv, detail: i.EnvVar}
 		}
 	}
 
@@ -498,7 +761,40 @@ func Flag[T any](f *FlagSection, i *Var[T]) {
 		example = fmt.Sprintf("%T", *new(T))
 	}
 
-	if v := printer(i.Default); v != "" {
+	// Describe deprecations, unless they are past their grace period.
+	hidden := i.Hidden
+	aliases := make([]string, 0, len(i.Aliases))
+	for _, alias := range i.Aliases {
+		if !i.Deprecated[alias].hidden() {
+			aliases = append(aliases, alias)
+		}
+	}
+	for name, dep := range i.Deprecated {
+		if dep == nil {
+			continue
+		}
+		switch {
+		case name == i.Name:
+			hidden = hidden || dep.hidden()
+		case !slices.Contains(i.Aliases, name):
+			panic(fmt.Sprintf("deprecated name %q is not the name or an alias of flag -%s", name, i.Name))
+		}
+	}
+	if dep := i.Deprecated[i.Name]; dep != nil && !hidden {
+		usage += " " + dep.describe("This flag", "", dashed)
+	}
+	for _, alias := range aliases {
+		if dep := i.Deprecated[alias]; dep != nil {
+			usage += " " + dep.describe("The alias -"+alias, i.Name, dashed)
+		}
+	}
+
+	// Capture the usage before default and environment information is added so
+	// documentation can render them separately.
+	docUsage := usage
+
+	defaultValue := printer(i.Default)
+	if v := defaultValue; v != "" {
 		usage += fmt.Sprintf(" The default value is %q.", v)
 	}
 
@@ -507,28 +803,64 @@ func Flag[T any](f *FlagSection, i *Var[T]) {
 			"environment variable.", v)
 	}
 
+	if i.Sensitive && !i.AllowSensitiveArgs {
+		usage += " This value is sensitive and cannot be given directly on the " +
+			"command line."
+	}
+
+	if source.kind != sourceDefault {
+		usage += fmt.Sprintf(" The current value %q is from the %s.", printer(initial), source)
+	}
+
 	fv := &flagValue[T]{
 		target:    i.Target,
-		hidden:    i.Hidden,
+		hidden:    hidden,
 		isBool:    i.IsBool,
 		example:   example,
 		parser:    parser,
 		printer:   printer,
 		predictor: predictor,
 		setter:    setter,
-		aliases:   i.Aliases,
+		aliases:   aliases,
+		all:       i.Aliases,
+		src:       source,
+		sensitive: sensitive,
+
+		usage:        docUsage,
+		defaultValue: defaultValue,
+		envVar:       i.EnvVar,
+	}
+	// Deprecated names are registered with a wrapper which warns when they are
+	// used.
+	register := func(name, fallback, usage string) {
+		var v flag.Value = fv
+		if dep := i.Deprecated[name]; dep != nil {
+			v = &deprecatedFlagValue[T]{
+				flagValue:   fv,
+				name:        name,
+				deprecation: dep,
+				fallback:    fallback,
+				stderr:      f.stderr,
+				style:       f.set.style,
+			}
+		}
+		f.flagSet.Var(v, name, usage)
 	}
+
 	f.flagNames = append(f.flagNames, i.Name)
-	f.flagSet.Var(fv, i.Name, usage)
+	register(i.Name, "", usage)
 
 	// Since aliases are not added as a flag name, we can safely add them to the
 	// main flag set. Our custom help will skip them.
 	for _, alias := range i.Aliases {
-		f.flagSet.Var(fv, alias, "")
+		register(alias, i.Name, "")
 	}
 }
 
-var _ Value = (*flagValue[any])(nil)
+var (
+	_ Value        = (*flagValue[any])(nil)
+	_ sourcedValue = (*flagValue[any])(nil)
+)
 
 type flagValue[T any] struct {
 	target  *T
@@ -541,14 +873,38 @@ type flagValue[T any] struct {
 	setter    SetterFunc[T]
 	predictor complete.Predictor
 	aliases   []string
+
+	// all is every registered alias, including deprecated aliases which are
+	// hidden from help output.
+	all []string
+
+	// src is where the current value was resolved from.
+	src flagSource
+
+	// sensitive is the command line policy of sensitive flags, or nil if the
+	// flag is not sensitive.
+	sensitive *sensitivePolicy
+
+	// usage, defaultValue, and envVar are tracked separately from the flag's
+	// full usage text for generating documentation.
+	usage        string
+	defaultValue string
+	envVar       string
 }
 
 func (f *flagValue[T]) Set(s string) error {
+	if f.sensitive != nil {
+		if err := f.sensitive.check(s); err != nil {
+			return err
+		}
+	}
+
 	v, err := f.parser(s)
 	if err != nil {
 		return err
 	}
 	f.setter(f.target, v)
+	f.src = flagSource{kind: sourceFlag}
 	return nil
 }
 
@@ -560,6 +916,14 @@ func (f *flagValue[T]) Hidden() bool                  { return f.hidden }
 func (f *flagValue[T]) IsBoolFlag() bool              { return f.isBool }
 func (f *flagValue[T]) Predictor() complete.Predictor { return f.predictor }
 
+func (f *flagValue[T]) source() flagSource          { return f.src }
+func (f *flagValue[T]) isSensitive() bool           { return f.sensitive != nil }
+func (f *flagValue[T]) registeredAliases() []string { return f.all }
+
+func (f *flagValue[T]) docUsage() string   { return f.usage }
+func (f *flagValue[T]) docDefault() string { return f.defaultValue }
+func (f *flagValue[T]) docEnvVar() string  { return f.envVar }
+
 type BoolVar struct {
 	Name            string
 	Aliases         []string
@@ -756,6 +1120,9 @@ type StringVar struct {
 	Target          *string
 	AllowFromFile   bool
 	AllowFromPrompt bool
+
+	Sensitive          bool
+	AllowSensitiveArgs bool
 }
 
 func (f *FlagSection) StringVar(i *StringVar) {
@@ -776,6 +1143,9 @@ func (f *FlagSection) StringVar(i *StringVar) {
 		Printer:         printer,
 		AllowFromFile:   i.AllowFromFile,
 		AllowFromPrompt: i.AllowFromPrompt,
+
+		Sensitive:          i.Sensitive,
+		AllowSensitiveArgs: i.AllowSensitiveArgs,
 	})
 }
 
@@ -793,29 +1163,73 @@ type StringMapVar struct {
 	AllowFromPrompt bool
 }
 
+// StringMapVar creates a new map variable. Each value is a "key=value" pair,
+// and pairs are given by repeating the flag. Config files can also give the
+// pairs as a list.
 func (f *FlagSection) StringMapVar(i *StringMapVar) {
-	parser := func(s string) (map[string]string, error) {
-		idx := strings.Index(s, "=")
-		if idx == -1 {
-			return nil, fmt.Errorf("missing = in KV pair %q", s)
-		}
+	Flag(f, &Var[map[string]string]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          parseStringMapEntry,
+		Printer:         printStringMap,
+		Setter:          stringMapSetter(),
+		listParser:      parseStringMap,
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
 
-		m := make(map[string]string, 1)
-		m[s[0:idx]] = s[idx+1:]
-		return m, nil
+// parseStringMapEntry parses a single "key=value" pair.
+func parseStringMapEntry(s string) (map[string]string, error) {
+	idx := strings.Index(s, "=")
+	if idx == -1 {
+		return nil, fmt.Errorf("missing = in KV pair %q", s)
 	}
 
-	printer := func(m map[string]string) string {
-		list := make([]string, 0, len(m))
-		for k, v := range m {
-			list = append(list, k+"="+v)
+	m := make(map[string]string, 1)
+	m[s[0:idx]] = s[idx+1:]
+	return m, nil
+}
+
+// parseStringMap parses comma-separated "key=value" pairs, as given by lists in
+// config files and struct tag defaults.
+func parseStringMap(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range splitSliceValue(s) {
+		entry, err := parseStringMapEntry(pair)
+		if err != nil {
+			return nil, err
 		}
-		sort.Strings(list)
-		return strings.Join(list, ",")
+		for k, v := range entry {
+			m[k] = v
+		}
+	}
+	return m, nil
+}
+
+// printStringMap prints the map as sorted, comma-separated "key=value" pairs.
+func printStringMap(m map[string]string) string {
+	list := make([]string, 0, len(m))
+	for k, v := range m {
+		list = append(list, k+"="+v)
 	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
 
+// stringMapSetter returns a setter which merges values into the target map. The
+// first call sets the default value, and the first value given after the
+// default replaces it rather than merging with it.
+func stringMapSetter() SetterFunc[map[string]string] {
 	var setDefault *bool
-	setter := func(cur *map[string]string, val map[string]string) {
+	return func(cur *map[string]string, val map[string]string) {
 		if setDefault == nil {
 			setDefault = ptr(true)
 		} else if *setDefault {
@@ -830,23 +1244,6 @@ func (f *FlagSection) StringMapVar(i *StringMapVar) {
 			(*cur)[k] = v
 		}
 	}
-
-	Flag(f, &Var[map[string]string]{
-		Name:            i.Name,
-		Aliases:         i.Aliases,
-		Usage:           i.Usage,
-		Example:         i.Example,
-		Default:         i.Default,
-		Hidden:          i.Hidden,
-		EnvVar:          i.EnvVar,
-		Predict:         i.Predict,
-		Target:          i.Target,
-		Parser:          parser,
-		Printer:         printer,
-		Setter:          setter,
-		AllowFromFile:   i.AllowFromFile,
-		AllowFromPrompt: i.AllowFromPrompt,
-	})
 }
 
 type StringSliceVar struct {
@@ -865,53 +1262,13 @@ type StringSliceVar struct {
 
 func (f *FlagSection) StringSliceVar(i *StringSliceVar) {
 	parser := func(s string) ([]string, error) {
-		var parts []string
-		var b strings.Builder
-		var escaped bool
-
-		for _, r := range s {
-			if escaped {
-				escaped = false
-				b.WriteRune(r)
-				continue
-			}
-
-			switch r {
-			case '\\':
-				escaped = true
-			case ',':
-				if v := strings.TrimSpace(b.String()); v != "" {
-					parts = append(parts, v)
-				}
-				b.Reset()
-			default:
-				b.WriteRune(r)
-			}
-		}
-
-		if v := strings.TrimSpace(b.String()); v != "" {
-			parts = append(parts, v)
-		}
-
-		return parts, nil
+		return splitSliceValue(s), nil
 	}
 
 	printer := func(v []string) string {
 		return strings.Join(v, ",")
 	}
 
-	var setDefault *bool
-	setter := func(cur *[]string, val []string) {
-		if setDefault == nil {
-			setDefault = ptr(true)
-		} else if *setDefault {
-			*cur = []string{}
-			setDefault = ptr(false)
-		}
-
-		*cur = append(*cur, val...)
-	}
-
 	Flag(f, &Var[[]string]{
 		Name:            i.Name,
 		Aliases:         i.Aliases,
@@ -924,12 +1281,47 @@ func (f *FlagSection) StringSliceVar(i *StringSliceVar) {
 		Target:          i.Target,
 		Parser:          parser,
 		Printer:         printer,
-		Setter:          setter,
+		Setter:          sliceSetter[string](),
 		AllowFromFile:   i.AllowFromFile,
 		AllowFromPrompt: i.AllowFromPrompt,
 	})
 }
 
+// splitSliceValue splits the value on commas, trimming whitespace around each
+// element and dropping empty elements. A comma (or any other character) can be
+// escaped with a backslash.
+func splitSliceValue(s string) []string {
+	var parts []string
+	var b strings.Builder
+	var escaped bool
+
+	for _, r := range s {
+		if escaped {
+			escaped = false
+			b.WriteRune(r)
+			continue
+		}
+
+		switch r {
+		case '\\':
+			escaped = true
+		case ',':
+			if v := strings.TrimSpace(b.String()); v != "" {
+				parts = append(parts, v)
+			}
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	if v := strings.TrimSpace(b.String()); v != "" {
+		parts = append(parts, v)
+	}
+
+	return parts
+}
+
 type TimeVar struct {
 	Name            string
 	Aliases         []string
@@ -1049,15 +1441,6 @@ type LogLevelVar struct {
 }
 
 func (f *FlagSection) LogLevelVar(i *LogLevelVar) {
-	parser := func(s string) (slog.Level, error) {
-		v, err := logging.LookupLevel(s)
-		if err != nil {
-			return 0, err
-		}
-		return v, nil
-	}
-
-	printer := func(v slog.Level) string { return logging.LevelString(v) }
 
 	setter := func(_ *slog.Level, val slog.Level) { logging.SetLevel(i.Logger, val) }
 
@@ -1075,18 +1458,42 @@ func (f *FlagSection) LogLevelVar(i *LogLevelVar) {
 		Default:         slog.LevelInfo,
 		Predict:         predict.Set(levelNames),
 		Target:          &fake,
-		Parser:          parser,
-		Printer:         printer,
+		Parser:          parseLogLevel,
+		Printer:         logging.LevelString,
 		Setter:          setter,
 		AllowFromFile:   i.AllowFromFile,
 		AllowFromPrompt: i.AllowFromPrompt,
 	})
 }
 
-// wrapAtLengthWithPadding wraps the given text at the maxLineLength, taking
-// into account any provided left padding.
-func wrapAtLengthWithPadding(s string, pad int) string {
-	wrapped := text.Wrap(s, maxLineLength-pad)
+// parseLogLevel parses the name of a log level, such as "warn".
+func parseLogLevel(s string) (slog.Level, error) {
+	v, err := logging.LookupLevel(s)
+	if err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+// lineLength returns the length at which help output is wrapped, which is the
+// width of the terminal if known.
+func (f *FlagSet) lineLength() int {
+	return lineLengthFor(f.style)
+}
+
+// lineLengthFor returns the length at which help output written with the given
+// style is wrapped. See [NewStyle] for how the terminal width is determined.
+func lineLengthFor(s *Style) int {
+	if w := s.Width(); w > 0 {
+		return max(w, minLineLength)
+	}
+	return defaultLineLength
+}
+
+// wrapAtLengthWithPadding wraps the given text at the given length, taking into
+// account any provided left padding.
+func wrapAtLengthWithPadding(s string, length, pad int) string {
+	wrapped := text.Wrap(s, length-pad)
 	lines := strings.Split(wrapped, "\n")
 	for i, line := range lines {
 		lines[i] = strings.Repeat(" ", pad) + line
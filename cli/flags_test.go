@@ -676,6 +676,12 @@ func TestFlagSet_Parse(t *testing.T) {
 			wantArgs:  []string{"arg1"},
 			wantError: "flag provided but not defined: -invalid",
 		},
+		{
+			name:      "error_suggestion",
+			args:      []string{"{{dash}}friut=apple", "arg1"},
+			wantArgs:  []string{"arg1"},
+			wantError: "flag provided but not defined: -friut (did you mean -fruit?)",
+		},
 	}
 
 	for _, tc := range cases {
@@ -717,6 +723,27 @@ func TestFlagSet_Parse(t *testing.T) {
 	}
 }
 
+func TestFlagSet_Parse_suggestionsIgnoreHidden(t *testing.T) {
+	t.Parallel()
+
+	fs := NewFlagSet()
+	sec := fs.NewSection("my-section")
+	sec.StringVar(&StringVar{
+		Name:    "secret",
+		Aliases: []string{"s"},
+		Hidden:  true,
+		Target:  pointer.To(""),
+	})
+
+	err := fs.Parse([]string{"-secrte=foo"})
+	if diff := testutil.DiffErrString(err, "flag provided but not defined: -secrte"); diff != "" {
+		t.Error(diff)
+	}
+	if got := err.Error(); strings.Contains(got, "did you mean") {
+		t.Errorf("expected %q to not include suggestions", got)
+	}
+}
+
 func TestFromFileParser(t *testing.T) {
 	t.Parallel()
 
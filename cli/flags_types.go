@@ -0,0 +1,837 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+)
+
+type EnumVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         string
+	Values          []string
+	Hidden          bool
+	EnvVar          string
+	Target          *string
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// EnumVar creates a new string variable which must be one of the given values.
+// The valid values are listed in the help output and offered as completions.
+func (f *FlagSection) EnumVar(i *EnumVar) {
+	Flag(f, &Var[string]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           enumUsage(i.Usage, i.Values),
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         predict.Set(i.Values),
+		Target:          i.Target,
+		Parser:          enumParser(i.Values),
+		Printer:         func(v string) string { return v },
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type EnumSliceVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         []string
+	Values          []string
+	Hidden          bool
+	EnvVar          string
+	Target          *[]string
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// EnumSliceVar creates a new string slice variable where each element must be
+// one of the given values. Like [FlagSection.StringSliceVar], elements may be
+// given by repeating the flag or as a comma-separated list.
+func (f *FlagSection) EnumSliceVar(i *EnumSliceVar) {
+	Flag(f, &Var[[]string]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           enumUsage(i.Usage, i.Values),
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         predict.Set(i.Values),
+		Target:          i.Target,
+		Parser:          sliceParser(enumParser(i.Values), true),
+		Printer:         slicePrinter(func(v string) string { return v }),
+		Setter:          sliceSetter[string](),
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+// enumUsage appends the list of valid values to the usage text.
+func enumUsage(usage string, values []string) string {
+	return strings.TrimSpace(usage + " Valid values include: " + strings.Join(values, ", ") + ".")
+}
+
+// enumParser returns a parser which only accepts the given values.
+func enumParser(values []string) ParserFunc[string] {
+	return func(s string) (string, error) {
+		if slices.Contains(values, s) {
+			return s, nil
+		}
+
+		err := fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+		if v := formatSuggestions(suggest(s, values), strconv.Quote); v != "" {
+			err = fmt.Errorf("%w (%s)", err, v)
+		}
+		return "", err
+	}
+}
+
+type URLVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         url.URL
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *url.URL
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// URLVar creates a new URL variable. The value must be an absolute URL with a
+// scheme (e.g. "https://example.com/path").
+func (f *FlagSection) URLVar(i *URLVar) {
+	Flag(f, &Var[url.URL]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          parseURL,
+		Printer:         printURL,
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type URLSliceVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         []url.URL
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *[]url.URL
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// URLSliceVar creates a new URL slice variable. Elements may be given by
+// repeating the flag or as a comma-separated list.
+func (f *FlagSection) URLSliceVar(i *URLSliceVar) {
+	Flag(f, &Var[[]url.URL]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          sliceParser(parseURL, true),
+		Printer:         slicePrinter(printURL),
+		Setter:          sliceSetter[url.URL](),
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+// parseURL parses an absolute URL.
+func parseURL(s string) (url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return url.URL{}, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme == "" {
+		return url.URL{}, fmt.Errorf("invalid url %q: missing scheme", s)
+	}
+	return *u, nil
+}
+
+func printURL(v url.URL) string {
+	return v.String()
+}
+
+type IPVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         netip.Addr
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *netip.Addr
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// IPVar creates a new IP address variable. Both IPv4 and IPv6 addresses are
+// accepted.
+func (f *FlagSection) IPVar(i *IPVar) {
+	Flag(f, &Var[netip.Addr]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          parseIP,
+		Printer:         printIP,
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type IPSliceVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         []netip.Addr
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *[]netip.Addr
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// IPSliceVar creates a new IP address slice variable. Elements may be given by
+// repeating the flag or as a comma-separated list.
+func (f *FlagSection) IPSliceVar(i *IPSliceVar) {
+	Flag(f, &Var[[]netip.Addr]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          sliceParser(parseIP, true),
+		Printer:         slicePrinter(printIP),
+		Setter:          sliceSetter[netip.Addr](),
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+func parseIP(s string) (netip.Addr, error) {
+	v, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid ip address: %w", err)
+	}
+	return v, nil
+}
+
+func printIP(v netip.Addr) string {
+	if !v.IsValid() {
+		return ""
+	}
+	return v.String()
+}
+
+type CIDRVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         netip.Prefix
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *netip.Prefix
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// CIDRVar creates a new CIDR variable (e.g. "10.0.0.0/8"). Both IPv4 and IPv6
+// prefixes are accepted.
+func (f *FlagSection) CIDRVar(i *CIDRVar) {
+	Flag(f, &Var[netip.Prefix]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          parseCIDR,
+		Printer:         printCIDR,
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type CIDRSliceVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         []netip.Prefix
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *[]netip.Prefix
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// CIDRSliceVar creates a new CIDR slice variable. Elements may be given by
+// repeating the flag or as a comma-separated list.
+func (f *FlagSection) CIDRSliceVar(i *CIDRSliceVar) {
+	Flag(f, &Var[[]netip.Prefix]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          sliceParser(parseCIDR, true),
+		Printer:         slicePrinter(printCIDR),
+		Setter:          sliceSetter[netip.Prefix](),
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+func parseCIDR(s string) (netip.Prefix, error) {
+	v, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid cidr: %w", err)
+	}
+	return v, nil
+}
+
+func printCIDR(v netip.Prefix) string {
+	if !v.IsValid() {
+		return ""
+	}
+	return v.String()
+}
+
+type RegexpVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         *regexp.Regexp
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          **regexp.Regexp
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// RegexpVar creates a new regular expression variable. The value is compiled
+// with [regexp.Compile].
+func (f *FlagSection) RegexpVar(i *RegexpVar) {
+	Flag(f, &Var[*regexp.Regexp]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          parseRegexp,
+		Printer:         printRegexp,
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type RegexpSliceVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         []*regexp.Regexp
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *[]*regexp.Regexp
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// RegexpSliceVar creates a new regular expression slice variable. Since
+// regular expressions may contain commas, values are not split and elements are
+// given by repeating the flag. Config files can also give the elements as a
+// list.
+func (f *FlagSection) RegexpSliceVar(i *RegexpSliceVar) {
+	Flag(f, &Var[[]*regexp.Regexp]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          sliceParser(parseRegexp, false),
+		listParser:      sliceParser(parseRegexp, true),
+		Printer:         slicePrinter(printRegexp),
+		Setter:          sliceSetter[*regexp.Regexp](),
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+func parseRegexp(s string) (*regexp.Regexp, error) {
+	v, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return v, nil
+}
+
+func printRegexp(v *regexp.Regexp) string {
+	if v == nil {
+		return ""
+	}
+	return v.String()
+}
+
+type ByteSizeVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         uint64
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *uint64
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// ByteSizeVar creates a new variable for a number of bytes. Values are a number
+// followed by an optional unit, which is either a decimal unit (kB, MB, GB, TB,
+// PB, EB) or a binary unit (KiB, MiB, GiB, TiB, PiB, EiB). For example, "10MiB"
+// is 10485760 bytes and "1.5kB" is 1500 bytes. Units are case-insensitive.
+func (f *FlagSection) ByteSizeVar(i *ByteSizeVar) {
+	Flag(f, &Var[uint64]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          parseByteSize,
+		Printer:         formatByteSize,
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type ByteSizeSliceVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         []uint64
+	Hidden          bool
+	EnvVar          string
+	Predict         complete.Predictor
+	Target          *[]uint64
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// ByteSizeSliceVar creates a new slice variable for numbers of bytes, using the
+// same syntax as [FlagSection.ByteSizeVar]. Elements may be given by repeating
+// the flag or as a comma-separated list.
+func (f *FlagSection) ByteSizeSliceVar(i *ByteSizeSliceVar) {
+	Flag(f, &Var[[]uint64]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         i.Predict,
+		Target:          i.Target,
+		Parser:          sliceParser(parseByteSize, true),
+		Printer:         slicePrinter(formatByteSize),
+		Setter:          sliceSetter[uint64](),
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+// byteUnits are the supported byte size units, which [parseByteSize] matches
+// case-insensitively. No two units are the same size.
+var byteUnits = []struct {
+	name string
+	size uint64
+}{
+	{"EiB", 1 << 60},
+	{"PiB", 1 << 50},
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"EB", 1e18},
+	{"PB", 1e15},
+	{"TB", 1e12},
+	{"GB", 1e9},
+	{"MB", 1e6},
+	{"kB", 1e3},
+	{"B", 1},
+}
+
+// parseByteSize parses a human-readable byte size such as "10MiB" or "1.5GB".
+func parseByteSize(s string) (uint64, error) {
+	v := strings.TrimSpace(s)
+
+	idx := strings.IndexFunc(v, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := v, ""
+	if idx >= 0 {
+		number, unit = v[:idx], strings.TrimSpace(v[idx:])
+	}
+	if number == "" {
+		return 0, fmt.Errorf("invalid byte size %q: missing number", s)
+	}
+
+	multiplier := uint64(1)
+	if unit != "" {
+		found := false
+		for _, u := range byteUnits {
+			if strings.EqualFold(unit, u.name) {
+				multiplier, found = u.size, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", s, unit)
+		}
+	}
+
+	// Parse integers exactly to avoid losing precision for large values.
+	if n, err := strconv.ParseUint(number, 10, 64); err == nil {
+		if n > math.MaxUint64/multiplier {
+			return 0, fmt.Errorf("invalid byte size %q: value out of range", s)
+		}
+		return n * multiplier, nil
+	}
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q: %w", s, err)
+	}
+	result := f * float64(multiplier)
+	if result >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid byte size %q: value out of range", s)
+	}
+	return uint64(result), nil
+}
+
+// formatByteSize formats the number of bytes using the largest unit which
+// divides the value exactly, so the value is never rounded.
+func formatByteSize(v uint64) string {
+	if v == 0 {
+		return "0B"
+	}
+
+	best := byteUnits[len(byteUnits)-1]
+	for _, u := range byteUnits {
+		if v%u.size == 0 && u.size > best.size {
+			best = u
+		}
+	}
+	return strconv.FormatUint(v/best.size, 10) + best.name
+}
+
+type FileVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         string
+	Hidden          bool
+	EnvVar          string
+	Target          *string
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// FileVar creates a new variable for the path to an existing file. Paths are
+// completed from the filesystem, and relative paths are resolved against the
+// working directory (see [WithWorkingDir]). The default value is not checked
+// for existence.
+func (f *FlagSection) FileVar(i *FileVar) {
+	Flag(f, &Var[string]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         predict.Files("*"),
+		Target:          i.Target,
+		Parser:          pathParser(false, f.workingDir),
+		Printer:         func(v string) string { return v },
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type FileSliceVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         []string
+	Hidden          bool
+	EnvVar          string
+	Target          *[]string
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// FileSliceVar creates a new slice variable for the paths to existing files.
+// Elements may be given by repeating the flag or as a comma-separated list.
+func (f *FlagSection) FileSliceVar(i *FileSliceVar) {
+	Flag(f, &Var[[]string]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         predict.Files("*"),
+		Target:          i.Target,
+		Parser:          sliceParser(pathParser(false, f.workingDir), true),
+		Printer:         slicePrinter(func(v string) string { return v }),
+		Setter:          sliceSetter[string](),
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type DirVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         string
+	Hidden          bool
+	EnvVar          string
+	Target          *string
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// DirVar creates a new variable for the path to an existing directory. Paths
+// are completed from the filesystem, and relative paths are resolved against
+// the working directory (see [WithWorkingDir]). The default value is not
+// checked for existence.
+func (f *FlagSection) DirVar(i *DirVar) {
+	Flag(f, &Var[string]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         predict.Dirs("*"),
+		Target:          i.Target,
+		Parser:          pathParser(true, f.workingDir),
+		Printer:         func(v string) string { return v },
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+type DirSliceVar struct {
+	Name            string
+	Aliases         []string
+	Usage           string
+	Example         string
+	Default         []string
+	Hidden          bool
+	EnvVar          string
+	Target          *[]string
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// DirSliceVar creates a new slice variable for the paths to existing
+// directories. Elements may be given by repeating the flag or as a
+// comma-separated list.
+func (f *FlagSection) DirSliceVar(i *DirSliceVar) {
+	Flag(f, &Var[[]string]{
+		Name:            i.Name,
+		Aliases:         i.Aliases,
+		Usage:           i.Usage,
+		Example:         i.Example,
+		Default:         i.Default,
+		Hidden:          i.Hidden,
+		EnvVar:          i.EnvVar,
+		Predict:         predict.Dirs("*"),
+		Target:          i.Target,
+		Parser:          sliceParser(pathParser(true, f.workingDir), true),
+		Printer:         slicePrinter(func(v string) string { return v }),
+		Setter:          sliceSetter[string](),
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+// pathParser returns a parser which requires the path to exist and be a
+// directory (if dir is true) or not a directory (if dir is false). Relative
+// paths are resolved against the working directory, and the parsed value is the
+// resolved path.
+func pathParser(dir bool, workingDir WorkingDirFunc) ParserFunc[string] {
+	return func(s string) (string, error) {
+		pth := filepath.Clean(s)
+		if !filepath.IsAbs(pth) {
+			root, err := workingDir()
+			if err != nil {
+				return "", fmt.Errorf("failed to get working directory: %w", err)
+			}
+			pth = filepath.Join(root, pth)
+		}
+
+		info, err := os.Stat(pth)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat: %w", err)
+		}
+
+		if dir && !info.IsDir() {
+			return "", fmt.Errorf("%s is not a directory", s)
+		}
+		if !dir && info.IsDir() {
+			return "", fmt.Errorf("%s is a directory", s)
+		}
+		return pth, nil
+	}
+}
+
+// sliceParser returns a parser for a list of values, parsing each element with
+// the given parser. If split is true, the value is split on unescaped commas
+// like [FlagSection.StringSliceVar].
+func sliceParser[T any](parse ParserFunc[T], split bool) ParserFunc[[]T] {
+	return func(s string) ([]T, error) {
+		parts := []string{s}
+		if split {
+			parts = splitSliceValue(s)
+		}
+
+		result := make([]T, 0, len(parts))
+		for _, part := range parts {
+			v, err := parse(part)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	}
+}
+
+// slicePrinter returns a printer which joins the printed elements with commas.
+func slicePrinter[T any](printer PrinterFunc[T]) PrinterFunc[[]T] {
+	return func(v []T) string {
+		parts := make([]string, 0, len(v))
+		for _, elem := range v {
+			parts = append(parts, printer(elem))
+		}
+		return strings.Join(parts, ",")
+	}
+}
+
+// sliceSetter returns a setter which appends values to the target. The first
+// call sets the default value, and the first value given after the default
+// replaces it rather than appending to it.
+func sliceSetter[T any]() SetterFunc[[]T] {
+	var setDefault *bool
+	return func(cur *[]T, val []T) {
+		if setDefault == nil {
+			setDefault = ptr(true)
+		} else if *setDefault {
+			*cur = []T{}
+			setDefault = ptr(false)
+		}
+
+		*cur = append(*cur, val...)
+	}
+}
@@ -0,0 +1,472 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/posener/complete/v2/predict"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestFlagSection_typedVars(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	// Each setup function registers a single flag named "v" and returns a
+	// function which returns its current value.
+	cases := []struct {
+		name    string
+		setup   func(f *FlagSection) func() any
+		args    []string
+		want    any
+		wantErr string
+	}{
+		{
+			name: "enum",
+			setup: func(f *FlagSection) func() any {
+				var v string
+				f.EnumVar(&EnumVar{Name: "v", Values: []string{"json", "text"}, Default: "text", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", "json"},
+			want: "json",
+		},
+		{
+			name: "enum_default",
+			setup: func(f *FlagSection) func() any {
+				var v string
+				f.EnumVar(&EnumVar{Name: "v", Values: []string{"json", "text"}, Default: "text", Target: &v})
+				return func() any { return v }
+			},
+			want: "text",
+		},
+		{
+			name: "enum_invalid",
+			setup: func(f *FlagSection) func() any {
+				var v string
+				f.EnumVar(&EnumVar{Name: "v", Values: []string{"json", "text"}, Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", "jsno"},
+			wantErr: `invalid value "jsno" for flag -v: must be one of json, text (did you mean "json"?)`,
+		},
+		{
+			name: "enum_slice",
+			setup: func(f *FlagSection) func() any {
+				var v []string
+				f.EnumSliceVar(&EnumSliceVar{Name: "v", Values: []string{"a", "b", "c"}, Default: []string{"a"}, Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", "b,c", "-v", "a"},
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "enum_slice_invalid",
+			setup: func(f *FlagSection) func() any {
+				var v []string
+				f.EnumSliceVar(&EnumSliceVar{Name: "v", Values: []string{"a", "b"}, Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", "a,z"},
+			wantErr: "must be one of a, b",
+		},
+		{
+			name: "url",
+			setup: func(f *FlagSection) func() any {
+				var v url.URL
+				f.URLVar(&URLVar{Name: "v", Target: &v})
+				return func() any { return v.String() }
+			},
+			args: []string{"-v", "https://example.com/foo?bar=baz"},
+			want: "https://example.com/foo?bar=baz",
+		},
+		{
+			name: "url_missing_scheme",
+			setup: func(f *FlagSection) func() any {
+				var v url.URL
+				f.URLVar(&URLVar{Name: "v", Target: &v})
+				return func() any { return v.String() }
+			},
+			args:    []string{"-v", "example.com"},
+			wantErr: `invalid url "example.com": missing scheme`,
+		},
+		{
+			name: "url_slice",
+			setup: func(f *FlagSection) func() any {
+				var v []url.URL
+				f.URLSliceVar(&URLSliceVar{Name: "v", Target: &v})
+				return func() any { return slicePrinter(printURL)(v) }
+			},
+			args: []string{"-v", "https://a.com,https://b.com"},
+			want: "https://a.com,https://b.com",
+		},
+		{
+			name: "ip",
+			setup: func(f *FlagSection) func() any {
+				var v netip.Addr
+				f.IPVar(&IPVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", "2001:db8::1"},
+			want: netip.MustParseAddr("2001:db8::1"),
+		},
+		{
+			name: "ip_invalid",
+			setup: func(f *FlagSection) func() any {
+				var v netip.Addr
+				f.IPVar(&IPVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", "10.0.0.256"},
+			wantErr: "invalid ip address",
+		},
+		{
+			name: "ip_slice",
+			setup: func(f *FlagSection) func() any {
+				var v []netip.Addr
+				f.IPSliceVar(&IPSliceVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", "10.0.0.1, 10.0.0.2"},
+			want: []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")},
+		},
+		{
+			name: "cidr",
+			setup: func(f *FlagSection) func() any {
+				var v netip.Prefix
+				f.CIDRVar(&CIDRVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", "10.0.0.0/8"},
+			want: netip.MustParsePrefix("10.0.0.0/8"),
+		},
+		{
+			name: "cidr_invalid",
+			setup: func(f *FlagSection) func() any {
+				var v netip.Prefix
+				f.CIDRVar(&CIDRVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", "10.0.0.0"},
+			wantErr: "invalid cidr",
+		},
+		{
+			name: "cidr_slice",
+			setup: func(f *FlagSection) func() any {
+				var v []netip.Prefix
+				f.CIDRSliceVar(&CIDRSliceVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", "10.0.0.0/8", "-v", "fd00::/8"},
+			want: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")},
+		},
+		{
+			name: "regexp",
+			setup: func(f *FlagSection) func() any {
+				var v *regexp.Regexp
+				f.RegexpVar(&RegexpVar{Name: "v", Target: &v})
+				return func() any { return printRegexp(v) }
+			},
+			args: []string{"-v", `^a{1,3}\d$`},
+			want: `^a{1,3}\d$`,
+		},
+		{
+			name: "regexp_invalid",
+			setup: func(f *FlagSection) func() any {
+				var v *regexp.Regexp
+				f.RegexpVar(&RegexpVar{Name: "v", Target: &v})
+				return func() any { return printRegexp(v) }
+			},
+			args:    []string{"-v", `(`},
+			wantErr: "invalid regular expression",
+		},
+		{
+			name: "regexp_slice_not_split",
+			setup: func(f *FlagSection) func() any {
+				var v []*regexp.Regexp
+				f.RegexpSliceVar(&RegexpSliceVar{Name: "v", Target: &v})
+				return func() any { return len(v) }
+			},
+			args: []string{"-v", `a{1,3}`, "-v", `b`},
+			want: 2,
+		},
+		{
+			name: "byte_size",
+			setup: func(f *FlagSection) func() any {
+				var v uint64
+				f.ByteSizeVar(&ByteSizeVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", "10MiB"},
+			want: uint64(10 << 20),
+		},
+		{
+			name: "byte_size_invalid",
+			setup: func(f *FlagSection) func() any {
+				var v uint64
+				f.ByteSizeVar(&ByteSizeVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", "10XB"},
+			wantErr: `unknown unit "XB"`,
+		},
+		{
+			name: "byte_size_slice",
+			setup: func(f *FlagSection) func() any {
+				var v []uint64
+				f.ByteSizeSliceVar(&ByteSizeSliceVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", "1kB,2KiB"},
+			want: []uint64{1000, 2048},
+		},
+		{
+			name: "file",
+			setup: func(f *FlagSection) func() any {
+				var v string
+				f.FileVar(&FileVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", file},
+			want: file,
+		},
+		{
+			name: "file_is_dir",
+			setup: func(f *FlagSection) func() any {
+				var v string
+				f.FileVar(&FileVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", dir},
+			wantErr: "is a directory",
+		},
+		{
+			name: "file_missing",
+			setup: func(f *FlagSection) func() any {
+				var v string
+				f.FileVar(&FileVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", missing},
+			wantErr: "no such file or directory",
+		},
+		{
+			name: "file_slice",
+			setup: func(f *FlagSection) func() any {
+				var v []string
+				f.FileSliceVar(&FileSliceVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", file, "-v", file},
+			want: []string{file, file},
+		},
+		{
+			name: "dir",
+			setup: func(f *FlagSection) func() any {
+				var v string
+				f.DirVar(&DirVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args: []string{"-v", dir},
+			want: dir,
+		},
+		{
+			name: "dir_is_file",
+			setup: func(f *FlagSection) func() any {
+				var v string
+				f.DirVar(&DirVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", file},
+			wantErr: "is not a directory",
+		},
+		{
+			name: "dir_slice_missing",
+			setup: func(f *FlagSection) func() any {
+				var v []string
+				f.DirSliceVar(&DirSliceVar{Name: "v", Target: &v})
+				return func() any { return v }
+			},
+			args:    []string{"-v", dir + "," + missing},
+			wantErr: "no such file or directory",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			set := NewFlagSet(WithLookupEnv(MapLookuper(nil)))
+			get := tc.setup(set.NewSection("OPTIONS"))
+
+			err := set.Parse(tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if tc.wantErr != "" {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want, get(), cmp.Comparer(func(a, b netip.Addr) bool { return a == b }),
+				cmp.Comparer(func(a, b netip.Prefix) bool { return a == b })); diff != "" {
+				t.Errorf("value (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFlagSection_FileVar_workingDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var file, sub string
+	set := NewFlagSet(WithWorkingDir(func() (string, error) { return dir, nil }))
+	f := set.NewSection("OPTIONS")
+	f.FileVar(&FileVar{Name: "file", Target: &file})
+	f.DirVar(&DirVar{Name: "dir", Target: &sub})
+
+	if err := set.Parse([]string{"-file", "sub/file.txt", "-dir", "sub"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := file, filepath.Join(dir, "sub", "file.txt"); got != want {
+		t.Errorf("expected file %q to be %q", got, want)
+	}
+	if got, want := sub, filepath.Join(dir, "sub"); got != want {
+		t.Errorf("expected dir %q to be %q", got, want)
+	}
+}
+
+func TestFlagSection_EnumVar_helpAndPredict(t *testing.T) {
+	t.Parallel()
+
+	var v string
+	set := NewFlagSet()
+	set.NewSection("OPTIONS").EnumVar(&EnumVar{
+		Name:   "format",
+		Usage:  "The format.",
+		Values: []string{"json", "text"},
+		Target: &v,
+	})
+
+	if got, want := set.Help(), "The format. Valid values include: json, text."; !strings.Contains(got, want) {
+		t.Errorf("expected\n\n%s\n\nto include %q", got, want)
+	}
+
+	typ, ok := set.Lookup("format").Value.(Value)
+	if !ok {
+		t.Fatal("expected flag to implement Value")
+	}
+	if diff := cmp.Diff(predict.Set{"json", "text"}, typ.Predictor()); diff != "" {
+		t.Errorf("predictor (-want, +got):\n%s", diff)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in      string
+		want    uint64
+		wantErr string
+	}{
+		{in: "0", want: 0},
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "1kB", want: 1000},
+		{in: "1KB", want: 1000},
+		{in: "1KiB", want: 1024},
+		{in: "10MiB", want: 10 << 20},
+		{in: "10 mib", want: 10 << 20},
+		{in: "1.5GB", want: 1_500_000_000},
+		{in: "1.5KiB", want: 1536},
+		{in: "16EiB", wantErr: "value out of range"},
+		{in: "MiB", wantErr: "missing number"},
+		{in: "1.2.3MB", wantErr: "invalid byte size"},
+		{in: "-1MB", wantErr: "missing number"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseByteSize(tc.in)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got != tc.want {
+				t.Errorf("expected %d to be %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in   uint64
+		want string
+	}{
+		{in: 0, want: "0B"},
+		{in: 1, want: "1B"},
+		{in: 1000, want: "1kB"},
+		{in: 1024, want: "1KiB"},
+		{in: 1500, want: "1500B"},
+		{in: 10 << 20, want: "10MiB"},
+		{in: 2_000_000_000, want: "2GB"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.want, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatByteSize(tc.in)
+			if got != tc.want {
+				t.Errorf("expected %q to be %q", got, tc.want)
+			}
+
+			// Formatted sizes round-trip through the parser.
+			parsed, err := parseByteSize(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed != tc.in {
+				t.Errorf("expected %d to be %d", parsed, tc.in)
+			}
+		})
+	}
+}
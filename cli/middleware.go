@@ -0,0 +1,102 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// Invocation describes a single dispatch of a subcommand by a [RootCommand].
+type Invocation struct {
+	// Path is the full command path, starting with the name of the top-level
+	// root (e.g. "tool child leaf"). Aliases are resolved to the command name.
+	Path string
+
+	// Command is the subcommand which is being run.
+	Command Command
+
+	// Args are the arguments which are passed to the subcommand's Run function.
+	// Persistent flags have already been removed.
+	Args []string
+
+	// Flags are the parsed flags. Before the subcommand runs, these are the
+	// persistent flags, including those inherited from parent commands. The
+	// subcommand parses its own flags in its Run function; once it does so with a
+	// flag set from [BaseCommand.NewFlagSet], Flags is replaced by that flag set,
+	// which also includes the persistent flags. Middleware can therefore read
+	// the subcommand's flags after next returns.
+	Flags *FlagSet
+}
+
+// Handler runs an invocation.
+type Handler func(ctx context.Context, inv *Invocation) error
+
+// Middleware wraps the [Handler] which runs a subcommand. Middleware can run
+// logic before and after the subcommand, modify the context, or skip the
+// subcommand entirely by not calling next.
+//
+//	func Timer(next cli.Handler) cli.Handler {
+//		return func(ctx context.Context, inv *cli.Invocation) error {
+//			start := time.Now()
+//			defer func() {
+//				inv.Command.Errf("%s took %s", inv.Path, time.Since(start))
+//			}()
+//			return next(ctx, inv)
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
+// middlewareChain returns the middleware which applies to subcommands of this
+// root, with the middleware of parent commands first.
+func (r *RootCommand) middlewareChain() []Middleware {
+	var chain []Middleware
+	for cur := r; cur != nil; cur = cur.parent {
+		chain = append(append([]Middleware{}, cur.Middleware...), chain...)
+	}
+	return chain
+}
+
+// buildHandler wraps the given handler in the middleware. The first middleware
+// is the outermost.
+func buildHandler(h Handler, middleware []Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		if mw := middleware[i]; mw != nil {
+			h = mw(h)
+		}
+	}
+	return h
+}
+
+// runInvocation is the innermost [Handler], which runs the subcommand.
+func runInvocation(ctx context.Context, inv *Invocation) error {
+	//nolint:wrapcheck // We want to bubble this error exactly as-is.
+	return inv.Command.Run(ctx, inv.Args)
+}
+
+// RecoverPanics is [Middleware] which converts a panic in a subcommand into an
+// error. The stack trace is printed to the command's [Stderr].
+func RecoverPanics(next Handler) Handler {
+	return func(ctx context.Context, inv *Invocation) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				inv.Command.Errf("%s", debug.Stack())
+				err = fmt.Errorf("%s panicked: %v", inv.Path, p)
+			}
+		}()
+		return next(ctx, inv)
+	}
+}
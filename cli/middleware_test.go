@@ -0,0 +1,231 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestRootCommand_Middleware(t *testing.T) {
+	t.Parallel()
+
+	type ctxKey struct{}
+
+	// recorder returns middleware which records when it is entered and exited,
+	// along with the invocation details.
+	recorder := func(name string, events *[]string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, inv *Invocation) error {
+				var region string
+				if f := inv.Flags.Lookup("region"); f != nil {
+					region = f.Value.String()
+				}
+				*events = append(*events, fmt.Sprintf("%s: enter %s %v region=%s", name, inv.Path, inv.Args, region))
+				err := next(context.WithValue(ctx, ctxKey{}, name), inv)
+				*events = append(*events, fmt.Sprintf("%s: exit %v", name, err))
+				return err
+			}
+		}
+	}
+
+	rootCmd := func(events *[]string) *RootCommand {
+		var region string
+		return &RootCommand{
+			Name: "test",
+			PersistentFlags: func(set *FlagSet) {
+				set.NewSection("GLOBAL OPTIONS").StringVar(&StringVar{
+					Name:   "region",
+					Target: &region,
+				})
+			},
+			Middleware: []Middleware{
+				recorder("outer", events),
+				recorder("inner", events),
+			},
+			Commands: map[string]CommandFactory{
+				"leaf": func() Command {
+					return &TestCommand{
+						RunFunc: func(ctx context.Context, c *TestCommand) {
+							*events = append(*events, fmt.Sprintf("run ctx=%v", ctx.Value(ctxKey{})))
+						},
+					}
+				},
+				"error": func() Command {
+					return &TestCommand{
+						Error: fmt.Errorf("oops"),
+					}
+				},
+				"child": func() Command {
+					return &RootCommand{
+						Name:       "child",
+						Middleware: []Middleware{recorder("child", events)},
+						Commands: map[string]CommandFactory{
+							"leaf": func() Command {
+								return &TestCommand{}
+							},
+						},
+					}
+				},
+			},
+			Aliases: map[string]string{
+				"l": "leaf",
+			},
+		}
+	}
+
+	cases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "order",
+			args: []string{"-region", "us", "leaf", "a"},
+			want: []string{
+				"outer: enter test leaf [a] region=us",
+				"inner: enter test leaf [a] region=us",
+				"run ctx=inner",
+				"inner: exit <nil>",
+				"outer: exit <nil>",
+			},
+		},
+		{
+			name: "alias",
+			args: []string{"l"},
+			want: []string{
+				"outer: enter test leaf [] region=",
+				"inner: enter test leaf [] region=",
+				"run ctx=inner",
+				"inner: exit <nil>",
+				"outer: exit <nil>",
+			},
+		},
+		{
+			name: "error",
+			args: []string{"error"},
+			want: []string{
+				"outer: enter test error [] region=",
+				"inner: enter test error [] region=",
+				"inner: exit oops",
+				"outer: exit oops",
+			},
+			wantErr: "oops",
+		},
+		{
+			name: "nested",
+			args: []string{"child", "leaf", "-region", "eu"},
+			want: []string{
+				"outer: enter test child leaf [] region=eu",
+				"inner: enter test child leaf [] region=eu",
+				"child: enter test child leaf [] region=eu",
+				"child: exit <nil>",
+				"inner: exit <nil>",
+				"outer: exit <nil>",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var events []string
+			cmd := rootCmd(&events)
+			cmd.Pipe()
+
+			err := cmd.Run(t.Context(), tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, events); diff != "" {
+				t.Errorf("events (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRootCommand_Middleware_flags(t *testing.T) {
+	t.Parallel()
+
+	var before, after string
+	rootCmd := &RootCommand{
+		Name: "test",
+		Middleware: []Middleware{
+			func(next Handler) Handler {
+				return func(ctx context.Context, inv *Invocation) error {
+					if f := inv.Flags.Lookup("string"); f != nil {
+						before = f.Value.String()
+					}
+					err := next(ctx, inv)
+					if f := inv.Flags.Lookup("string"); f != nil {
+						after = f.Value.String()
+					}
+					return err
+				}
+			},
+		},
+		Commands: map[string]CommandFactory{
+			"leaf": func() Command {
+				return &TestCommand{}
+			},
+		},
+	}
+	rootCmd.Pipe()
+
+	if err := rootCmd.Run(t.Context(), []string{"leaf", "-string", "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := before, ""; got != want {
+		t.Errorf("expected flag before next %q to be %q", got, want)
+	}
+	if got, want := after, "value"; got != want {
+		t.Errorf("expected flag after next %q to be %q", got, want)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	t.Parallel()
+
+	cmd := &RootCommand{
+		Name:       "test",
+		Middleware: []Middleware{RecoverPanics},
+		Commands: map[string]CommandFactory{
+			"panic": func() Command {
+				return &TestCommand{
+					RunFunc: func(ctx context.Context, c *TestCommand) {
+						panic("boom")
+					},
+				}
+			},
+		},
+	}
+	_, _, stderr := cmd.Pipe()
+
+	err := cmd.Run(t.Context(), []string{"panic"})
+	if diff := testutil.DiffErrString(err, "test panic panicked: boom"); diff != "" {
+		t.Error(diff)
+	}
+	if got, want := stderr.String(), "goroutine"; !strings.Contains(got, want) {
+		t.Errorf("expected stderr %q to contain %q", got, want)
+	}
+}
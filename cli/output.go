@@ -0,0 +1,388 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/mattn/go-isatty"
+	"github.com/posener/complete/v2/predict"
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat is the format in which [BaseCommand.Render] writes values.
+type OutputFormat string
+
+const (
+	// OutputFormatAuto renders a table when stdout is a terminal and JSON
+	// otherwise.
+	OutputFormatAuto OutputFormat = ""
+
+	// OutputFormatTable renders values as aligned text tables.
+	OutputFormatTable OutputFormat = "table"
+
+	// OutputFormatJSON renders values as indented JSON.
+	OutputFormatJSON OutputFormat = "json"
+
+	// OutputFormatYAML renders values as YAML.
+	OutputFormatYAML OutputFormat = "yaml"
+
+	// OutputFormatTemplate is the prefix for rendering values with a Go
+	// [text/template]. The template follows the prefix, for example:
+	//
+	//	template={{ .Name }}
+	OutputFormatTemplate OutputFormat = "template="
+)
+
+// outputFormatNames are the user-facing names of the output formats.
+var outputFormatNames = []string{"auto", "json", "table", "template=", "yaml"}
+
+// ParseOutputFormat parses the given string as an [OutputFormat]. Valid values
+// are "auto", "table", "json", "yaml", or "template=" followed by a Go
+// template.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch v := OutputFormat(strings.TrimSpace(s)); v {
+	case "auto", OutputFormatAuto:
+		return OutputFormatAuto, nil
+	case OutputFormatTable, OutputFormatJSON, OutputFormatYAML:
+		return v, nil
+	default:
+		if tmpl, ok := strings.CutPrefix(string(v), string(OutputFormatTemplate)); ok {
+			if _, err := template.New("").Parse(tmpl); err != nil {
+				return "", fmt.Errorf("invalid output template: %w", err)
+			}
+			return v, nil
+		}
+		return "", fmt.Errorf("invalid output format %q, valid values include: %s",
+			s, strings.Join(outputFormatNames, ", "))
+	}
+}
+
+type OutputFormatVar struct {
+	Default         OutputFormat
+	EnvVar          string
+	Target          *OutputFormat
+	AllowFromFile   bool
+	AllowFromPrompt bool
+}
+
+// OutputFormatVar creates a new "-output" flag (aliased "-o") which controls the
+// format of [BaseCommand.Render]. By default, the output format is chosen
+// automatically based on whether stdout is a terminal.
+func (f *FlagSection) OutputFormatVar(i *OutputFormatVar) {
+	printer := func(v OutputFormat) string { return string(v) }
+
+	Flag(f, &Var[OutputFormat]{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage: `Format in which to write output. Valid values include: ` +
+			strings.Join(outputFormatNames, ",") + `. When unset, output is ` +
+			`rendered as a table in an interactive terminal and as JSON otherwise.`,
+		Example:         "json",
+		Default:         i.Default,
+		EnvVar:          i.EnvVar,
+		Predict:         predict.Set(outputFormatNames),
+		Target:          i.Target,
+		Parser:          ParseOutputFormat,
+		Printer:         printer,
+		AllowFromFile:   i.AllowFromFile,
+		AllowFromPrompt: i.AllowFromPrompt,
+	})
+}
+
+// Render writes the value to [BaseCommand.Stdout] in the given format. If the
+// format is [OutputFormatAuto], the value is rendered as a table when stdout is
+// a terminal and as JSON otherwise. Table headers are only styled when
+// [BaseCommand.OutStyle] is enabled.
+//
+// Tables are built from slices of structs or maps, with one row per element and
+// one column per exported field or key. Single structs and maps are rendered as
+// a two-column list of keys and values.
+func (c *BaseCommand) Render(format OutputFormat, v any) error {
+	stdout := c.Stdout()
+	tty := isTerminal(stdout)
+
+	if format == OutputFormatAuto {
+		format = OutputFormatJSON
+		if tty {
+			format = OutputFormatTable
+		}
+	}
+
+	if err := renderOutput(stdout, format, v, c.OutStyle().Enabled()); err != nil {
+		return fmt.Errorf("failed to render output: %w", err)
+	}
+	return nil
+}
+
+// renderOutput writes v to w in the given format. If color is true, table
+// headers are styled.
+func renderOutput(w io.Writer, format OutputFormat, v any, color bool) error {
+	switch format {
+	case OutputFormatJSON:
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal json: %w", err)
+		}
+		return writeWithNewline(w, b)
+	case OutputFormatYAML:
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to marshal yaml: %w", err)
+		}
+		return writeWithNewline(w, b)
+	case OutputFormatTable:
+		return writeWithNewline(w, []byte(renderTable(v, color)))
+	case OutputFormatAuto:
+	}
+
+	if tmpl, ok := strings.CutPrefix(string(format), string(OutputFormatTemplate)); ok {
+		t, err := template.New("output").Parse(tmpl)
+		if err != nil {
+			return fmt.Errorf("failed to parse template: %w", err)
+		}
+
+		var b bytes.Buffer
+		if err := t.Execute(&b, v); err != nil {
+			return fmt.Errorf("failed to execute template: %w", err)
+		}
+		return writeWithNewline(w, b.Bytes())
+	}
+
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// writeWithNewline writes b to w, appending a trailing newline if one is not
+// present.
+func writeWithNewline(w io.Writer, b []byte) error {
+	if len(b) == 0 || b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// renderTable renders the value as an aligned text table.
+func renderTable(v any, color bool) string {
+	rv := indirect(reflect.ValueOf(v))
+
+	var header []string
+	var rows [][]string
+
+	switch {
+	case !rv.IsValid():
+		return ""
+	case isScalar(rv):
+		return formatCell(rv)
+	case rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array:
+		var elems []reflect.Value
+		for i := 0; i < rv.Len(); i++ {
+			elems = append(elems, indirect(rv.Index(i)))
+		}
+		header, rows = tabulate(elems)
+	default:
+		// A single record is rendered as a list of keys and values.
+		keys, values := columns(rv)
+		header = []string{"KEY", "VALUE"}
+		for i := range keys {
+			rows = append(rows, []string{keys[i], values[i]})
+		}
+	}
+
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 4, 3, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	_ = tw.Flush()
+
+	// Trailing padding is left behind for rows with empty trailing cells.
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	out := strings.Join(lines, "\n")
+
+	// Style the header after alignment, since escape sequences would otherwise
+	// be counted towards the column width.
+	if color && header != nil {
+		first, rest, _ := strings.Cut(out, "\n")
+		out = ansiBold + first + ansiReset
+		if rest != "" {
+			out += "\n" + rest
+		}
+	}
+	return out
+}
+
+// tabulate converts the list of values into a header and rows. The header is
+// the union of all columns, in the order in which they first appear. Scalar
+// values are rendered as a single "VALUE" column.
+func tabulate(elems []reflect.Value) ([]string, [][]string) {
+	var header []string
+	index := make(map[string]int)
+	records := make([]map[string]string, 0, len(elems))
+
+	for _, elem := range elems {
+		record := make(map[string]string)
+
+		if (elem.Kind() == reflect.Struct || elem.Kind() == reflect.Map) && !isScalar(elem) {
+			keys, values := columns(elem)
+			for i, key := range keys {
+				record[key] = values[i]
+			}
+			for _, key := range keys {
+				if _, ok := index[key]; !ok {
+					index[key] = len(header)
+					header = append(header, key)
+				}
+			}
+		} else {
+			if _, ok := index["VALUE"]; !ok {
+				index["VALUE"] = len(header)
+				header = append(header, "VALUE")
+			}
+			record["VALUE"] = formatCell(elem)
+		}
+
+		records = append(records, record)
+	}
+
+	rows := make([][]string, 0, len(records))
+	for _, record := range records {
+		row := make([]string, len(header))
+		for i, key := range header {
+			row[i] = record[key]
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// isScalar returns true if the value is rendered as a single cell rather than
+// as columns. This includes values which implement [fmt.Stringer], such as
+// [time.Time], and structs without exported fields.
+func isScalar(rv reflect.Value) bool {
+	typ := rv.Type()
+	if typ.Implements(stringerType) {
+		return true
+	}
+
+	switch rv.Kind() { //nolint:exhaustive // All other kinds are scalars.
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if typ.Field(i).IsExported() {
+				return false
+			}
+		}
+		return true
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return false
+	default:
+		return true
+	}
+}
+
+// stringerType is the type of [fmt.Stringer].
+var stringerType = reflect.TypeFor[fmt.Stringer]()
+
+// columns returns the column names and formatted values for a struct or map.
+// Struct columns use the name from the "json" tag if present, and fields with a
+// "-" tag are skipped. Map columns are sorted by key.
+func columns(rv reflect.Value) ([]string, []string) {
+	var keys, values []string
+
+	switch rv.Kind() { //nolint:exhaustive // Only structs and maps are supported.
+	case reflect.Struct:
+		typ := rv.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := field.Name
+			if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+
+			keys = append(keys, strings.ToUpper(name))
+			values = append(values, formatCell(indirect(rv.Field(i))))
+		}
+	case reflect.Map:
+		mapKeys := rv.MapKeys()
+		sort.Slice(mapKeys, func(i, j int) bool {
+			return fmt.Sprint(mapKeys[i].Interface()) < fmt.Sprint(mapKeys[j].Interface())
+		})
+		for _, k := range mapKeys {
+			keys = append(keys, strings.ToUpper(fmt.Sprint(k.Interface())))
+			values = append(values, formatCell(indirect(rv.MapIndex(k))))
+		}
+	}
+	return keys, values
+}
+
+// formatCell formats a single value for a table cell. Tabs and newlines are
+// replaced with spaces so they do not break alignment.
+func formatCell(rv reflect.Value) string {
+	if !rv.IsValid() {
+		return ""
+	}
+
+	var s string
+	if rv.CanInterface() {
+		s = fmt.Sprint(rv.Interface())
+	} else {
+		s = rv.String()
+	}
+	return strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ").Replace(s)
+}
+
+// indirect dereferences pointers and interfaces until it reaches a concrete
+// value. It returns the zero [reflect.Value] for nil pointers.
+func indirect(rv reflect.Value) reflect.Value {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// isTerminal returns true if the writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -0,0 +1,257 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+type outputTestRecord struct {
+	Name    string `json:"name" yaml:"name"`
+	Count   int    `json:"count" yaml:"count"`
+	Ignored string `json:"-" yaml:"-"`
+	private string
+}
+
+func TestOutputFormatVar(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		args []string
+
+		want    OutputFormat
+		wantErr string
+	}{
+		{
+			name: "default",
+			want: OutputFormatAuto,
+		},
+		{
+			name: "long",
+			args: []string{"-output", "json"},
+			want: OutputFormatJSON,
+		},
+		{
+			name: "short",
+			args: []string{"-o", "yaml"},
+			want: OutputFormatYAML,
+		},
+		{
+			name: "auto",
+			args: []string{"-o", "auto"},
+			want: OutputFormatAuto,
+		},
+		{
+			name: "template",
+			args: []string{"-o", "template={{ .Name }}"},
+			want: OutputFormat("template={{ .Name }}"),
+		},
+		{
+			name: "template_whitespace",
+			args: []string{"-o", " template={{ .Name }} "},
+			want: OutputFormat("template={{ .Name }}"),
+		},
+		{
+			name:    "invalid_template",
+			args:    []string{"-o", "template={{ .Name"},
+			wantErr: "invalid output template",
+		},
+		{
+			name:    "invalid",
+			args:    []string{"-output", "xml"},
+			wantErr: `invalid value "xml" for flag -output`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got OutputFormat
+			set := NewFlagSet()
+			f := set.NewSection("OUTPUT OPTIONS")
+			f.OutputFormatVar(&OutputFormatVar{
+				Target: &got,
+			})
+
+			err := set.Parse(tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q to be %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBaseCommand_Render(t *testing.T) {
+	t.Parallel()
+
+	records := []*outputTestRecord{
+		{Name: "apple", Count: 12, Ignored: "x", private: "y"},
+		{Name: "banana", Count: 3},
+	}
+
+	cases := []struct {
+		name   string
+		format OutputFormat
+		value  any
+
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "auto_not_terminal",
+			format: OutputFormatAuto,
+			value:  records[1],
+			want:   "{\n  \"name\": \"banana\",\n  \"count\": 3\n}\n",
+		},
+		{
+			name:   "json",
+			format: OutputFormatJSON,
+			value:  records,
+			want: "[\n" +
+				"  {\n    \"name\": \"apple\",\n    \"count\": 12\n  },\n" +
+				"  {\n    \"name\": \"banana\",\n    \"count\": 3\n  }\n" +
+				"]\n",
+		},
+		{
+			name:   "yaml",
+			format: OutputFormatYAML,
+			value:  records,
+			want: "- name: apple\n" +
+				"  count: 12\n" +
+				"- name: banana\n" +
+				"  count: 3\n",
+		},
+		{
+			name:   "table_slice",
+			format: OutputFormatTable,
+			value:  records,
+			want: "NAME     COUNT\n" +
+				"apple    12\n" +
+				"banana   3\n",
+		},
+		{
+			name:   "table_struct",
+			format: OutputFormatTable,
+			value:  records[0],
+			want: "KEY     VALUE\n" +
+				"NAME    apple\n" +
+				"COUNT   12\n",
+		},
+		{
+			name:   "table_maps",
+			format: OutputFormatTable,
+			value: []map[string]any{
+				{"zone": "us-east1", "id": 1},
+				{"id": 2, "zone": "us-west1\tb", "extra": true},
+			},
+			want: "ID   ZONE         EXTRA\n" +
+				"1    us-east1\n" +
+				"2    us-west1 b   true\n",
+		},
+		{
+			name:   "table_scalars",
+			format: OutputFormatTable,
+			value:  []string{"one", "two"},
+			want:   "VALUE\none\ntwo\n",
+		},
+		{
+			name:   "table_stringers",
+			format: OutputFormatTable,
+			value: []time.Time{
+				time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+			want: "VALUE\n2023-01-02 03:04:05 +0000 UTC\n",
+		},
+		{
+			name:   "table_no_exported_fields",
+			format: OutputFormatTable,
+			value:  []struct{ private string }{{private: "x"}},
+			want:   "VALUE\n{x}\n",
+		},
+		{
+			name:   "table_scalar",
+			format: OutputFormatTable,
+			value:  42,
+			want:   "42\n",
+		},
+		{
+			name:   "template",
+			format: OutputFormat(`template={{ range . }}{{ .Name }}={{ .Count }}{{ "\n" }}{{ end }}`),
+			value:  records,
+			want:   "apple=12\nbanana=3\n",
+		},
+		{
+			name:   "template_newline",
+			format: OutputFormat(`template={{ .Name }}`),
+			value:  records[0],
+			want:   "apple\n",
+		},
+		{
+			name:    "template_error",
+			format:  OutputFormat(`template={{ .Missing }}`),
+			value:   records[0],
+			wantErr: "failed to execute template",
+		},
+		{
+			name:    "unknown",
+			format:  OutputFormat("xml"),
+			value:   records,
+			wantErr: `unknown output format "xml"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cmd BaseCommand
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Render(tc.format, tc.value)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+
+			if diff := cmp.Diff(tc.want, stdout.String()); diff != "" {
+				t.Errorf("output (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderTable_color(t *testing.T) {
+	t.Parallel()
+
+	got := renderTable([]*outputTestRecord{{Name: "apple", Count: 1}}, true)
+	want := "\x1b[1mNAME    COUNT\x1b[0m\napple   1"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("table (-want, +got):\n%s", diff)
+	}
+
+	if got := renderTable(nil, true); strings.Contains(got, "\x1b") {
+		t.Errorf("expected no styling for empty output, got %q", got)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+)
+
+// plugin is an external executable which is run as a subcommand.
+type plugin struct {
+	name string
+	path string
+}
+
+// pluginPrefix returns the prefix of plugin executables for this command. For
+// nested commands, the names are joined with dashes (e.g. "tool-child-").
+func (r *RootCommand) pluginPrefix() string {
+	return strings.Join(strings.Fields(r.Name), "-") + "-"
+}
+
+// plugins searches $PATH for plugin executables for this command. If the same
+// plugin exists in multiple directories, the first one in $PATH wins, which
+// matches the behavior of the shell. The result is sorted by name. It returns
+// nil if plugins are not enabled.
+func (r *RootCommand) plugins() []*plugin {
+	if !r.EnablePlugins {
+		return nil
+	}
+
+	prefix := r.pluginPrefix()
+	pathExts := executableExtensions(r.GetEnv("PATHEXT"))
+
+	seen := make(map[string]struct{})
+	var plugins []*plugin
+	for _, dir := range filepath.SplitList(r.GetEnv("PATH")) {
+		if dir == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			// Directories in $PATH which do not exist or cannot be read are
+			// ignored, like the shell does.
+			continue
+		}
+
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name(), prefix, pathExts)
+			if !ok {
+				continue
+			}
+			if _, ok := seen[name]; ok {
+				continue
+			}
+
+			pth := filepath.Join(dir, entry.Name())
+			if !isExecutable(pth, pathExts) {
+				continue
+			}
+
+			seen[name] = struct{}{}
+			plugins = append(plugins, &plugin{name: name, path: pth})
+		}
+	}
+
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].name < plugins[j].name
+	})
+	return plugins
+}
+
+// lookupPlugin finds the plugin with the given name.
+func (r *RootCommand) lookupPlugin(name string) (*plugin, bool) {
+	if !r.EnablePlugins || name == "" {
+		return nil, false
+	}
+
+	for _, p := range r.plugins() {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// pluginsHelp returns the help section listing the available plugins, or the
+// empty string if there are none.
+func (r *RootCommand) pluginsHelp() string {
+	var plugins []*plugin
+	for _, p := range r.plugins() {
+		// Commands take precedence over plugins of the same name.
+		if _, ok := r.lookupDefinedCommand(p.name); ok {
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	if len(plugins) == 0 {
+		return ""
+	}
+
+	longest := 0
+	for _, p := range plugins {
+		if l := len(p.name); l > longest {
+			longest = l
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprint(&b, "PLUGINS\n\n")
+	for _, p := range plugins {
+		fmt.Fprintf(&b, "  %-*s%s\n", longest+4, p.name, p.path)
+	}
+	return b.String()
+}
+
+// pluginName returns the plugin name for the given file name, if it is a
+// plugin for the given prefix. On Windows, the executable extension is removed.
+func pluginName(file, prefix string, pathExts []string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(file))
+		for _, v := range pathExts {
+			if ext == v {
+				file = strings.TrimSuffix(file, filepath.Ext(file))
+				break
+			}
+		}
+	}
+
+	name, ok := strings.CutPrefix(file, prefix)
+	if !ok || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// isExecutable returns true if the file at the given path is a regular file
+// which can be executed. On Windows, this is determined by the file extension.
+func isExecutable(pth string, pathExts []string) bool {
+	info, err := os.Stat(pth)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(pth))
+		for _, v := range pathExts {
+			if ext == v {
+				return true
+			}
+		}
+		return false
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+// executableExtensions parses the Windows $PATHEXT value into a list of
+// lowercase extensions.
+func executableExtensions(pathExt string) []string {
+	if pathExt == "" {
+		pathExt = ".com;.exe;.bat;.cmd"
+	}
+
+	var exts []string
+	for _, v := range strings.Split(strings.ToLower(pathExt), ";") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if !strings.HasPrefix(v, ".") {
+			v = "." + v
+		}
+		exts = append(exts, v)
+	}
+	return exts
+}
+
+var (
+	_ Command      = (*pluginCommand)(nil)
+	_ ArgPredictor = (*pluginCommand)(nil)
+)
+
+// pluginCommand is a [Command] which runs a plugin executable.
+type pluginCommand struct {
+	BaseCommand
+
+	plugin *plugin
+}
+
+func (c *pluginCommand) Desc() string {
+	return fmt.Sprintf("Plugin at %s", c.plugin.path)
+}
+
+func (c *pluginCommand) Help() string {
+	return fmt.Sprintf("Usage: {{ COMMAND }} [args...]\n\n"+
+		"  Runs the plugin at %s. Run the plugin with -help for more information.",
+		c.plugin.path)
+}
+
+func (c *pluginCommand) PredictArgs() complete.Predictor {
+	return predict.Something
+}
+
+// Run executes the plugin with the given arguments. The plugin inherits the
+// standard streams and environment of the command (see [pluginCommand.environ]).
+// If the plugin exits with a non-zero status, it is expected to have reported
+// its own error, so the status is returned as an [ExitError] without a message.
+func (c *pluginCommand) Run(ctx context.Context, args []string) error {
+	cmd := exec.CommandContext(ctx, c.plugin.path, args...)
+	cmd.Stdin = c.Stdin()
+	cmd.Stdout = c.Stdout()
+	cmd.Stderr = c.Stderr()
+	cmd.Env = c.environ()
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return &ExitError{Code: exitErr.ExitCode()}
+		}
+		return fmt.Errorf("failed to run plugin %s: %w", c.plugin.name, err)
+	}
+	return nil
+}
+
+// environ returns the environment of the plugin. Environment lookups cannot be
+// listed, so each variable of the current process is resolved with
+// [BaseCommand.LookupEnv], and variables which it does not find are removed.
+// PATH and PATHEXT, which are used to find plugins, are always resolved so the
+// plugin sees the same values.
+func (c *pluginCommand) environ() []string {
+	keys := []string{"PATH", "PATHEXT"}
+	for _, kv := range os.Environ() {
+		// On Windows, the environment contains entries like "=C:=C:\" which
+		// have no key.
+		if k, _, _ := strings.Cut(kv, "="); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		id := k
+		if runtime.GOOS == "windows" {
+			id = strings.ToUpper(k)
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		if v, ok := c.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return env
+}
@@ -0,0 +1,212 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+// writePlugin writes a shell script plugin with the given name and body to dir.
+func writePlugin(tb testing.TB, dir, name, body string, mode os.FileMode) {
+	tb.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body+"\n"), mode); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestRootCommand_plugins(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+
+	dir1 := t.TempDir()
+	dir2 := t.TempDir()
+
+	writePlugin(t, dir1, "test-hello", `echo "hello $@"`, 0o755)
+	writePlugin(t, dir1, "test-fail", `echo "failing" >&2; exit 3`, 0o755)
+	writePlugin(t, dir1, "test-one", `echo "plugin one"`, 0o755)
+	writePlugin(t, dir1, "test-env", `echo "$PATH"`, 0o755)
+	writePlugin(t, dir1, "test-child-deep", `echo "deep $@"`, 0o755)
+	writePlugin(t, dir1, "test-noexec", `echo "nope"`, 0o644)
+	writePlugin(t, dir1, "other-thing", `echo "nope"`, 0o755)
+	writePlugin(t, dir2, "test-hello", `echo "shadowed"`, 0o755)
+
+	path := strings.Join([]string{dir1, filepath.Join(dir1, "missing"), dir2}, string(os.PathListSeparator))
+
+	newRoot := func(enabled bool) *RootCommand {
+		r := &RootCommand{
+			Name:          "test",
+			EnablePlugins: enabled,
+			Commands: map[string]CommandFactory{
+				"one": func() Command {
+					return &TestCommand{Output: "builtin one"}
+				},
+				"child": func() Command {
+					return &RootCommand{
+						Name:          "child",
+						EnablePlugins: true,
+						Commands:      map[string]CommandFactory{},
+					}
+				},
+			},
+		}
+		r.SetLookupEnv(MapLookuper(map[string]string{"PATH": path}))
+		return r
+	}
+
+	cases := []struct {
+		name       string
+		disabled   bool
+		args       []string
+		wantStdout string
+		wantStderr string
+		wantErr    string
+	}{
+		{
+			name:       "runs_plugin",
+			args:       []string{"hello", "a", "-b"},
+			wantStdout: "hello a -b\n",
+		},
+		{
+			name:       "command_takes_precedence",
+			args:       []string{"one"},
+			wantStdout: "builtin one\n",
+		},
+		{
+			name:       "nested",
+			args:       []string{"child", "deep", "x"},
+			wantStdout: "deep x\n",
+		},
+		{
+			name:       "exit_code",
+			args:       []string{"fail"},
+			wantStderr: "failing\n",
+			wantErr:    "exit status 3",
+		},
+		{
+			name:       "environment",
+			args:       []string{"env"},
+			wantStdout: path + "\n",
+		},
+		{
+			name:    "not_executable",
+			args:    []string{"noexec"},
+			wantErr: `unknown command "noexec"`,
+		},
+		{
+			name:     "disabled",
+			disabled: true,
+			args:     []string{"hello"},
+			wantErr:  `unknown command "hello"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newRoot(!tc.disabled)
+			_, stdout, stderr := r.Pipe()
+
+			err := r.Run(t.Context(), tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := stdout.String(), tc.wantStdout; got != want {
+				t.Errorf("expected stdout %q to be %q", got, want)
+			}
+			if got, want := stderr.String(), tc.wantStderr; got != want {
+				t.Errorf("expected stderr %q to be %q", got, want)
+			}
+		})
+	}
+
+	t.Run("help", func(t *testing.T) {
+		t.Parallel()
+
+		want := "PLUGINS\n\n" +
+			"  child-deep    " + filepath.Join(dir1, "test-child-deep") + "\n" +
+			"  env           " + filepath.Join(dir1, "test-env") + "\n" +
+			"  fail          " + filepath.Join(dir1, "test-fail") + "\n" +
+			"  hello         " + filepath.Join(dir1, "test-hello")
+		if got := newRoot(true).Help(); !strings.HasSuffix(got, want) {
+			t.Errorf("expected help\n\n%s\n\nto end with\n\n%s", got, want)
+		}
+		if got := newRoot(false).Help(); strings.Contains(got, "PLUGINS") {
+			t.Errorf("expected help to not contain plugins:\n\n%s", got)
+		}
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		t.Parallel()
+
+		node := buildCompletionTree(newRoot(true), "test")
+		names := make([]string, 0, len(node.subs))
+		for _, sub := range node.subs {
+			names = append(names, sub.name)
+		}
+		if diff := cmp.Diff([]string{"child", "one", "child-deep", "env", "fail", "hello"}, names); diff != "" {
+			t.Errorf("subcommands (-want, +got):\n%s", diff)
+		}
+
+		completer := buildCompleteCommands(newRoot(true))
+		for _, name := range []string{"fail", "hello", "one"} {
+			if _, ok := completer.Sub[name]; !ok {
+				t.Errorf("expected completion for %q", name)
+			}
+		}
+	})
+}
+
+func TestExecutableExtensions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "default",
+			want: []string{".com", ".exe", ".bat", ".cmd"},
+		},
+		{
+			name: "custom",
+			in:   ".EXE; ps1;;",
+			want: []string{".exe", ".ps1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.want, executableExtensions(tc.in)); diff != "" {
+				t.Errorf("extensions (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// WithPOSIXFlags enables GNU-style flag parsing. Flags with a single-character
+// name or alias are short flags, which are given with a single dash and can be
+// combined (e.g. "-abc" is the same as "-a -b -c"). The value of a short flag
+// can be attached (e.g. "-ofile") or given as the next argument. All other flags
+// are long flags, which must be given with two dashes (e.g. "--output=file" or
+// "--output file").
+//
+// Help output and error messages use the same syntax. Flags can still be
+// interspersed with arguments, and "--" stops flag parsing.
+func WithPOSIXFlags() Option {
+	return func(fs *FlagSet) *FlagSet {
+		fs.posix = true
+		return fs
+	}
+}
+
+// dashedName returns the flag name as it is given on the command line.
+func (f *FlagSet) dashedName(name string) string {
+	if f.posix && utf8.RuneCountInString(name) > 1 {
+		return "--" + name
+	}
+	return "-" + name
+}
+
+// normalizePOSIXArgs rewrites GNU-style arguments into the syntax understood by
+// the flag package. Combined short flags are expanded and long flags are given
+// a single dash. Arguments after "--" and values of flags are left unchanged.
+func (f *FlagSet) normalizePOSIXArgs(args []string) ([]string, error) {
+	var merr error
+
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		var expanded []string
+		var takesValue bool
+		switch {
+		case arg == "--":
+			return append(out, args[i:]...), merr
+		case strings.HasPrefix(arg, "--"):
+			name, _, hasValue := strings.Cut(arg[2:], "=")
+			expanded = []string{arg[1:]}
+			takesValue = !hasValue && f.takesValue(name)
+		case len(arg) > 1 && arg[0] == '-':
+			var err error
+			expanded, takesValue, err = f.expandShortFlags(arg[1:])
+			if err != nil {
+				merr = errors.Join(merr, err)
+				continue
+			}
+		default:
+			expanded = []string{arg}
+		}
+
+		out = append(out, expanded...)
+		if takesValue && i+1 < len(args) {
+			i++
+			out = append(out, args[i])
+		}
+	}
+	return out, merr
+}
+
+// expandShortFlags expands a group of combined short flags, without the leading
+// dash, into individual flags. If the last flag requires a value which was not
+// attached, takesValue is true and the value is the next argument.
+func (f *FlagSet) expandShortFlags(group string) (expanded []string, takesValue bool, err error) {
+	// Catch long flags given with a single dash, which would otherwise be
+	// reported as an unknown short flag.
+	first, _ := utf8.DecodeRuneInString(group)
+	if long, _, _ := strings.Cut(group, "="); utf8.RuneCountInString(long) > 1 &&
+		f.flagSet.Lookup(string(first)) == nil && f.flagSet.Lookup(long) != nil {
+		return nil, false, fmt.Errorf("flag -%s must be given with two dashes: --%s", long, long)
+	}
+
+	for i, r := range group {
+		name := string(r)
+		expanded = append(expanded, "-"+name)
+
+		// Unknown flags are reported by the flag package, including -h for help.
+		// The remainder of the group cannot be interpreted.
+		fl := f.flagSet.Lookup(name)
+		if fl == nil {
+			return expanded, false, nil
+		}
+
+		if !isBoolFlag(fl) {
+			if rest := group[i+len(name):]; rest != "" {
+				expanded[len(expanded)-1] += "=" + rest
+				return expanded, false, nil
+			}
+			return expanded, true, nil
+		}
+	}
+	return expanded, false, nil
+}
+
+// takesValue returns true if the named flag is defined and requires a value.
+func (f *FlagSet) takesValue(name string) bool {
+	fl := f.flagSet.Lookup(name)
+	return fl != nil && !isBoolFlag(fl)
+}
+
+// isBoolFlag returns true if the flag does not require a value.
+func isBoolFlag(fl *flag.Flag) bool {
+	typ, ok := fl.Value.(interface{ IsBoolFlag() bool })
+	return ok && typ.IsBoolFlag()
+}
@@ -0,0 +1,221 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+type posixFlags struct {
+	all     bool
+	verbose bool
+	force   bool
+	output  string
+	count   int
+}
+
+func TestWithPOSIXFlags_Parse(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		args      []string
+		want      *posixFlags
+		wantArgs  []string
+		wantError string
+	}{
+		{
+			name: "long",
+			args: []string{"--all", "--output", "out.txt", "--count=3"},
+			want: &posixFlags{all: true, output: "out.txt", count: 3},
+		},
+		{
+			name: "short",
+			args: []string{"-a", "-o", "out.txt", "-f"},
+			want: &posixFlags{all: true, force: true, output: "out.txt"},
+		},
+		{
+			name: "combined",
+			args: []string{"-avf"},
+			want: &posixFlags{all: true, verbose: true, force: true},
+		},
+		{
+			name: "combined_value_attached",
+			args: []string{"-avoout.txt"},
+			want: &posixFlags{all: true, verbose: true, output: "out.txt"},
+		},
+		{
+			name: "combined_value_next",
+			args: []string{"-fo", "out.txt"},
+			want: &posixFlags{force: true, output: "out.txt"},
+		},
+		{
+			name: "value_looks_like_flag",
+			args: []string{"--output", "-a", "-o", "--all"},
+			want: &posixFlags{output: "--all"},
+		},
+		{
+			name: "bool_value",
+			args: []string{"-a", "--all=false"},
+			want: &posixFlags{},
+		},
+		{
+			name:     "interspersed",
+			args:     []string{"arg1", "-av", "arg2", "--output=out.txt", "arg3"},
+			want:     &posixFlags{all: true, verbose: true, output: "out.txt"},
+			wantArgs: []string{"arg1", "arg2", "arg3"},
+		},
+		{
+			name:     "stop_parsing",
+			args:     []string{"-a", "arg1", "--", "-v", "--output=out.txt"},
+			want:     &posixFlags{all: true},
+			wantArgs: []string{"arg1", "-v", "--output=out.txt"},
+		},
+		{
+			name:     "single_dash_argument",
+			args:     []string{"-a", "-"},
+			want:     &posixFlags{all: true},
+			wantArgs: []string{"-"},
+		},
+		{
+			name: "short_shadows_long",
+			args: []string{"-output"},
+			want: &posixFlags{output: "utput"},
+		},
+		{
+			name:      "long_with_single_dash",
+			args:      []string{"-count=3"},
+			want:      &posixFlags{},
+			wantError: "flag -count must be given with two dashes: --count",
+		},
+		{
+			name:      "unknown_short",
+			args:      []string{"-avx"},
+			want:      &posixFlags{all: true, verbose: true},
+			wantError: "flag provided but not defined: -x",
+		},
+		{
+			name:      "unknown_long",
+			args:      []string{"--outptu=out.txt"},
+			want:      &posixFlags{},
+			wantError: "flag provided but not defined: --outptu (did you mean --output?)",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got posixFlags
+			set := NewFlagSet(WithPOSIXFlags())
+			f := set.NewSection("OPTIONS")
+			f.BoolVar(&BoolVar{
+				Name:    "all",
+				Aliases: []string{"a"},
+				Target:  &got.all,
+			})
+			f.BoolVar(&BoolVar{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Target:  &got.verbose,
+			})
+			f.BoolVar(&BoolVar{
+				Name:   "f",
+				Target: &got.force,
+			})
+			f.StringVar(&StringVar{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Target:  &got.output,
+			})
+			f.IntVar(&IntVar{
+				Name:   "count",
+				Target: &got.count,
+			})
+
+			err := set.Parse(tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantError); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tc.want, &got, cmp.AllowUnexported(posixFlags{})); diff != "" {
+				t.Errorf("flags (-want, +got):\n%s", diff)
+			}
+			if tc.wantError == "" {
+				if diff := cmp.Diff(tc.wantArgs, set.Args(), cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("args (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestWithPOSIXFlags_help(t *testing.T) {
+	t.Parallel()
+
+	for _, arg := range []string{"-h", "--help"} {
+		set := NewFlagSet(WithPOSIXFlags())
+		if err := set.Parse([]string{arg}); !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("expected %q to return %v, got %v", arg, flag.ErrHelp, err)
+		}
+	}
+
+	set := NewFlagSet(WithPOSIXFlags())
+	f := set.NewSection("OPTIONS")
+	f.BoolVar(&BoolVar{
+		Name:    "all",
+		Aliases: []string{"a"},
+		Target:  new(bool),
+		Usage:   "Include everything.",
+	})
+	f.BoolVar(&BoolVar{
+		Name:   "f",
+		Target: new(bool),
+		Usage:  "Overwrite files.",
+	})
+	f.StringVar(&StringVar{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Example: "out.txt",
+		Target:  new(string),
+		Usage:   "Output file.",
+	})
+	f.IntVar(&IntVar{
+		Name:   "count",
+		Target: new(int),
+		Usage:  "Number of things.",
+	})
+	set.MarkMutuallyExclusive("all", "f")
+
+	help := set.Help()
+	for _, want := range []string{
+		"    -a, --all\n",
+		"    -f\n",
+		`    -o, --output="out.txt"`,
+		`    --count="int"`,
+		"Only one of --all or -f may be given.",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected\n\n%s\n\nto include %q", help, want)
+		}
+	}
+}
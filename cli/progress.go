@@ -0,0 +1,409 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/timeutil"
+)
+
+const (
+	// progressFrameInterval is how often progress is redrawn on a terminal.
+	progressFrameInterval = 100 * time.Millisecond
+
+	// defaultProgressLogInterval is how often progress is logged when the
+	// output is not a terminal.
+	defaultProgressLogInterval = 10 * time.Second
+
+	// progressBarWidth is the number of characters inside a progress bar.
+	progressBarWidth = 30
+)
+
+// spinnerFrames are the frames of the spinner animation.
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// ProgressOption is an option to [NewProgress].
+type ProgressOption func(p *Progress) *Progress
+
+// WithProgressInterval sets how often progress is logged when the output is not
+// a terminal. The default is 10 seconds.
+func WithProgressInterval(d time.Duration) ProgressOption {
+	return func(p *Progress) *Progress {
+		if d > 0 {
+			p.logInterval = d
+		}
+		return p
+	}
+}
+
+// WithProgressTerminal overrides the detection of whether the output is a
+// terminal, which determines whether progress is animated.
+func WithProgressTerminal(terminal bool) ProgressOption {
+	return func(p *Progress) *Progress {
+		p.interactive = terminal
+		return p
+	}
+}
+
+// Progress reports the progress of one or more concurrent tasks. Tasks with a
+// known total are rendered as progress bars, and tasks without a total are
+// rendered as spinners.
+//
+// If the output is a terminal, the tasks are animated in place. Otherwise the
+// progress of each task is logged as plain lines when the task starts,
+// periodically while it runs, and when it finishes, so logs in CI systems stay
+// readable.
+//
+// While progress is being reported, other output to the same writer should go
+// through [Progress.Printf] so it does not interfere with the animation. Call
+// [Progress.Stop] when finished. It is safe for concurrent use.
+type Progress struct {
+	w             io.Writer
+	interactive   bool
+	logInterval   time.Duration
+	frameInterval time.Duration
+	now           func() time.Time
+
+	mu       sync.Mutex
+	tasks    []*Task
+	drawn    int
+	frame    int
+	started  bool
+	stopped  bool
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewProgress creates a new progress reporter which writes to w. Progress is
+// animated only if w is a terminal.
+func NewProgress(w io.Writer, opts ...ProgressOption) *Progress {
+	p := &Progress{
+		w:             w,
+		interactive:   isTerminal(w),
+		logInterval:   defaultProgressLogInterval,
+		frameInterval: progressFrameInterval,
+		now:           time.Now,
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		p = opt(p)
+	}
+	return p
+}
+
+// NewProgress creates a new progress reporter which writes to [Stderr]. See
+// [NewProgress] for more information.
+func (c *BaseCommand) NewProgress(opts ...ProgressOption) *Progress {
+	return NewProgress(c.Stderr(), opts...)
+}
+
+// Bar starts a new task with the given total, which is rendered as a progress
+// bar.
+func (p *Progress) Bar(name string, total int64) *Task {
+	return p.start(name, total)
+}
+
+// Spinner starts a new task without a known total, which is rendered as a
+// spinner.
+func (p *Progress) Spinner(name string) *Task {
+	return p.start(name, 0)
+}
+
+// Printf prints a line of output above the progress. A trailing newline is
+// added if one is not present.
+func (p *Progress) Printf(format string, a ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	fmt.Fprint(p.w, ensureNewline(fmt.Sprintf(format, a...)))
+	p.drawn = 0
+	if p.interactive {
+		p.draw()
+	}
+}
+
+// Stop stops reporting progress and renders the final state of all tasks. Tasks
+// which are still running are left as they are. It is safe to call Stop
+// multiple times.
+func (p *Progress) Stop() {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		started := p.started
+		p.stopped = true
+		p.mu.Unlock()
+
+		close(p.stopCh)
+		if started {
+			<-p.doneCh
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.interactive {
+			p.draw()
+			p.drawn = 0
+		}
+	})
+}
+
+// start registers a new task and starts the render loop if needed.
+func (p *Progress) start(name string, total int64) *Task {
+	t := &Task{
+		progress: p,
+		name:     name,
+		total:    total,
+		start:    p.now(),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tasks = append(p.tasks, t)
+	if !p.interactive {
+		fmt.Fprintf(p.w, "%s: started\n", t.name)
+		t.logged = t.current
+	}
+
+	if !p.started && !p.stopped {
+		p.started = true
+		go p.loop()
+	}
+	return t
+}
+
+// loop periodically renders progress until stopped.
+func (p *Progress) loop() {
+	defer close(p.doneCh)
+
+	interval := p.logInterval
+	if p.interactive {
+		interval = p.frameInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			p.tick()
+		}
+	}
+}
+
+// tick renders a single frame or log interval.
+func (p *Progress) tick() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.interactive {
+		p.frame++
+		p.draw()
+		return
+	}
+	p.logActive()
+}
+
+// logActive logs a line for every running task which has made progress since it
+// was last logged. Spinners are always logged, since they have no measurable
+// progress. The caller must hold the lock.
+func (p *Progress) logActive() {
+	for _, t := range p.tasks {
+		if t.finished || (t.total > 0 && t.current == t.logged) {
+			continue
+		}
+		fmt.Fprintf(p.w, "%s: %s\n", t.name, t.status(p.now()))
+		t.logged = t.current
+	}
+}
+
+// finish marks the task as complete and renders it.
+func (p *Progress) finish(t *Task, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t.finished {
+		return
+	}
+	t.finished = true
+	t.err = err
+	t.end = p.now()
+	if err == nil && t.total > 0 {
+		t.current = t.total
+	}
+
+	if p.interactive {
+		p.draw()
+		return
+	}
+
+	fmt.Fprintf(p.w, "%s: %s\n", t.name, t.status(t.end))
+	p.remove(t)
+}
+
+// draw redraws all tasks in place. Finished tasks are printed one last time
+// above the running tasks and are then forgotten. The caller must hold the lock.
+func (p *Progress) draw() {
+	p.clear()
+
+	var running []*Task
+	for _, t := range p.tasks {
+		if t.finished {
+			fmt.Fprintf(p.w, "%s\n", t.line(t.end, p.frame))
+			continue
+		}
+		running = append(running, t)
+	}
+	for _, t := range running {
+		fmt.Fprintf(p.w, "%s\n", t.line(p.now(), p.frame))
+	}
+
+	p.tasks = running
+	p.drawn = len(running)
+}
+
+// clear erases the lines of the previous frame. The caller must hold the lock.
+func (p *Progress) clear() {
+	if p.interactive && p.drawn > 0 {
+		fmt.Fprintf(p.w, "\x1b[%dA\x1b[J", p.drawn)
+	}
+}
+
+// remove forgets the given task. The caller must hold the lock.
+func (p *Progress) remove(t *Task) {
+	for i, v := range p.tasks {
+		if v == t {
+			p.tasks = append(p.tasks[:i], p.tasks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Task is a single unit of work whose progress is reported by [Progress]. It is
+// safe for concurrent use.
+type Task struct {
+	progress *Progress
+
+	name  string
+	start time.Time
+
+	// These fields are guarded by the progress lock.
+	total    int64
+	current  int64
+	logged   int64
+	finished bool
+	err      error
+	end      time.Time
+}
+
+// Add increments the progress of the task by n. The progress never goes below
+// zero.
+func (t *Task) Add(n int64) {
+	t.progress.mu.Lock()
+	defer t.progress.mu.Unlock()
+	t.current = max(t.current+n, 0)
+}
+
+// Set sets the progress of the task to n. The progress never goes below zero.
+func (t *Task) Set(n int64) {
+	t.progress.mu.Lock()
+	defer t.progress.mu.Unlock()
+	t.current = max(n, 0)
+}
+
+// SetTotal sets the total of the task. A total of zero renders the task as a
+// spinner.
+func (t *Task) SetTotal(n int64) {
+	t.progress.mu.Lock()
+	defer t.progress.mu.Unlock()
+	t.total = n
+}
+
+// Done marks the task as successfully completed.
+func (t *Task) Done() {
+	t.progress.finish(t, nil)
+}
+
+// Fail marks the task as failed with the given error.
+func (t *Task) Fail(err error) {
+	if err == nil {
+		err = fmt.Errorf("unknown error")
+	}
+	t.progress.finish(t, err)
+}
+
+// status returns the plain status of the task at the given time. The caller
+// must hold the progress lock.
+func (t *Task) status(now time.Time) string {
+	elapsed := timeutil.HumanDuration(now.Sub(t.start))
+
+	switch {
+	case t.err != nil:
+		return fmt.Sprintf("failed after %s: %s", elapsed, t.err)
+	case t.finished:
+		return fmt.Sprintf("done in %s", elapsed)
+	case t.total > 0:
+		return fmt.Sprintf("%d%% (%d/%d), %s elapsed", t.percent(), t.current, t.total, elapsed)
+	default:
+		return fmt.Sprintf("running, %s elapsed", elapsed)
+	}
+}
+
+// line returns the animated line for the task. The caller must hold the
+// progress lock.
+func (t *Task) line(now time.Time, frame int) string {
+	switch {
+	case t.finished:
+		return fmt.Sprintf("%s: %s", t.name, t.status(now))
+	case t.total > 0:
+		filled := int(int64(progressBarWidth) * min(max(t.current, 0), t.total) / t.total)
+		bar := strings.Repeat("=", filled)
+		if filled < progressBarWidth {
+			bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+		}
+		return fmt.Sprintf("%s [%s] %3d%% (%d/%d)", t.name, bar, t.percent(), t.current, t.total)
+	default:
+		elapsed := timeutil.HumanDuration(now.Sub(t.start))
+		return fmt.Sprintf("%s %s (%s)", spinnerFrames[frame%len(spinnerFrames)], t.name, elapsed)
+	}
+}
+
+// percent returns the completion percentage, capped at 100. The caller must
+// hold the progress lock.
+func (t *Task) percent() int64 {
+	if t.total <= 0 {
+		return 0
+	}
+	return min(max(100*t.current/t.total, 0), 100)
+}
+
+// ensureNewline appends a newline to s if it does not already end in one.
+func ensureNewline(s string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
@@ -0,0 +1,202 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock which only moves when advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testProgress creates a progress reporter with a fake clock and intervals long
+// enough that the render loop never fires during a test.
+func testProgress(tb testing.TB, interactive bool) (*Progress, *fakeClock, *bytes.Buffer) {
+	tb.Helper()
+
+	var b bytes.Buffer
+	p := NewProgress(&b, WithProgressTerminal(interactive), WithProgressInterval(time.Hour))
+	p.frameInterval = time.Hour
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p.now = clock.Now
+
+	tb.Cleanup(p.Stop)
+	return p, clock, &b
+}
+
+func TestProgress_plain(t *testing.T) {
+	t.Parallel()
+
+	p, clock, b := testProgress(t, false)
+
+	download := p.Bar("download", 200)
+	wait := p.Spinner("wait")
+
+	clock.Advance(2 * time.Second)
+	download.Add(50)
+	p.tick()
+
+	// Bars without progress are not logged again, but spinners are.
+	clock.Advance(time.Second)
+	p.tick()
+
+	p.Printf("a message")
+	clock.Advance(time.Second)
+	download.Done()
+	wait.Fail(fmt.Errorf("timed out"))
+	p.tick()
+	p.Stop()
+
+	want := strings.Join([]string{
+		"download: started",
+		"wait: started",
+		"download: 25% (50/200), 2s elapsed",
+		"wait: running, 2s elapsed",
+		"wait: running, 3s elapsed",
+		"a message",
+		"download: done in 4s",
+		"wait: failed after 4s: timed out",
+		"",
+	}, "\n")
+	if got := b.String(); got != want {
+		t.Errorf("expected\n\n%s\n\nto be\n\n%s", got, want)
+	}
+}
+
+func TestProgress_interactive(t *testing.T) {
+	t.Parallel()
+
+	p, clock, b := testProgress(t, true)
+
+	download := p.Bar("download", 4)
+	wait := p.Spinner("wait")
+
+	clock.Advance(2 * time.Second)
+	download.Add(1)
+	p.tick()
+	clock.Advance(time.Second)
+	download.Add(1)
+	p.tick()
+	wait.Done()
+	p.Printf("message")
+	download.Set(3)
+	p.Stop()
+
+	want := strings.Join([]string{
+		// First frame.
+		"download [=======>                      ]  25% (1/4)",
+		"/ wait (2s)",
+		// Second frame, which moves up two lines.
+		"\x1b[2A\x1b[J" + "download [===============>              ]  50% (2/4)",
+		"- wait (3s)",
+		// The finished task is printed above the running task.
+		"\x1b[2A\x1b[J" + "wait: done in 3s",
+		"download [===============>              ]  50% (2/4)",
+		// The message is printed above the running task.
+		"\x1b[1A\x1b[J" + "message",
+		"download [===============>              ]  50% (2/4)",
+		// The final state is drawn when stopped.
+		"\x1b[1A\x1b[J" + "download [======================>       ]  75% (3/4)",
+		"",
+	}, "\n")
+	if got := b.String(); got != want {
+		t.Errorf("expected\n\n%q\n\nto be\n\n%q", got, want)
+	}
+}
+
+func TestTask_line_outOfRange(t *testing.T) {
+	t.Parallel()
+
+	p, _, _ := testProgress(t, true)
+	task := p.Bar("download", 4)
+
+	task.Add(-10)
+	if got, want := task.line(time.Now(), 0), "download [>                             ]   0% (0/4)"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	task.Set(10)
+	if got, want := task.line(time.Now(), 0), "download [==============================] 100% (10/4)"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	p.Stop()
+}
+
+func TestProgress_concurrent(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	p := NewProgress(&b, WithProgressTerminal(true))
+	p.frameInterval = time.Millisecond
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			task := p.Bar(fmt.Sprintf("task-%d", i), 100)
+			for range 100 {
+				task.Add(1)
+			}
+			task.Done()
+		}()
+	}
+	wg.Wait()
+	p.Stop()
+
+	for i := range 10 {
+		if want := fmt.Sprintf("task-%d: done", i); !strings.Contains(b.String(), want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+}
+
+func TestBaseCommand_NewProgress(t *testing.T) {
+	t.Parallel()
+
+	var cmd BaseCommand
+	_, _, stderr := cmd.Pipe()
+
+	p := cmd.NewProgress()
+	p.Spinner("work").Done()
+	p.Stop()
+
+	// Buffers are not terminals, so progress is logged as plain lines.
+	if got, want := stderr.String(), "work: started\nwork: done in 0s\n"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Confirm asks a yes/no question and reads the answer from [Stdin]. The answer
+// is case-insensitive and may be "y", "yes", "n", or "no". An empty answer
+// selects the default. The choices are appended to the message, with the
+// default in uppercase (e.g. "Continue? [Y/n]: ").
+//
+// If the prompt is interactive, invalid answers are reported and the question
+// is asked again. Otherwise, an invalid answer returns an error. For more
+// information about the conditions under which the prompt is displayed, see
+// [BaseCommand.PromptTo].
+func (c *BaseCommand) Confirm(ctx context.Context, msg string, defaultValue bool) (bool, error) {
+	choices := "[y/N]"
+	if defaultValue {
+		choices = "[Y/n]"
+	}
+
+	var result bool
+	if err := c.promptUntilValid(ctx, msg+" "+choices+": ", func(s string) error {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "":
+			result = defaultValue
+		case "y", "yes":
+			result = true
+		case "n", "no":
+			result = false
+		default:
+			return fmt.Errorf("invalid answer %q, expected yes or no", s)
+		}
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	return result, nil
+}
+
+// Select asks the user to choose one of the given options and returns the
+// chosen option. The options are printed as a numbered list, and the answer may
+// be either the number or the option itself.
+//
+// If the prompt is interactive, invalid answers are reported and the question
+// is asked again. Otherwise, an invalid answer returns an error. For more
+// information about the conditions under which the prompt is displayed, see
+// [BaseCommand.PromptTo].
+func (c *BaseCommand) Select(ctx context.Context, msg string, options []string) (string, error) {
+	if len(options) == 0 {
+		return "", fmt.Errorf("no options to select from")
+	}
+
+	c.printOptions(options)
+
+	var result string
+	if err := c.promptUntilValid(ctx, msg, func(s string) error {
+		idx, err := parseOption(strings.TrimSpace(s), options)
+		if err != nil {
+			return err
+		}
+		result = options[idx]
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// MultiSelect asks the user to choose any number of the given options and
+// returns the chosen options in the order in which they were given. The options
+// are printed as a numbered list, and the answer is a comma-separated list of
+// numbers or options. An empty answer selects no options.
+//
+// If the prompt is interactive, invalid answers are reported and the question
+// is asked again. Otherwise, an invalid answer returns an error. For more
+// information about the conditions under which the prompt is displayed, see
+// [BaseCommand.PromptTo].
+func (c *BaseCommand) MultiSelect(ctx context.Context, msg string, options []string) ([]string, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("no options to select from")
+	}
+
+	c.printOptions(options)
+
+	var result []string
+	if err := c.promptUntilValid(ctx, msg, func(s string) error {
+		result = nil
+		seen := make(map[int]struct{}, len(options))
+
+		for _, part := range strings.Split(s, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			idx, err := parseOption(part, options)
+			if err != nil {
+				return err
+			}
+			if _, ok := seen[idx]; ok {
+				continue
+			}
+			seen[idx] = struct{}{}
+			result = append(result, options[idx])
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PromptSecret asks for sensitive user input, such as a password or token. If
+// [Stdin] is a terminal, it reads a single line and typed characters are not
+// echoed. Otherwise it reads all of [Stdin] like [BaseCommand.PromptAll], so
+// multi-line secrets such as PEM-encoded keys can be read from a pipe, and only
+// the final newline is removed.
+func (c *BaseCommand) PromptSecret(ctx context.Context, msg string, args ...any) (string, error) {
+	stdin := c.Stdin()
+
+	f, ok := stdin.(*os.File)
+	if !ok || !isatty.IsTerminal(f.Fd()) {
+		v, err := c.PromptAll(ctx, msg, args...)
+		if err != nil {
+			return "", err
+		}
+		v = strings.TrimSuffix(v, "\n")
+		return strings.TrimSuffix(v, "\r"), nil
+	}
+
+	restore, err := disableEcho(f.Fd())
+	if err != nil {
+		return "", fmt.Errorf("failed to prompt: %w", err)
+	}
+
+	v, err := c.Prompt(ctx, msg, args...)
+	if rerr := restore(); rerr != nil {
+		err = errors.Join(err, rerr)
+	}
+	return v, err
+}
+
+// maxPromptAttempts is the maximum number of times an interactive prompt is
+// asked when the answers are invalid.
+const maxPromptAttempts = 3
+
+// promptUntilValid prompts with the given message and calls parse with the
+// answer. If parse returns an error and the prompt is interactive, the error is
+// printed to [Stderr] and the prompt is repeated, asking at most
+// [maxPromptAttempts] times. Otherwise the error is returned.
+func (c *BaseCommand) promptUntilValid(ctx context.Context, msg string, parse func(string) error) error {
+	interactive := shouldPrompt(c.Stdin(), c.Stdout(), c.Stderr())
+
+	for attempt := 1; ; attempt++ {
+		v, err := c.Prompt(ctx, "%s", msg)
+		if err != nil {
+			return err
+		}
+
+		perr := parse(v)
+		if perr == nil {
+			return nil
+		}
+
+		if !interactive || attempt >= maxPromptAttempts {
+			return perr
+		}
+		c.Errf("%s", perr)
+	}
+}
+
+// printOptions prints the numbered list of options to [Stdout] if the prompt is
+// interactive.
+func (c *BaseCommand) printOptions(options []string) {
+	if !shouldPrompt(c.Stdin(), c.Stdout(), c.Stderr()) {
+		return
+	}
+
+	width := len(strconv.Itoa(len(options)))
+	for i, opt := range options {
+		c.Outf("  %*d) %s", width, i+1, opt)
+	}
+}
+
+// parseOption returns the index of the option selected by s, which is either the
+// 1-based number of the option or the option itself.
+func parseOption(s string, options []string) (int, error) {
+	for i, opt := range options {
+		if s == opt {
+			return i, nil
+		}
+	}
+
+	if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= len(options) {
+		return n - 1, nil
+	}
+	return 0, fmt.Errorf("invalid selection %q, expected a number between 1 and %d or one of the options", s, len(options))
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// redactedValue is printed in place of the value of sensitive flags.
+const redactedValue = "REDACTED"
+
+// sensitivePolicy describes how the value of a sensitive flag may be given on
+// the command line.
+type sensitivePolicy struct {
+	name        string
+	envVar      string
+	allowArgs   bool
+	allowFile   bool
+	allowPrompt bool
+}
+
+// check returns an error if the command line value is not allowed by the
+// policy. Values which refer to a file or prompt are always allowed if reading
+// from a file or prompt is enabled, since they do not contain the secret.
+func (p *sensitivePolicy) check(val string) error {
+	if p.allowArgs ||
+		(p.allowFile && strings.HasPrefix(val, "@")) ||
+		(p.allowPrompt && val == "-") {
+		return nil
+	}
+
+	var alternatives []string
+	if p.allowFile {
+		alternatives = append(alternatives, "a file with \"@\"")
+	}
+	if p.allowPrompt {
+		alternatives = append(alternatives, "a prompt with \"-\"")
+	}
+	if p.envVar != "" {
+		alternatives = append(alternatives, "the "+p.envVar+" environment variable")
+	}
+
+	msg := fmt.Sprintf("flag -%s is sensitive and cannot be given on the command line", p.name)
+	if len(alternatives) > 0 {
+		msg += ", use " + joinWithConjunction(alternatives, "or") + " instead"
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// redactPrinter wraps the printer to print [redactedValue] instead of the
+// value. Empty values are not redacted, so help output does not imply there is
+// a default.
+func redactPrinter[T any](inner PrinterFunc[T]) PrinterFunc[T] {
+	return func(cur T) string {
+		if inner(cur) == "" {
+			return ""
+		}
+		return redactedValue
+	}
+}
+
+// redactParser wraps the parser to omit the details of parsing errors, which
+// often include the value.
+func redactParser[T any](name string, inner ParserFunc[T]) ParserFunc[T] {
+	return func(val string) (T, error) {
+		v, err := inner(val)
+		if err != nil {
+			var nilT T
+			return nilT, fmt.Errorf("invalid value for sensitive flag -%s", name)
+		}
+		return v, nil
+	}
+}
+
+// invalidValuePrefix is the prefix of the error message returned by the flag
+// package when a flag value fails to parse. It is followed by the quoted value.
+const invalidValuePrefix = "invalid value "
+
+// redactFlagError replaces the value in invalid value errors returned by the
+// flag package for sensitive flags, which include the value as given on the
+// command line.
+func (f *FlagSet) redactFlagError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	rest, ok := strings.CutPrefix(err.Error(), invalidValuePrefix)
+	if !ok {
+		return err
+	}
+	quoted, qerr := strconv.QuotedPrefix(rest)
+	if qerr != nil {
+		return err
+	}
+	rest, ok = strings.CutPrefix(rest[len(quoted):], " for flag -")
+	if !ok {
+		return err
+	}
+	name, detail, _ := strings.Cut(rest, ": ")
+
+	fl := f.flagSet.Lookup(name)
+	if fl == nil {
+		return err
+	}
+	if typ, ok := fl.Value.(interface{ isSensitive() bool }); !ok || !typ.isSensitive() {
+		return err
+	}
+	return fmt.Errorf("%s%q for flag -%s: %s", invalidValuePrefix, redactedValue, name, detail)
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestSensitive_Parse(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token.txt"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		args      []string
+		allowArgs bool
+		env       map[string]string
+		want      string
+		wantPin   int
+		wantError string
+	}{
+		{
+			name: "env",
+			env:  map[string]string{"TOKEN": "from-env"},
+			want: "from-env",
+		},
+		{
+			name: "file",
+			args: []string{"-token", "@token.txt"},
+			want: "from-file",
+		},
+		{
+			name: "prompt",
+			args: []string{"-token", "-"},
+			want: "from-secret-prompt",
+		},
+		{
+			name: "args_rejected",
+			args: []string{"-token", "hunter2"},
+			wantError: `invalid value "REDACTED" for flag -token: flag -token is sensitive and ` +
+				`cannot be given on the command line, use a file with "@", a prompt with "-", ` +
+				`or the TOKEN environment variable instead`,
+		},
+		{
+			name:      "args_allowed",
+			args:      []string{"-token", "hunter2", "-pin", "1234"},
+			allowArgs: true,
+			want:      "hunter2",
+			wantPin:   1234,
+		},
+		{
+			name:      "parse_error",
+			args:      []string{"-pin", "12ab"},
+			allowArgs: true,
+			wantError: `invalid value "REDACTED" for flag -pin: invalid value for sensitive flag -pin`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			set := NewFlagSet(
+				WithLookupEnv(MapLookuper(tc.env)),
+				WithWorkingDir(func() (string, error) { return dir, nil }),
+				WithPromptAll(func(ctx context.Context, msg string, args ...any) (string, error) {
+					return "from-prompt", nil
+				}),
+				WithPromptSecret(func(ctx context.Context, msg string, args ...any) (string, error) {
+					return "from-secret-prompt", nil
+				}),
+			)
+
+			var token string
+			var pin int
+			f := set.NewSection("SECRETS")
+			f.StringVar(&StringVar{
+				Name:               "token",
+				EnvVar:             "TOKEN",
+				Target:             &token,
+				AllowFromFile:      true,
+				AllowFromPrompt:    true,
+				Sensitive:          true,
+				AllowSensitiveArgs: tc.allowArgs,
+			})
+			Flag(f, &Var[int]{
+				Name:               "pin",
+				Target:             &pin,
+				Parser:             strconv.Atoi,
+				Printer:            strconv.Itoa,
+				Sensitive:          true,
+				AllowSensitiveArgs: tc.allowArgs,
+			})
+
+			err := set.Parse(tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantError); diff != "" {
+				t.Error(diff)
+			}
+			if err != nil {
+				for _, arg := range tc.args {
+					if strings.Contains(err.Error(), arg) && arg != "-pin" && arg != "-token" {
+						t.Errorf("expected error %q to not contain %q", err, arg)
+					}
+				}
+			}
+			if got, want := token, tc.want; got != want {
+				t.Errorf("expected token %q to be %q", got, want)
+			}
+			if got, want := pin, tc.wantPin; got != want {
+				t.Errorf("expected pin %d to be %d", got, want)
+			}
+		})
+	}
+}
+
+func TestSensitive_output(t *testing.T) {
+	t.Parallel()
+
+	set := NewFlagSet(WithLookupEnv(MapLookuper(map[string]string{
+		"TOKEN": "from-env",
+	})))
+
+	var token, name string
+	f := set.NewSection("OPTIONS")
+	f.StringVar(&StringVar{
+		Name:      "token",
+		EnvVar:    "TOKEN",
+		Default:   "default-secret",
+		Target:    &token,
+		Usage:     "The API token.",
+		Sensitive: true,
+	})
+	f.StringVar(&StringVar{
+		Name:   "name",
+		Target: &name,
+	})
+
+	if err := set.Parse([]string{"-name", "bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	help := set.Help()
+	dump := set.DumpValues()
+	for _, out := range []string{help, dump} {
+		for _, secret := range []string{"default-secret", "from-env"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("expected\n\n%s\n\nto not contain %q", out, secret)
+			}
+		}
+	}
+
+	usage := set.Lookup("token").Usage
+	for _, want := range []string{
+		`The default value is "REDACTED".`,
+		`The current value "REDACTED" is from the environment variable TOKEN.`,
+		"This value is sensitive and cannot be given directly on the command line.",
+	} {
+		if !strings.Contains(usage, want) {
+			t.Errorf("expected %q to contain %q", usage, want)
+		}
+	}
+	if got, want := dump, `-token="REDACTED" (environment variable TOKEN)`; !strings.Contains(got, want) {
+		t.Errorf("expected\n\n%s\n\nto contain %q", got, want)
+	}
+
+	// The real value is still available to the command.
+	if got, want := fmt.Sprint(set.Lookup("token").Value.(Value).Get()), "from-env"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences for styling terminal output.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiCyan      = "\x1b[36m"
+	ansiBoldRed   = ansiBold + ansiRed
+	ansiBoldCyan  = ansiBold + ansiCyan
+	ansiBoldGreen = ansiBold + ansiGreen
+)
+
+// Style applies terminal styling, such as bold text and colors, to output.
+// Styling is only applied if color is enabled for the output stream, so the
+// same code produces plain text when output is redirected to a file or pipe.
+// A nil Style never applies styling.
+//
+// Use [BaseCommand.OutStyle] or [BaseCommand.ErrStyle] to get the style for the
+// command's output streams:
+//
+//	style := c.ErrStyle()
+//	c.Errf("%s the cluster is not ready", style.Warning("warning:"))
+type Style struct {
+	color bool
+	width int
+}
+
+// NewStyle returns the style for output written to w. Color is enabled if w is
+// a terminal, unless:
+//
+//   - NO_COLOR is set to a non-empty value, which always disables color.
+//   - FORCE_COLOR is set to a non-empty value other than "0" or "false", which
+//     enables color even if w is not a terminal.
+//   - TERM is "dumb", which disables color unless it is forced.
+//
+// The width is the width of the terminal, which can be overridden by setting
+// COLUMNS to a positive number. Environment variables are read with lookupEnv,
+// which defaults to [os.LookupEnv] if nil.
+func NewStyle(w io.Writer, lookupEnv LookupEnvFunc) *Style {
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	getEnv := func(k string) string {
+		v, _ := lookupEnv(k)
+		return v
+	}
+
+	tty := isTerminal(w)
+
+	var width int
+	if tty {
+		width = terminalWidth(w)
+	}
+	if v, err := strconv.Atoi(getEnv("COLUMNS")); err == nil && v > 0 {
+		width = v
+	}
+
+	color := tty && getEnv("TERM") != "dumb"
+	switch v := strings.ToLower(getEnv("FORCE_COLOR")); v {
+	case "", "0", "false":
+	default:
+		color = true
+	}
+	if getEnv("NO_COLOR") != "" {
+		color = false
+	}
+
+	return &Style{
+		color: color,
+		width: width,
+	}
+}
+
+// Enabled returns true if styling is applied.
+func (s *Style) Enabled() bool {
+	return s != nil && s.color
+}
+
+// Width returns the width of the terminal in columns, or 0 if the output is not
+// a terminal or the width cannot be determined. The COLUMNS environment
+// variable takes precedence over the detected width.
+func (s *Style) Width() int {
+	if s == nil {
+		return 0
+	}
+	return s.width
+}
+
+// Bold returns the text in bold. It is used for headings.
+func (s *Style) Bold(text string) string {
+	return s.apply(ansiBold, text)
+}
+
+// Dim returns the text dimmed. It is used for hints and other secondary
+// information.
+func (s *Style) Dim(text string) string {
+	return s.apply(ansiDim, text)
+}
+
+// Highlight returns the text in bold cyan. It is used for names the user can
+// type, such as flags and arguments.
+func (s *Style) Highlight(text string) string {
+	return s.apply(ansiBoldCyan, text)
+}
+
+// Error returns the text in bold red.
+func (s *Style) Error(text string) string {
+	return s.apply(ansiBoldRed, text)
+}
+
+// Warning returns the text in yellow.
+func (s *Style) Warning(text string) string {
+	return s.apply(ansiYellow, text)
+}
+
+// Success returns the text in bold green.
+func (s *Style) Success(text string) string {
+	return s.apply(ansiBoldGreen, text)
+}
+
+// apply wraps the text in the given escape sequence if styling is enabled.
+func (s *Style) apply(code, text string) string {
+	if !s.Enabled() || text == "" {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// terminalWidth returns the width of the terminal w, or 0 if w is not a file or
+// the width cannot be determined.
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+	width, err := getTerminalWidth(f.Fd())
+	if err != nil || width < 0 {
+		return 0
+	}
+	return width
+}
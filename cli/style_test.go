@@ -0,0 +1,212 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewStyle(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{
+			name: "not_terminal",
+			want: false,
+		},
+		{
+			name: "force_color",
+			env:  map[string]string{"FORCE_COLOR": "1"},
+			want: true,
+		},
+		{
+			name: "force_color_dumb",
+			env:  map[string]string{"FORCE_COLOR": "true", "TERM": "dumb"},
+			want: true,
+		},
+		{
+			name: "force_color_disabled",
+			env:  map[string]string{"FORCE_COLOR": "0"},
+			want: false,
+		},
+		{
+			name: "no_color",
+			env:  map[string]string{"FORCE_COLOR": "1", "NO_COLOR": "1"},
+			want: false,
+		},
+		{
+			name: "no_color_empty",
+			env:  map[string]string{"FORCE_COLOR": "1", "NO_COLOR": ""},
+			want: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			style := NewStyle(new(bytes.Buffer), MapLookuper(tc.env))
+			if got, want := style.Enabled(), tc.want; got != want {
+				t.Errorf("expected enabled %t to be %t", got, want)
+			}
+			if got, want := style.Width(), 0; got != want {
+				t.Errorf("expected width %d to be %d", got, want)
+			}
+
+			got := style.Bold("text")
+			if want := tc.want; strings.Contains(got, "\x1b[") != want {
+				t.Errorf("expected %q to be styled: %t", got, want)
+			}
+		})
+	}
+}
+
+func TestStyle_apply(t *testing.T) {
+	t.Parallel()
+
+	style := &Style{color: true}
+
+	cases := []struct {
+		name string
+		fn   func(string) string
+		want string
+	}{
+		{name: "bold", fn: style.Bold, want: "\x1b[1mtext\x1b[0m"},
+		{name: "dim", fn: style.Dim, want: "\x1b[2mtext\x1b[0m"},
+		{name: "highlight", fn: style.Highlight, want: "\x1b[1m\x1b[36mtext\x1b[0m"},
+		{name: "error", fn: style.Error, want: "\x1b[1m\x1b[31mtext\x1b[0m"},
+		{name: "warning", fn: style.Warning, want: "\x1b[33mtext\x1b[0m"},
+		{name: "success", fn: style.Success, want: "\x1b[1m\x1b[32mtext\x1b[0m"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := tc.fn("text"), tc.want; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := tc.fn(""), ""; got != want {
+				t.Errorf("expected empty text to be unstyled, got %q", got)
+			}
+		})
+	}
+
+	// A nil style never applies styling.
+	var nilStyle *Style
+	if got, want := nilStyle.Error("text"), "text"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestStyle_help(t *testing.T) {
+	t.Parallel()
+
+	cmd := &TestCommand{}
+	_, _, stderr := cmd.Pipe()
+	cmd.SetLookupEnv(MapLookuper(map[string]string{"FORCE_COLOR": "1"}))
+
+	help := cmd.Flags().Help()
+	for _, want := range []string{
+		"\x1b[1mOPTIONS\x1b[0m\n\n",
+		"    \x1b[1m\x1b[36m-string\x1b[0m=\x1b[2m\"my-string\"\x1b[0m\n",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected\n\n%q\n\nto contain %q", help, want)
+		}
+	}
+
+	cmd.Error = fmt.Errorf("oops")
+	if got, want := runMain(t.Context(), cmd, nil), ExitCodeError; got != want {
+		t.Errorf("expected exit code %d to be %d", got, want)
+	}
+	if got, want := stderr.String(), "\x1b[1m\x1b[31merror:\x1b[0m oops\n"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// NO_COLOR disables styling.
+	cmd.SetLookupEnv(MapLookuper(map[string]string{"FORCE_COLOR": "1", "NO_COLOR": "1"}))
+	if help := cmd.Flags().Help(); strings.Contains(help, "\x1b[") {
+		t.Errorf("expected %q to not be styled", help)
+	}
+}
+
+func TestStyle_width(t *testing.T) {
+	t.Parallel()
+
+	usage := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 5)
+
+	cases := []struct {
+		name    string
+		columns string
+		want    int
+	}{
+		{
+			name: "default",
+			want: defaultLineLength,
+		},
+		{
+			name:    "narrow",
+			columns: "50",
+			want:    50,
+		},
+		{
+			name:    "wide",
+			columns: "120",
+			want:    120,
+		},
+		{
+			name:    "minimum",
+			columns: "10",
+			want:    minLineLength,
+		},
+		{
+			name:    "invalid",
+			columns: "wide",
+			want:    defaultLineLength,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			style := NewStyle(new(bytes.Buffer), MapLookuper(map[string]string{
+				"COLUMNS": tc.columns,
+			}))
+			set := NewFlagSet(WithStyle(style))
+			set.NewSection("OPTIONS").StringVar(&StringVar{
+				Name:   "name",
+				Target: new(string),
+				Usage:  usage,
+			})
+
+			var longest int
+			for _, line := range strings.Split(set.Help(), "\n") {
+				longest = max(longest, len(line))
+			}
+			if longest > tc.want || longest < tc.want-10 {
+				t.Errorf("expected longest line (%d) to be close to %d:\n\n%s", longest, tc.want, set.Help())
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the maximum number of suggestions to return.
+const maxSuggestions = 3
+
+// suggest returns the candidates that are "close" to the input, ordered by
+// their edit distance and then alphabetically. The maximum allowed distance
+// scales with the length of the input so short inputs do not match everything.
+func suggest(input string, candidates []string) []string {
+	if input == "" {
+		return nil
+	}
+
+	maxDistance := len([]rune(input)) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	if maxDistance > 3 {
+		maxDistance = 3
+	}
+
+	type match struct {
+		name     string
+		distance int
+	}
+
+	seen := make(map[string]struct{}, len(candidates))
+	matches := make([]*match, 0, len(candidates))
+	for _, candidate := range candidates {
+		if _, ok := seen[candidate]; ok || candidate == input {
+			continue
+		}
+		seen[candidate] = struct{}{}
+
+		if d := editDistance(strings.ToLower(input), strings.ToLower(candidate)); d <= maxDistance {
+			matches = append(matches, &match{name: candidate, distance: d})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+
+	result := make([]string, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, m.name)
+	}
+	return result
+}
+
+// editDistance computes the optimal string alignment distance between a and b,
+// which is the Levenshtein distance extended to treat the transposition of two
+// adjacent characters as a single edit (e.g. "projetc" and "project" have a
+// distance of 1).
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// prev2, prev, and curr are the rows i-2, i-1, and i of the distance
+	// matrix.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(
+				prev[j]+1,      // deletion
+				curr[j-1]+1,    // insertion
+				prev[j-1]+cost, // substitution
+			)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1) // transposition
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+// formatSuggestions returns a human-readable "did you mean" phrase for the
+// suggestions, formatting each suggestion with the given function. It returns
+// the empty string if there are no suggestions.
+func formatSuggestions(suggestions []string, format func(string) string) string {
+	formatted := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		formatted = append(formatted, format(s))
+	}
+
+	if len(formatted) == 0 {
+		return ""
+	}
+	return "did you mean " + joinWithConjunction(formatted, "or") + "?"
+}
+
+// joinWithConjunction joins the items into a human-readable list, separating
+// the final item with the given conjunction (e.g. "a, b, or c").
+func joinWithConjunction(items []string, conjunction string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	case 2:
+		return items[0] + " " + conjunction + " " + items[1]
+	default:
+		return strings.Join(items[:len(items)-1], ", ") + ", " + conjunction + " " + items[len(items)-1]
+	}
+}
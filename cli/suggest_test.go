@@ -0,0 +1,153 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSuggest(t *testing.T) {
+	t.Parallel()
+
+	candidates := []string{"bus", "car", "project", "train", "transport", "transports"}
+
+	cases := []struct {
+		name  string
+		input string
+		exp   []string
+	}{
+		{
+			name:  "empty",
+			input: "",
+			exp:   []string{},
+		},
+		{
+			name:  "no_match",
+			input: "airplane",
+			exp:   []string{},
+		},
+		{
+			name:  "deletion",
+			input: "trasport",
+			exp:   []string{"transport", "transports"},
+		},
+		{
+			name:  "transposition",
+			input: "projetc",
+			exp:   []string{"project"},
+		},
+		{
+			name:  "case_insensitive",
+			input: "BUS",
+			exp:   []string{"bus"},
+		},
+		{
+			name:  "short_input_single_edit",
+			input: "cr",
+			exp:   []string{"car"},
+		},
+		{
+			name:  "exact_match_not_suggested",
+			input: "train",
+			exp:   []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := suggest(tc.input, candidates)
+			if got == nil {
+				got = []string{}
+			}
+			if diff := cmp.Diff(tc.exp, got); diff != "" {
+				t.Errorf("suggestions (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		a, b string
+		exp  int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"kitten", "sitting", 3},
+		{"projetc", "project", 1},
+		{"ca", "abc", 3},
+		{"🌎", "🌍", 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.a+"_"+tc.b, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := editDistance(tc.a, tc.b), tc.exp; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+		})
+	}
+}
+
+func TestFormatSuggestions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		input []string
+		exp   string
+	}{
+		{
+			name:  "none",
+			input: nil,
+			exp:   "",
+		},
+		{
+			name:  "one",
+			input: []string{"a"},
+			exp:   `did you mean "a"?`,
+		},
+		{
+			name:  "two",
+			input: []string{"a", "b"},
+			exp:   `did you mean "a" or "b"?`,
+		},
+		{
+			name:  "three",
+			input: []string{"a", "b", "c"},
+			exp:   `did you mean "a", "b", or "c"?`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := formatSuggestions(tc.input, strconv.Quote), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package cli
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// getTerminalWidth returns the number of columns of the console window with
+// the given handle.
+func getTerminalWidth(fd uintptr) (int, error) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(fd), &info); err != nil {
+		return 0, fmt.Errorf("failed to get console size: %w", err)
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, nil
+}
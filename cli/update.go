@@ -0,0 +1,526 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abcxyz/pkg/buildinfo"
+)
+
+const (
+	// defaultUpdateCheckInterval is how long the result of an update check is
+	// cached.
+	defaultUpdateCheckInterval = 24 * time.Hour
+
+	// maxManifestSize is the maximum size of a release manifest.
+	maxManifestSize = 4_194_304 // 4 MiB
+
+	// maxBinarySize is the maximum size of a downloaded binary.
+	maxBinarySize = 1_073_741_824 // 1 GiB
+
+	// updateNoticeTimeout is how long [Updater.Middleware] waits for the release
+	// manifest, so slow networks do not delay commands.
+	updateNoticeTimeout = 2 * time.Second
+)
+
+// Release is the latest release of a program, as described by the release
+// manifest:
+//
+//	{
+//	  "version": "v1.2.3",
+//	  "notes_url": "https://example.com/releases/v1.2.3",
+//	  "assets": {
+//	    "linux/amd64": {
+//	      "url": "https://example.com/releases/v1.2.3/tool_linux_amd64",
+//	      "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+//	    }
+//	  }
+//	}
+//
+// Assets are keyed by [buildinfo.OSArch]. The URL of an asset can be relative
+// to the manifest URL.
+type Release struct {
+	Version  string                   `json:"version"`
+	NotesURL string                   `json:"notes_url,omitempty"`
+	Assets   map[string]*ReleaseAsset `json:"assets,omitempty"`
+}
+
+// ReleaseAsset is a downloadable binary for a single platform.
+type ReleaseAsset struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// Updater checks for and installs new releases of the running program from a
+// release manifest. Update checks are opt-in: a program must configure an
+// Updater, typically on a [VersionCommand] or with [Updater.Middleware].
+type Updater struct {
+	// ManifestURL is the URL of the release manifest, which is a JSON-encoded
+	// [Release].
+	ManifestURL string
+
+	// CurrentVersion is the version of the running program. The default is
+	// [buildinfo.Version]. Versions must be semantic versions (e.g. "v1.2.3") to
+	// be compared; programs built from source are never considered outdated.
+	CurrentVersion string
+
+	// CacheDir is the directory in which the result of the last check is cached.
+	// The default is a directory named after the binary in
+	// [os.UserCacheDir]. CheckInterval is how long the result is cached, which
+	// defaults to 24 hours.
+	CacheDir      string
+	CheckInterval time.Duration
+
+	// HTTPClient is the client used to download the manifest and binaries. The
+	// default is [http.DefaultClient].
+	HTTPClient *http.Client
+
+	// executable returns the path of the binary to replace. It defaults to
+	// [os.Executable] and is overridden in tests.
+	executable func() (string, error)
+
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+}
+
+// updateCache is the on-disk cache of the last update check.
+type updateCache struct {
+	ManifestURL string    `json:"manifest_url"`
+	CheckedAt   time.Time `json:"checked_at"`
+	Release     *Release  `json:"release"`
+}
+
+// Check returns the latest release, using the cached result of a previous
+// check if it is recent enough. It does not indicate whether the release is
+// newer than the running program; use [Updater.IsNewer] for that.
+func (u *Updater) Check(ctx context.Context) (*Release, error) {
+	if release, ok := u.cached(); ok && release != nil {
+		return release, nil
+	}
+	return u.Refresh(ctx)
+}
+
+// Refresh downloads the release manifest, ignoring any cached result, and
+// caches the result.
+func (u *Updater) Refresh(ctx context.Context) (*Release, error) {
+	if u.ManifestURL == "" {
+		return nil, fmt.Errorf("no release manifest url configured")
+	}
+
+	b, err := u.download(ctx, u.ManifestURL, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download release manifest: %w", err)
+	}
+
+	var release Release
+	if err := json.Unmarshal(b, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release manifest as json: %w", err)
+	}
+	if release.Version == "" {
+		return nil, fmt.Errorf("release manifest is missing a version")
+	}
+
+	// Caching is best-effort, since the cache directory may not be writable.
+	_ = u.writeCache(&release)
+
+	return &release, nil
+}
+
+// IsNewer returns true if the release is newer than the running program.
+func (u *Updater) IsNewer(release *Release) bool {
+	if release == nil {
+		return false
+	}
+	c, ok := compareVersions(release.Version, u.currentVersion())
+	return ok && c > 0
+}
+
+// Update downloads the binary for the current platform from the release,
+// verifies its checksum, and atomically replaces the running binary. The new
+// binary takes effect the next time the program runs. On Windows, where a
+// running binary cannot be replaced, the old binary is first renamed with an
+// ".old" suffix and is removed by the next update.
+func (u *Updater) Update(ctx context.Context, release *Release) error {
+	osArch := buildinfo.OSArch()
+	asset, ok := release.Assets[osArch]
+	if !ok || asset == nil {
+		return fmt.Errorf("release %s has no binary for %s", release.Version, osArch)
+	}
+
+	want, err := hex.DecodeString(asset.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("release %s has an invalid sha256 checksum for %s", release.Version, osArch)
+	}
+
+	assetURL, err := resolveReference(u.ManifestURL, asset.URL)
+	if err != nil {
+		return err
+	}
+
+	b, err := u.download(ctx, assetURL, maxBinarySize)
+	if err != nil {
+		return fmt.Errorf("failed to download release binary: %w", err)
+	}
+
+	if got := sha256.Sum256(b); !strings.EqualFold(hex.EncodeToString(got[:]), asset.SHA256) {
+		return fmt.Errorf("checksum mismatch for release binary: expected %s, got %s",
+			asset.SHA256, hex.EncodeToString(got[:]))
+	}
+
+	executable := u.executable
+	if executable == nil {
+		executable = os.Executable
+	}
+	pth, err := executable()
+	if err != nil {
+		return fmt.Errorf("failed to find running binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(pth); err == nil {
+		pth = resolved
+	}
+
+	if err := replaceExecutable(pth, b); err != nil {
+		return fmt.Errorf("failed to replace binary: %w", err)
+	}
+	return nil
+}
+
+// replaceExecutable replaces the running binary at pth with the given
+// contents. Windows does not allow a running binary to be overwritten, but it
+// can be renamed, so the binary is moved aside before it is replaced.
+func replaceExecutable(pth string, b []byte) error {
+	if runtime.GOOS != "windows" {
+		return replaceFile(pth, b)
+	}
+
+	// The binary left by a previous update is no longer running.
+	old := pth + ".old"
+	if err := os.Remove(old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove previous binary: %w", err)
+	}
+	if err := os.Rename(pth, old); err != nil {
+		return fmt.Errorf("failed to move running binary: %w", err)
+	}
+	if err := replaceFile(pth, b); err != nil {
+		return errors.Join(err, os.Rename(old, pth))
+	}
+	return nil
+}
+
+// Middleware returns a middleware which prints a notice to [Stderr] after the
+// command finishes if a newer release is available. The check uses the cached
+// result if possible and never fails the command. Failed checks are cached
+// too, so an unreachable manifest does not delay every command. The notice is
+// not printed by [VersionCommand], which reports updates itself.
+func (u *Updater) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, inv *Invocation) error {
+			err := next(ctx, inv)
+			if _, ok := inv.Command.(*VersionCommand); ok {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, updateNoticeTimeout)
+			defer cancel()
+
+			release, ok := u.cached()
+			if !ok {
+				var cerr error
+				if release, cerr = u.Refresh(ctx); cerr != nil {
+					_ = u.writeCache(nil)
+				}
+			}
+
+			if u.IsNewer(release) {
+				name, _, _ := strings.Cut(inv.Path, " ")
+				inv.Command.Errf("\n%s", u.notice(name, release))
+			}
+			return err
+		}
+	}
+}
+
+// notice returns the message announcing the new release.
+func (u *Updater) notice(name string, release *Release) string {
+	msg := fmt.Sprintf("A new version of %s is available: %s (current: %s).",
+		name, release.Version, u.currentVersion())
+	if release.NotesURL != "" {
+		msg += " Release notes: " + release.NotesURL
+	}
+	return msg
+}
+
+// currentVersion returns the version of the running program.
+func (u *Updater) currentVersion() string {
+	if u.CurrentVersion != "" {
+		return u.CurrentVersion
+	}
+	return buildinfo.Version()
+}
+
+// cached returns the cached release. The result is false if there is no
+// cached result or it is too old. The release is nil if the cached check
+// failed.
+func (u *Updater) cached() (*Release, bool) {
+	pth, err := u.cachePath()
+	if err != nil {
+		return nil, false
+	}
+
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, false
+	}
+
+	var c updateCache
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, false
+	}
+
+	interval := u.CheckInterval
+	if interval <= 0 {
+		interval = defaultUpdateCheckInterval
+	}
+	if c.ManifestURL != u.ManifestURL || u.timeNow().Sub(c.CheckedAt) > interval {
+		return nil, false
+	}
+	return c.Release, true
+}
+
+// writeCache caches the release. A nil release records a failed check.
+func (u *Updater) writeCache(release *Release) error {
+	pth, err := u.cachePath()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(&updateCache{
+		ManifestURL: u.ManifestURL,
+		CheckedAt:   u.timeNow(),
+		Release:     release,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pth), 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return replaceFile(pth, b)
+}
+
+// cachePath returns the path of the cache file.
+func (u *Updater) cachePath() (string, error) {
+	dir := u.CacheDir
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find cache directory: %w", err)
+		}
+		dir = filepath.Join(base, filepath.Base(os.Args[0]))
+	}
+	return filepath.Join(dir, "update-check.json"), nil
+}
+
+// timeNow returns the current time.
+func (u *Updater) timeNow() time.Time {
+	if u.now != nil {
+		return u.now()
+	}
+	return time.Now()
+}
+
+// download returns the body of the given URL, failing if it is larger than
+// limit bytes.
+func (u *Updater) download(ctx context.Context, target string, limit int64) ([]byte, error) {
+	client := u.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make http request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if got, want := res.StatusCode, http.StatusOK; got != want {
+		return nil, fmt.Errorf("invalid http response status (expected %d to be %d)", got, want)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("response body exceeds %d bytes", limit)
+	}
+	return b, nil
+}
+
+// resolveReference resolves the URL relative to the base URL.
+func resolveReference(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse url %q: %w", base, err)
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse url %q: %w", ref, err)
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+// replaceFile atomically replaces the file at pth with the given contents by
+// writing a temporary file in the same directory and renaming it. The mode of
+// an existing file is preserved.
+func replaceFile(pth string, b []byte) (retErr error) {
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(pth); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(pth), "."+filepath.Base(pth)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		if retErr != nil {
+			retErr = errors.Join(retErr, os.Remove(f.Name()))
+		}
+	}()
+
+	if _, err := f.Write(b); err != nil {
+		return errors.Join(fmt.Errorf("failed to write temporary file: %w", err), f.Close())
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(f.Name(), pth); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	return nil
+}
+
+// compareVersions compares two semantic versions (e.g. "v1.2.3" or
+// "1.2.3-rc.1"), returning -1, 0, or 1. The result is false if either version
+// cannot be parsed. Pre-release versions sort before the release, and are
+// compared with [comparePrerelease].
+func compareVersions(a, b string) (int, bool) {
+	an, apre, aok := parseVersion(a)
+	bn, bpre, bok := parseVersion(b)
+	if !aok || !bok {
+		return 0, false
+	}
+
+	for i := range an {
+		switch {
+		case an[i] < bn[i]:
+			return -1, true
+		case an[i] > bn[i]:
+			return 1, true
+		}
+	}
+
+	switch {
+	case apre == bpre:
+		return 0, true
+	case apre == "":
+		return 1, true
+	case bpre == "":
+		return -1, true
+	default:
+		return comparePrerelease(apre, bpre), true
+	}
+}
+
+// comparePrerelease compares two pre-release versions (e.g. "rc.1"), returning
+// -1, 0, or 1. Dot-separated identifiers are compared in order: numeric
+// identifiers are compared numerically and sort before alphanumeric ones, which
+// are compared lexically. If all identifiers are equal, the version with more
+// identifiers is greater.
+//
+// See: https://semver.org/#spec-item-11
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range min(len(as), len(bs)) {
+		an, aerr := strconv.ParseUint(as[i], 10, 64)
+		bn, berr := strconv.ParseUint(bs[i], 10, 64)
+
+		switch {
+		case aerr == nil && berr == nil:
+			if c := cmp.Compare(an, bn); c != 0 {
+				return c
+			}
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// parseVersion parses the numeric parts and pre-release of a semantic version.
+// Build metadata is ignored.
+func parseVersion(v string) ([3]int, string, bool) {
+	var parts [3]int
+
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "+")
+	v, pre, _ := strings.Cut(v, "-")
+
+	fields := strings.Split(v, ".")
+	if len(fields) != len(parts) {
+		return parts, "", false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, "", false
+		}
+		parts[i] = n
+	}
+	return parts, pre, true
+}
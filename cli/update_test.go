@@ -0,0 +1,415 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/buildinfo"
+	"github.com/abcxyz/pkg/testutil"
+)
+
+// testReleaseServer serves a release manifest at /latest.json and the release
+// binary at /bin. It returns the server and the number of manifest requests.
+func testReleaseServer(tb testing.TB, release *Release, binary []byte) (*httptest.Server, *atomic.Int64) {
+	tb.Helper()
+
+	var manifestHits atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest.json", func(w http.ResponseWriter, r *http.Request) {
+		manifestHits.Add(1)
+		if err := json.NewEncoder(w).Encode(release); err != nil {
+			tb.Errorf("failed to encode manifest: %s", err)
+		}
+	})
+	mux.HandleFunc("/bin", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(binary)
+	})
+
+	srv := httptest.NewServer(mux)
+	tb.Cleanup(srv.Close)
+	return srv, &manifestHits
+}
+
+func testChecksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		a, b   string
+		want   int
+		wantOK bool
+	}{
+		{a: "v1.2.3", b: "v1.2.3", want: 0, wantOK: true},
+		{a: "v1.2.4", b: "v1.2.3", want: 1, wantOK: true},
+		{a: "1.10.0", b: "v1.9.9", want: 1, wantOK: true},
+		{a: "v1.2.3", b: "v2.0.0", want: -1, wantOK: true},
+		{a: "v1.2.3-rc.1", b: "v1.2.3", want: -1, wantOK: true},
+		{a: "v1.2.3-rc.2", b: "v1.2.3-rc.1", want: 1, wantOK: true},
+		{a: "v1.0.0-rc.10", b: "v1.0.0-rc.9", want: 1, wantOK: true},
+		{a: "v1.0.0-alpha", b: "v1.0.0-alpha.1", want: -1, wantOK: true},
+		{a: "v1.0.0-alpha.1", b: "v1.0.0-alpha.beta", want: -1, wantOK: true},
+		{a: "v1.0.0-beta", b: "v1.0.0-alpha.beta", want: 1, wantOK: true},
+		{a: "v1.2.3+build.5", b: "v1.2.3", want: 0, wantOK: true},
+		{a: "v1.2.3", b: "source", wantOK: false},
+		{a: "v1.2", b: "v1.2.0", wantOK: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.a+"_"+tc.b, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := compareVersions(tc.a, tc.b)
+			if ok != tc.wantOK {
+				t.Fatalf("expected ok %t to be %t", ok, tc.wantOK)
+			}
+			if got != tc.want {
+				t.Errorf("expected %d to be %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUpdater_Check(t *testing.T) {
+	t.Parallel()
+
+	srv, hits := testReleaseServer(t, &Release{Version: "v1.3.0"}, nil)
+
+	u := &Updater{
+		ManifestURL:    srv.URL + "/latest.json",
+		CurrentVersion: "v1.2.0",
+		CacheDir:       t.TempDir(),
+	}
+
+	for range 3 {
+		release, err := u.Check(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := release.Version, "v1.3.0"; got != want {
+			t.Errorf("expected version %q to be %q", got, want)
+		}
+		if !u.IsNewer(release) {
+			t.Errorf("expected %s to be newer than %s", release.Version, u.CurrentVersion)
+		}
+	}
+	if got, want := hits.Load(), int64(1); got != want {
+		t.Errorf("expected %d manifest requests to be %d", got, want)
+	}
+
+	// Expired results are checked again.
+	u.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	if _, err := u.Check(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hits.Load(), int64(2); got != want {
+		t.Errorf("expected %d manifest requests to be %d", got, want)
+	}
+
+	// Programs built from source are never outdated.
+	u.CurrentVersion = "source"
+	if u.IsNewer(&Release{Version: "v1.3.0"}) {
+		t.Errorf("expected source build to not be outdated")
+	}
+}
+
+func TestUpdater_Update(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("#!/bin/sh\necho new\n")
+
+	cases := []struct {
+		name      string
+		assets    map[string]*ReleaseAsset
+		wantError string
+	}{
+		{
+			name: "success",
+			assets: map[string]*ReleaseAsset{
+				buildinfo.OSArch(): {URL: "bin", SHA256: testChecksum(binary)},
+			},
+		},
+		{
+			name: "checksum_mismatch",
+			assets: map[string]*ReleaseAsset{
+				buildinfo.OSArch(): {URL: "bin", SHA256: testChecksum([]byte("other"))},
+			},
+			wantError: "checksum mismatch for release binary",
+		},
+		{
+			name: "missing_platform",
+			assets: map[string]*ReleaseAsset{
+				"plan9/mips": {URL: "bin", SHA256: testChecksum(binary)},
+			},
+			wantError: "release v1.3.0 has no binary for " + buildinfo.OSArch(),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			release := &Release{Version: "v1.3.0", Assets: tc.assets}
+			srv, _ := testReleaseServer(t, release, binary)
+
+			pth := filepath.Join(t.TempDir(), "tool")
+			if err := os.WriteFile(pth, []byte("old"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			u := &Updater{
+				ManifestURL: srv.URL + "/latest.json",
+				CacheDir:    t.TempDir(),
+				executable:  func() (string, error) { return pth, nil },
+			}
+
+			err := u.Update(t.Context(), release)
+			if diff := testutil.DiffErrString(err, tc.wantError); diff != "" {
+				t.Error(diff)
+			}
+
+			want := "old"
+			if tc.wantError == "" {
+				want = string(binary)
+			}
+			got, err := os.ReadFile(pth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("expected binary %q to be %q", got, want)
+			}
+
+			info, err := os.Stat(pth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := info.Mode().Perm(), os.FileMode(0o755); got != want {
+				t.Errorf("expected mode %s to be %s", got, want)
+			}
+
+			// No temporary files are left behind.
+			entries, err := os.ReadDir(filepath.Dir(pth))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := len(entries), 1; got != want {
+				t.Errorf("expected %d files to be %d", got, want)
+			}
+		})
+	}
+}
+
+func TestUpdater_Middleware(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := testReleaseServer(t, &Release{
+		Version:  "v1.3.0",
+		NotesURL: "https://example.com/v1.3.0",
+	}, nil)
+
+	u := &Updater{
+		ManifestURL:    srv.URL + "/latest.json",
+		CurrentVersion: "v1.2.0",
+		CacheDir:       t.TempDir(),
+	}
+
+	rootCmd := &RootCommand{
+		Name:       "tool",
+		Middleware: []Middleware{u.Middleware()},
+		Commands: map[string]CommandFactory{
+			"leaf": func() Command {
+				return &TestCommand{Output: "done"}
+			},
+			"version": func() Command {
+				return &VersionCommand{Version: "v1.2.0", Commit: "abc", OSArch: "linux/amd64"}
+			},
+		},
+	}
+
+	_, _, stderr := rootCmd.Pipe()
+	if err := rootCmd.Run(t.Context(), []string{"leaf"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stderr.String(), "A new version of tool is available: v1.3.0 (current: v1.2.0). "+
+		"Release notes: https://example.com/v1.3.0"; !strings.Contains(got, want) {
+		t.Errorf("expected %q to contain %q", got, want)
+	}
+
+	_, _, stderr = rootCmd.Pipe()
+	if err := rootCmd.Run(t.Context(), []string{"version"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stderr.String(); got != "" {
+		t.Errorf("expected no notice from version command, got %q", got)
+	}
+}
+
+func TestUpdater_Middleware_failedCheck(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+
+	u := &Updater{
+		ManifestURL:    srv.URL + "/latest.json",
+		CurrentVersion: "v1.2.0",
+		CacheDir:       t.TempDir(),
+	}
+
+	rootCmd := &RootCommand{
+		Name:       "tool",
+		Middleware: []Middleware{u.Middleware()},
+		Commands: map[string]CommandFactory{
+			"leaf": func() Command {
+				return &TestCommand{Output: "done"}
+			},
+		},
+	}
+
+	for range 3 {
+		_, _, stderr := rootCmd.Pipe()
+		if err := rootCmd.Run(t.Context(), []string{"leaf"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := stderr.String(); got != "" {
+			t.Errorf("expected no notice, got %q", got)
+		}
+	}
+	if got, want := hits.Load(), int64(1); got != want {
+		t.Errorf("expected %d manifest requests to be %d", got, want)
+	}
+
+	// Failed checks are not returned by Check.
+	if _, err := u.Check(t.Context()); err == nil {
+		t.Errorf("expected error")
+	}
+	if got, want := hits.Load(), int64(2); got != want {
+		t.Errorf("expected %d manifest requests to be %d", got, want)
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := testReleaseServer(t, &Release{Version: "v1.3.0"}, nil)
+
+	cases := []struct {
+		name       string
+		args       []string
+		updater    bool
+		wantStdout string
+		wantStderr string
+		wantError  string
+	}{
+		{
+			name:       "text",
+			wantStdout: "tool v1.2.0 (abc123, linux/amd64)\n",
+		},
+		{
+			name: "json",
+			args: []string{"-json"},
+			wantStdout: `{"name":"tool","version":"v1.2.0","commit":"abc123",` +
+				`"os_arch":"linux/amd64"}` + "\n",
+		},
+		{
+			name:       "check",
+			args:       []string{"-check"},
+			updater:    true,
+			wantStdout: "tool v1.2.0 (abc123, linux/amd64)\n",
+			wantStderr: "A new version of tool is available: v1.3.0 (current: v1.2.0).\n",
+		},
+		{
+			name:    "check_json",
+			args:    []string{"-check", "-json"},
+			updater: true,
+			wantStdout: `{"name":"tool","version":"v1.2.0","commit":"abc123",` +
+				`"os_arch":"linux/amd64","latest_version":"v1.3.0","update_available":true}` + "\n",
+			wantStderr: "A new version of tool is available: v1.3.0 (current: v1.2.0).\n",
+		},
+		{
+			name:      "check_disabled",
+			args:      []string{"-check"},
+			wantError: "flag provided but not defined: -check",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := &VersionCommand{
+				Name:    "tool",
+				Version: "v1.2.0",
+				Commit:  "abc123",
+				OSArch:  "linux/amd64",
+			}
+			if tc.updater {
+				cmd.Updater = &Updater{
+					ManifestURL: srv.URL + "/latest.json",
+					CacheDir:    t.TempDir(),
+				}
+			}
+			_, stdout, stderr := cmd.Pipe()
+
+			err := cmd.Run(t.Context(), tc.args)
+			if diff := testutil.DiffErrString(err, tc.wantError); diff != "" {
+				t.Error(diff)
+			}
+			if tc.wantError != "" {
+				return
+			}
+			if got, want := compactJSON(t, stdout.String()), compactJSON(t, tc.wantStdout); got != want {
+				t.Errorf("expected stdout %q to be %q", got, want)
+			}
+			if got, want := stderr.String(), tc.wantStderr; got != want {
+				t.Errorf("expected stderr %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+// compactJSON removes insignificant whitespace if s is JSON.
+func compactJSON(tb testing.TB, s string) string {
+	tb.Helper()
+
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(b) + "\n"
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/abcxyz/pkg/buildinfo"
+)
+
+// Ensure [VersionCommand] implements [Command].
+var _ Command = (*VersionCommand)(nil)
+
+// VersionCommand is an optional command which prints version information, and
+// optionally checks for and installs updates. Add it to
+// [RootCommand.Commands]:
+//
+//	Commands: map[string]cli.CommandFactory{
+//		"version": func() cli.Command {
+//			return &cli.VersionCommand{
+//				Name: "my-tool",
+//				Updater: &cli.Updater{
+//					ManifestURL: "https://example.com/my-tool/latest.json",
+//				},
+//			}
+//		},
+//	}
+type VersionCommand struct {
+	BaseCommand
+
+	// Name is the name of the program. The default is the name of the binary.
+	Name string
+
+	// Version, Commit, and OSArch describe the build. The defaults are
+	// [buildinfo.Version], [buildinfo.Commit], and [buildinfo.OSArch].
+	Version string
+	Commit  string
+	OSArch  string
+
+	// Updater enables the -check and -update flags. If nil, update checks are
+	// disabled.
+	Updater *Updater
+
+	flagJSON   bool
+	flagCheck  bool
+	flagUpdate bool
+}
+
+// versionInfo is the version information printed by [VersionCommand].
+type versionInfo struct {
+	Name            string `json:"name"`
+	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	OSArch          string `json:"os_arch"`
+	LatestVersion   string `json:"latest_version,omitempty"`
+	UpdateAvailable *bool  `json:"update_available,omitempty"`
+}
+
+// String returns the human-readable version (e.g. "my-tool v1.2.3 (abc123,
+// linux/amd64)").
+func (i *versionInfo) String() string {
+	return fmt.Sprintf("%s %s (%s, %s)", i.Name, i.Version, i.Commit, i.OSArch)
+}
+
+func (c *VersionCommand) Desc() string {
+	return "Print the version"
+}
+
+func (c *VersionCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+
+  Print the version, commit, and platform of this program.
+`
+}
+
+func (c *VersionCommand) Flags() *FlagSet {
+	set := c.NewFlagSet()
+
+	f := set.NewSection("OUTPUT OPTIONS")
+	f.BoolVar(&BoolVar{
+		Name:   "json",
+		Target: &c.flagJSON,
+		Usage:  "Print the version information as JSON.",
+	})
+
+	if c.Updater != nil {
+		f = set.NewSection("UPDATE OPTIONS")
+		f.BoolVar(&BoolVar{
+			Name:   "check",
+			Target: &c.flagCheck,
+			Usage:  "Check whether a newer version is available.",
+		})
+		f.BoolVar(&BoolVar{
+			Name:   "update",
+			Target: &c.flagUpdate,
+			Usage: "Download the newest version, if one is available, and replace " +
+				"this binary with it.",
+		})
+	}
+
+	return set
+}
+
+func (c *VersionCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	if args := f.Args(); len(args) > 0 {
+		return fmt.Errorf("expected 0 arguments, got %q", args)
+	}
+
+	info := &versionInfo{
+		Name:    valueOrDefault(c.Name, filepath.Base(os.Args[0])),
+		Version: valueOrDefault(c.Version, buildinfo.Version()),
+		Commit:  valueOrDefault(c.Commit, buildinfo.Commit()),
+		OSArch:  valueOrDefault(c.OSArch, buildinfo.OSArch()),
+	}
+
+	// Without an explicit check, only the cached result of a previous check is
+	// reported, so printing the version never requires the network.
+	var u *Updater
+	var release *Release
+	if c.Updater != nil {
+		// Compare against the version which is printed.
+		u = ptr(*c.Updater)
+		u.CurrentVersion = valueOrDefault(u.CurrentVersion, info.Version)
+
+		if c.flagCheck || c.flagUpdate {
+			r, err := u.Refresh(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to check for updates: %w", err)
+			}
+			release = r
+		} else {
+			release, _ = u.cached()
+		}
+	}
+
+	var newer bool
+	if release != nil {
+		newer = u.IsNewer(release)
+		info.LatestVersion = release.Version
+		info.UpdateAvailable = &newer
+	}
+
+	if c.flagJSON {
+		if err := c.Render(OutputFormatJSON, info); err != nil {
+			return err
+		}
+	} else {
+		c.Outf("%s", info)
+	}
+
+	switch {
+	case c.flagUpdate && newer:
+		if err := u.Update(ctx, release); err != nil {
+			return fmt.Errorf("failed to update: %w", err)
+		}
+		c.Errf("Updated %s from %s to %s.", info.Name, info.Version, release.Version)
+	case newer:
+		c.Errf("%s", u.notice(info.Name, release))
+	case c.flagCheck || c.flagUpdate:
+		c.Errf("%s is up to date.", info.Name)
+	}
+	return nil
+}
+
+// valueOrDefault returns v, or the default if v is empty.
+func valueOrDefault(v, def string) string {
+	if v != "" {
+		return v
+	}
+	return def
+}
@@ -13,6 +13,7 @@ require (
 	github.com/sethvargo/go-retry v0.3.0
 	golang.org/x/oauth2 v0.27.0
 	golang.org/x/sync v0.12.0
+	golang.org/x/sys v0.31.0
 	golang.org/x/text v0.23.0
 	google.golang.org/grpc v1.70.0
 	google.golang.org/protobuf v1.36.5
@@ -22,6 +23,5 @@ require (
 require (
 	github.com/posener/script v1.2.0 // indirect
 	golang.org/x/net v0.38.0 // indirect
-	golang.org/x/sys v0.31.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250212204824-5a70512c5d8b // indirect
 )
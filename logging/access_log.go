@@ -0,0 +1,250 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bufio"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// googleCloudHTTPRequestKey is the key in the structured log where the HTTP
+// request information is expected to be present.
+var googleCloudHTTPRequestKey = "httpRequest"
+
+// accessLogOptions is a holding structure for configurable access log options.
+type accessLogOptions struct {
+	sampleRate    float64
+	excludedPaths map[string]struct{}
+	levelFunc     func(status int) slog.Level
+
+	// random returns a number in [0.0, 1.0) to decide whether to sample a
+	// request.
+	random func() float64
+}
+
+// AccessLogOption represents a configuration function for
+// [HTTPAccessLogInterceptor].
+type AccessLogOption func(o *accessLogOptions) *accessLogOptions
+
+// WithAccessLogSampleRate sets the fraction of requests which are logged,
+// between 0.0 (none) and 1.0 (all). Requests which fail with a server error
+// (5xx) are always logged. The default is 1.0.
+func WithAccessLogSampleRate(rate float64) AccessLogOption {
+	return func(o *accessLogOptions) *accessLogOptions {
+		o.sampleRate = min(max(rate, 0), 1)
+		return o
+	}
+}
+
+// WithAccessLogExcludedPaths sets URL paths which are never logged, such as the
+// path of the health check handler. Paths must match exactly.
+func WithAccessLogExcludedPaths(paths ...string) AccessLogOption {
+	return func(o *accessLogOptions) *accessLogOptions {
+		for _, pth := range paths {
+			o.excludedPaths[pth] = struct{}{}
+		}
+		return o
+	}
+}
+
+// WithAccessLogLevelFunc sets the function which selects the level of the log
+// entry from the response status code. The default logs server errors (5xx) at
+// [LevelError], client errors (4xx) at [LevelWarning], and everything else at
+// [LevelInfo].
+func WithAccessLogLevelFunc(fn func(status int) slog.Level) AccessLogOption {
+	return func(o *accessLogOptions) *accessLogOptions {
+		if fn != nil {
+			o.levelFunc = fn
+		}
+		return o
+	}
+}
+
+// HTTPAccessLogInterceptor returns an HTTP middleware that logs each request
+// after it is handled, using the logger in the request context. The request is
+// recorded in the [httpRequest] field of the log entry, which Google Cloud
+// Logging displays as a request log.
+//
+// Wrap the middleware with [HTTPInterceptor] so the log entries include the
+// trace information:
+//
+//	handler = logging.HTTPInterceptor(logger, projectID)(
+//		logging.HTTPAccessLogInterceptor(
+//			logging.WithAccessLogExcludedPaths("/healthz"),
+//		)(handler))
+//
+// [httpRequest]: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+func HTTPAccessLogInterceptor(opts ...AccessLogOption) func(http.Handler) http.Handler {
+	o := &accessLogOptions{
+		sampleRate:    1.0,
+		excludedPaths: make(map[string]struct{}),
+		levelFunc:     defaultAccessLogLevel,
+		random:        rand.Float64,
+	}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := o.excludedPaths[r.URL.Path]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rw := &accessLogResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+			latency := time.Since(start)
+
+			status := rw.statusCode()
+			if status < http.StatusInternalServerError && o.random() >= o.sampleRate {
+				return
+			}
+
+			ctx := r.Context()
+			FromContext(ctx).LogAttrs(ctx, o.levelFunc(status), r.Method+" "+r.URL.Path,
+				slog.Group(googleCloudHTTPRequestKey, httpRequestAttrs(r, status, rw.size, latency)...))
+		})
+	}
+}
+
+// defaultAccessLogLevel returns the level of the log entry for the response
+// status code.
+func defaultAccessLogLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return LevelError
+	case status >= http.StatusBadRequest:
+		return LevelWarning
+	default:
+		return LevelInfo
+	}
+}
+
+// httpRequestAttrs returns the attributes of the Google Cloud Logging
+// httpRequest field. Empty values are omitted.
+func httpRequestAttrs(r *http.Request, status int, size int64, latency time.Duration) []any {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	u := *r.URL
+	u.Scheme = scheme
+	u.Host = r.Host
+
+	attrs := []any{
+		slog.String("requestMethod", r.Method),
+		slog.String("requestUrl", u.String()),
+		slog.Int("status", status),
+		// Sizes are 64-bit integers, which are encoded as strings in JSON.
+		slog.String("responseSize", strconv.FormatInt(size, 10)),
+		// Latency is a duration in seconds with up to nine fractional digits
+		// and an "s" suffix (e.g. "0.25s").
+		slog.String("latency", strconv.FormatFloat(latency.Seconds(), 'f', -1, 64)+"s"),
+		slog.String("protocol", r.Proto),
+	}
+
+	if r.ContentLength > 0 {
+		attrs = append(attrs, slog.String("requestSize", strconv.FormatInt(r.ContentLength, 10)))
+	}
+	if v := r.UserAgent(); v != "" {
+		attrs = append(attrs, slog.String("userAgent", v))
+	}
+	if v := r.Referer(); v != "" {
+		attrs = append(attrs, slog.String("referer", v))
+	}
+	if v := remoteIP(r.RemoteAddr); v != "" {
+		attrs = append(attrs, slog.String("remoteIp", v))
+	}
+	return attrs
+}
+
+// remoteIP returns the IP address from the remote address of a request, which
+// is usually in the form "host:port".
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// accessLogResponseWriter wraps an [http.ResponseWriter] to record the status
+// code and the number of bytes written.
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+	size   int64
+}
+
+// WriteHeader records the status code. Informational (1xx) responses are not
+// recorded, since they are followed by the final response, except for 101
+// Switching Protocols, which is the final response over HTTP.
+func (w *accessLogResponseWriter) WriteHeader(code int) {
+	if w.status == 0 && (code >= http.StatusOK || code == http.StatusSwitchingProtocols) {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the number of bytes written.
+func (w *accessLogResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err //nolint:wrapcheck // Want passthrough
+}
+
+// Flush implements [http.Flusher] if the underlying writer supports it.
+func (w *accessLogResponseWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements [http.Hijacker] if the underlying writer supports it, so
+// handlers can take over the connection (e.g. for WebSockets). Handlers which
+// hijack the connection without writing a status are recorded as 101 Switching
+// Protocols, since the response is no longer written by the server.
+func (w *accessLogResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // Want passthrough
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, nil
+}
+
+// Unwrap returns the underlying writer, for use by [http.ResponseController].
+func (w *accessLogResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the recorded status code. Handlers which do not write a
+// response implicitly respond with 200 OK.
+func (w *accessLogResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,235 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPAccessLogInterceptor(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		target string
+		status int
+		opts   []AccessLogOption
+		exp    map[string]any
+	}{
+		{
+			name:   "ok",
+			target: "/foo?bar=baz",
+			status: http.StatusOK,
+			exp: map[string]any{
+				"level": "INFO",
+				"msg":   "POST /foo",
+				"httpRequest": map[string]any{
+					"requestMethod": "POST",
+					"requestUrl":    "http://example.com/foo?bar=baz",
+					"requestSize":   "5",
+					"status":        float64(200),
+					"responseSize":  "11",
+					"protocol":      "HTTP/1.1",
+					"userAgent":     "test-agent",
+					"referer":       "https://example.com/",
+					"remoteIp":      "192.0.2.1",
+				},
+			},
+		},
+		{
+			name:   "client_error",
+			target: "/missing",
+			status: http.StatusNotFound,
+			exp: map[string]any{
+				"level": "WARN",
+				"msg":   "POST /missing",
+			},
+		},
+		{
+			name:   "server_error",
+			target: "/fail",
+			status: http.StatusInternalServerError,
+			exp: map[string]any{
+				"level": "ERROR",
+				"msg":   "POST /fail",
+			},
+		},
+		{
+			name:   "excluded",
+			target: "/healthz",
+			status: http.StatusOK,
+			opts:   []AccessLogOption{WithAccessLogExcludedPaths("/healthz")},
+			exp:    nil,
+		},
+		{
+			name:   "not_sampled",
+			target: "/foo",
+			status: http.StatusOK,
+			opts:   []AccessLogOption{WithAccessLogSampleRate(0)},
+			exp:    nil,
+		},
+		{
+			name:   "not_sampled_server_error",
+			target: "/foo",
+			status: http.StatusBadGateway,
+			opts:   []AccessLogOption{WithAccessLogSampleRate(0)},
+			exp: map[string]any{
+				"level": "ERROR",
+				"msg":   "POST /foo",
+			},
+		},
+		{
+			name:   "level_func",
+			target: "/foo",
+			status: http.StatusNotFound,
+			opts: []AccessLogOption{WithAccessLogLevelFunc(func(status int) slog.Level {
+				return LevelDebug
+			})},
+			exp: map[string]any{
+				"level": "DEBUG",
+				"msg":   "POST /foo",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+				Level: LevelDebug,
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					// Drop time and latency keys for deterministic tests
+					if a.Key == slog.TimeKey || a.Key == "latency" {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader("hello"))
+			r.RemoteAddr = "192.0.2.1:1234"
+			r.Header.Set("User-Agent", "test-agent")
+			r.Header.Set("Referer", "https://example.com/")
+			r = r.WithContext(WithLogger(t.Context(), logger))
+
+			interceptor := HTTPAccessLogInterceptor(tc.opts...)
+			interceptor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte("hello world"))
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if tc.exp == nil {
+				if got := buf.String(); got != "" {
+					t.Errorf("expected no log entry, got %q", got)
+				}
+				return
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("failed to parse log entry %q: %s", buf.String(), err)
+			}
+
+			// Only compare the request details for cases which define them.
+			if _, ok := tc.exp["httpRequest"]; !ok {
+				delete(got, "httpRequest")
+			}
+			if diff := cmp.Diff(tc.exp, got); diff != "" {
+				t.Errorf("log entry (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAccessLogResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	w := &accessLogResponseWriter{ResponseWriter: rec}
+
+	// Handlers which do not write a response respond with 200 OK.
+	if got, want := w.statusCode(), http.StatusOK; got != want {
+		t.Errorf("expected status %d to be %d", got, want)
+	}
+
+	// Informational responses are followed by the final response.
+	w.WriteHeader(http.StatusEarlyHints)
+	if got, want := w.status, 0; got != want {
+		t.Errorf("expected status %d to be %d", got, want)
+	}
+
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Errorf("expected flush to be supported: %s", err)
+	}
+	if !rec.Flushed {
+		t.Errorf("expected underlying writer to be flushed")
+	}
+
+	// The recorder cannot be hijacked.
+	if _, _, err := http.NewResponseController(w).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected hijack to not be supported, got %v", err)
+	}
+}
+
+func TestAccessLogResponseWriter_switchingProtocols(t *testing.T) {
+	t.Parallel()
+
+	w := &accessLogResponseWriter{ResponseWriter: httptest.NewRecorder()}
+	w.WriteHeader(http.StatusSwitchingProtocols)
+	if got, want := w.statusCode(), http.StatusSwitchingProtocols; got != want {
+		t.Errorf("expected status %d to be %d", got, want)
+	}
+}
+
+// hijackRecorder is a response recorder which supports [http.Hijacker].
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func TestAccessLogResponseWriter_hijack(t *testing.T) {
+	t.Parallel()
+
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := &accessLogResponseWriter{ResponseWriter: rec}
+
+	if _, _, err := http.NewResponseController(w).Hijack(); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.hijacked {
+		t.Errorf("expected underlying writer to be hijacked")
+	}
+	if got, want := w.statusCode(), http.StatusSwitchingProtocols; got != want {
+		t.Errorf("expected status %d to be %d", got, want)
+	}
+}
@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"strings"
 
 	"google.golang.org/grpc"
 	grpcmetadata "google.golang.org/grpc/metadata"
@@ -32,11 +31,54 @@ var (
 	// googleCloudTraceKey is the key in the structured log where trace information
 	// is expected to be present.
 	googleCloudTraceKey = "logging.googleapis.com/trace"
+
+	// googleCloudSpanIDKey and googleCloudTraceSampledKey are the keys in the
+	// structured log for the span ID and whether the trace was sampled.
+	googleCloudSpanIDKey       = "logging.googleapis.com/spanId"
+	googleCloudTraceSampledKey = "logging.googleapis.com/trace_sampled"
 )
 
+// interceptorOptions is a holding structure for configurable interceptor
+// options.
+type interceptorOptions struct {
+	extractors []TraceExtractor
+}
+
+// InterceptorOption represents a configuration function for the HTTP and gRPC
+// interceptors.
+type InterceptorOption func(o *interceptorOptions) *interceptorOptions
+
+// WithTraceExtractors sets the extractors used to read the trace context from
+// incoming requests. Extractors are tried in order, and the first trace context
+// found is used. The default is [DefaultTraceExtractors].
+func WithTraceExtractors(extractors ...TraceExtractor) InterceptorOption {
+	return func(o *interceptorOptions) *interceptorOptions {
+		o.extractors = extractors
+		return o
+	}
+}
+
+// newInterceptorOptions applies the options to the defaults.
+func newInterceptorOptions(opts ...InterceptorOption) *interceptorOptions {
+	o := &interceptorOptions{
+		extractors: DefaultTraceExtractors(),
+	}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	return o
+}
+
 // GRPCStreamingInterceptor returns client-side a gRPC streaming interceptor
 // that populates a logger with trace data in the context.
-func GRPCStreamingInterceptor(inLogger *slog.Logger, projectID string) grpc.StreamClientInterceptor {
+//
+// Deprecated: This interceptor reads the metadata of the incoming request, so
+// it only has an effect for streams opened while handling a request. Use
+// [GRPCStreamServerInterceptor] on servers, and [GRPCStreamClientInterceptor]
+// to propagate the trace context on clients.
+func GRPCStreamingInterceptor(inLogger *slog.Logger, projectID string, opts ...InterceptorOption) grpc.StreamClientInterceptor {
+	o := newInterceptorOptions(opts...)
+
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
 		// Only override the logger if it's the default logger. This is only used
 		// for testing and is intentionally a strict object equality check because
@@ -47,12 +89,9 @@ func GRPCStreamingInterceptor(inLogger *slog.Logger, projectID string) grpc.Stre
 		}
 		ctx = WithLogger(ctx, logger)
 
-		metadata, ok := grpcmetadata.FromIncomingContext(ctx)
-		if ok && len(metadata.Get(googleCloudTraceHeader)) > 0 {
-			header := metadata.Get(googleCloudTraceHeader)[0]
-			if header != "" {
-				ctx = withTracedLogger(ctx, projectID, header)
-			}
+		if metadata, ok := grpcmetadata.FromIncomingContext(ctx); ok {
+			tc := extractTraceContext(o.extractors, metadataGetter(metadata))
+			ctx = withTracedLogger(ctx, projectID, tc)
 		}
 
 		clientStream, err := streamer(ctx, desc, cc, method, opts...)
@@ -65,7 +104,9 @@ func GRPCStreamingInterceptor(inLogger *slog.Logger, projectID string) grpc.Stre
 
 // GRPCUnaryInterceptor returns a server-side gRPC unary interceptor that
 // populates a logger with trace data in the context.
-func GRPCUnaryInterceptor(inLogger *slog.Logger, projectID string) grpc.UnaryServerInterceptor {
+func GRPCUnaryInterceptor(inLogger *slog.Logger, projectID string, opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	o := newInterceptorOptions(opts...)
+
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
 		// Only override the logger if it's the default logger. This is only used
 		// for testing and is intentionally a strict object equality check because
@@ -76,21 +117,67 @@ func GRPCUnaryInterceptor(inLogger *slog.Logger, projectID string) grpc.UnarySer
 		}
 		ctx = WithLogger(ctx, logger)
 
-		metadata, ok := grpcmetadata.FromIncomingContext(ctx)
-		if ok && len(metadata.Get(googleCloudTraceHeader)) > 0 {
-			header := metadata.Get(googleCloudTraceHeader)[0]
-			if header != "" {
-				ctx = withTracedLogger(ctx, projectID, header)
-			}
+		if metadata, ok := grpcmetadata.FromIncomingContext(ctx); ok {
+			tc := extractTraceContext(o.extractors, metadataGetter(metadata))
+			ctx = withTracedLogger(ctx, projectID, tc)
 		}
 
 		return handler(ctx, req)
 	}
 }
 
+// GRPCStreamServerInterceptor returns a server-side gRPC streaming interceptor
+// that populates a logger with trace data in the context of the stream.
+func GRPCStreamServerInterceptor(inLogger *slog.Logger, projectID string, opts ...InterceptorOption) grpc.StreamServerInterceptor {
+	o := newInterceptorOptions(opts...)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+
+		// Only override the logger if it's the default logger. This is only used
+		// for testing and is intentionally a strict object equality check because
+		// the default logger is a global default in the logger package.
+		logger := inLogger
+		if existing := FromContext(ctx); existing != DefaultLogger() {
+			logger = existing
+		}
+		ctx = WithLogger(ctx, logger)
+
+		if metadata, ok := grpcmetadata.FromIncomingContext(ctx); ok {
+			tc := extractTraceContext(o.extractors, metadataGetter(metadata))
+			ctx = withTracedLogger(ctx, projectID, tc)
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// GRPCUnaryClientInterceptor returns a client-side gRPC unary interceptor that
+// propagates the trace context of the incoming request (see
+// [TraceContextFromContext]) to the outgoing request, so logs across services
+// are correlated. If the outgoing metadata already has any trace header (for
+// example, set by a tracing library), the trace context is not propagated, so
+// the request never carries conflicting trace contexts.
+func GRPCUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withOutgoingTraceContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// GRPCStreamClientInterceptor returns a client-side gRPC streaming interceptor
+// that propagates the trace context of the incoming request to the outgoing
+// stream. See [GRPCUnaryClientInterceptor] for details.
+func GRPCStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withOutgoingTraceContext(ctx), desc, cc, method, opts...)
+	}
+}
+
 // HTTPInterceptor returns an HTTP middleware that populates a logger with trace
 // data onto the incoming and outgoing [http.Request] context.
-func HTTPInterceptor(inLogger *slog.Logger, projectID string) func(http.Handler) http.Handler {
+func HTTPInterceptor(inLogger *slog.Logger, projectID string, opts ...InterceptorOption) func(http.Handler) http.Handler {
+	o := newInterceptorOptions(opts...)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -104,10 +191,8 @@ func HTTPInterceptor(inLogger *slog.Logger, projectID string) func(http.Handler)
 			}
 			ctx = WithLogger(ctx, logger)
 
-			header := r.Header.Get(googleCloudTraceHeader)
-			if header != "" {
-				ctx = withTracedLogger(ctx, projectID, header)
-			}
+			tc := extractTraceContext(o.extractors, r.Header.Get)
+			ctx = withTracedLogger(ctx, projectID, tc)
 
 			r = r.WithContext(ctx)
 			next.ServeHTTP(w, r)
@@ -115,19 +200,71 @@ func HTTPInterceptor(inLogger *slog.Logger, projectID string) func(http.Handler)
 	}
 }
 
-// withTracedLogger is a helper function that extracts the trace information
-// from the given header and puts it into the log entry. It is shared among HTTP
-// and GRPC interceptors.
-func withTracedLogger(ctx context.Context, projectID, header string) context.Context {
-	logger := FromContext(ctx)
+// withTracedLogger is a helper function that puts the trace information into
+// the log entry and stores the trace context in the context. It is shared among
+// HTTP and GRPC interceptors. If the trace context is nil, the context is
+// returned unchanged.
+func withTracedLogger(ctx context.Context, projectID string, tc *TraceContext) context.Context {
+	if tc == nil {
+		return ctx
+	}
 
-	// On Google Cloud, extract the trace context and add it to the logger.
-	// See: https://cloud.google.com/trace/docs/setup#force-trace
-	parts := strings.Split(header, "/")
-	if len(parts) > 0 && len(parts[0]) > 0 {
-		val := fmt.Sprintf("projects/%s/traces/%s", projectID, parts[0])
-		logger = logger.With(googleCloudTraceKey, val)
+	// Use the special fields which correlate log entries with traces on Google
+	// Cloud.
+	// See: https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+	attrs := []any{googleCloudTraceKey, fmt.Sprintf("projects/%s/traces/%s", projectID, tc.TraceID)}
+	if tc.SpanID != "" {
+		attrs = append(attrs, googleCloudSpanIDKey, tc.SpanID)
 	}
+	if tc.Sampled != nil {
+		attrs = append(attrs, googleCloudTraceSampledKey, *tc.Sampled)
+	}
+
+	ctx = WithTraceContext(ctx, tc)
+	return WithLogger(ctx, FromContext(ctx).With(attrs...))
+}
 
-	return WithLogger(ctx, logger)
+// withOutgoingTraceContext adds the trace headers for the trace context stored
+// in the context to the outgoing gRPC metadata, unless the metadata already has
+// any trace header.
+func withOutgoingTraceContext(ctx context.Context) context.Context {
+	headers := TraceContextFromContext(ctx).traceHeaders()
+	if len(headers) == 0 {
+		return ctx
+	}
+
+	existing, _ := grpcmetadata.FromOutgoingContext(ctx)
+	for _, k := range traceHeaderKeys() {
+		if len(existing.Get(k)) > 0 {
+			return ctx
+		}
+	}
+
+	kv := make([]string, 0, 2*len(headers))
+	for k, v := range headers {
+		kv = append(kv, k, v)
+	}
+	return grpcmetadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// serverStream wraps a [grpc.ServerStream] to override its context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx // Required to override the stream context
+}
+
+// Context returns the context of the stream.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataGetter returns a function which returns the first value of the gRPC
+// metadata key, or the empty string if the key is not present.
+func metadataGetter(md grpcmetadata.MD) func(key string) string {
+	return func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
 }
@@ -23,8 +23,12 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+
+	"github.com/abcxyz/pkg/pointer"
 )
 
 func TestGRPCStreamingInterceptor(t *testing.T) {
@@ -59,7 +63,8 @@ func TestGRPCStreamingInterceptor(t *testing.T) {
 			headers: map[string]string{
 				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1",
 			},
-			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/105445aa7843bc8bf206b12000100000",
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/105445aa7843bc8bf206b12000100000 " +
+				"logging.googleapis.com/spanId=0000000000000001 logging.googleapis.com/trace_sampled=true",
 		},
 	}
 
@@ -138,6 +143,43 @@ func TestGRPCUnaryInterceptor(t *testing.T) {
 			headers: map[string]string{
 				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1",
 			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/105445aa7843bc8bf206b12000100000 " +
+				"logging.googleapis.com/spanId=0000000000000001 logging.googleapis.com/trace_sampled=true",
+		},
+		{
+			name: "with_w3c_header",
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"tracestate":  "congo=t61rcWkgMzE",
+			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736 " +
+				"logging.googleapis.com/spanId=00f067aa0ba902b7 logging.googleapis.com/trace_sampled=true",
+		},
+		{
+			name: "with_b3_header",
+			headers: map[string]string{
+				"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0",
+			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/80f198ee56343ba864fe8b2a57d3eff7 " +
+				"logging.googleapis.com/spanId=e457b5a2e4d86bd1 logging.googleapis.com/trace_sampled=false",
+		},
+		{
+			name: "w3c_takes_precedence",
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1",
+				"X-B3-TraceId":          "80f198ee56343ba864fe8b2a57d3eff7",
+				"X-B3-SpanId":           "e457b5a2e4d86bd1",
+				"traceparent":           "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736 " +
+				"logging.googleapis.com/spanId=00f067aa0ba902b7 logging.googleapis.com/trace_sampled=false",
+		},
+		{
+			name: "invalid_w3c_falls_back",
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000",
+				"traceparent":           "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			},
 			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/105445aa7843bc8bf206b12000100000",
 		},
 	}
@@ -210,6 +252,43 @@ func TestHTTPInterceptor(t *testing.T) {
 			headers: map[string]string{
 				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1",
 			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/105445aa7843bc8bf206b12000100000 " +
+				"logging.googleapis.com/spanId=0000000000000001 logging.googleapis.com/trace_sampled=true",
+		},
+		{
+			name: "with_w3c_header",
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"tracestate":  "congo=t61rcWkgMzE",
+			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736 " +
+				"logging.googleapis.com/spanId=00f067aa0ba902b7 logging.googleapis.com/trace_sampled=true",
+		},
+		{
+			name: "with_b3_header",
+			headers: map[string]string{
+				"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0",
+			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/80f198ee56343ba864fe8b2a57d3eff7 " +
+				"logging.googleapis.com/spanId=e457b5a2e4d86bd1 logging.googleapis.com/trace_sampled=false",
+		},
+		{
+			name: "w3c_takes_precedence",
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1",
+				"X-B3-TraceId":          "80f198ee56343ba864fe8b2a57d3eff7",
+				"X-B3-SpanId":           "e457b5a2e4d86bd1",
+				"traceparent":           "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736 " +
+				"logging.googleapis.com/spanId=00f067aa0ba902b7 logging.googleapis.com/trace_sampled=false",
+		},
+		{
+			name: "invalid_w3c_falls_back",
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000",
+				"traceparent":           "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			},
 			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/105445aa7843bc8bf206b12000100000",
 		},
 	}
@@ -246,6 +325,201 @@ func TestHTTPInterceptor(t *testing.T) {
 	}
 }
 
+func TestGRPCStreamServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		exp     string
+	}{
+		{
+			name:    "no_headers",
+			headers: nil,
+			exp:     "level=INFO msg=test",
+		},
+		{
+			name: "with_trace_header",
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1",
+			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/105445aa7843bc8bf206b12000100000 " +
+				"logging.googleapis.com/spanId=0000000000000001 logging.googleapis.com/trace_sampled=true",
+		},
+		{
+			name: "with_w3c_header",
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+			exp: "level=INFO msg=test logging.googleapis.com/trace=projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736 " +
+				"logging.googleapis.com/spanId=00f067aa0ba902b7 logging.googleapis.com/trace_sampled=true",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			originalLogger, buf := testLogger(t)
+			ctx := WithLogger(t.Context(), originalLogger)
+
+			// The server sets the incoming metadata on the stream context.
+			ctx = metadata.NewIncomingContext(ctx, metadata.New(tc.headers))
+			stream := &testServerStream{ctx: ctx}
+
+			streamInfo := &grpc.StreamServerInfo{
+				FullMethod: "TestServer.Streamer",
+			}
+			streamHandler := func(srv any, stream grpc.ServerStream) error {
+				ctx := stream.Context()
+				logger := FromContext(ctx)
+				logger.InfoContext(ctx, "test")
+				return nil
+			}
+
+			interceptor := GRPCStreamServerInterceptor(originalLogger, "my-project")
+			if err := interceptor(nil, stream, streamInfo, streamHandler); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := strings.TrimSpace(buf.String()), tc.exp; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			if got, want := FromContext(stream.Context()), originalLogger; got != want {
+				t.Errorf("expected exact logger on context (%#v vs %#v)", got, want)
+			}
+		})
+	}
+}
+
+func TestGRPCClientInterceptors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		tc       *TraceContext
+		outgoing map[string]string
+		exp      metadata.MD
+	}{
+		{
+			name: "no_trace_context",
+			exp:  nil,
+		},
+		{
+			name: "w3c",
+			tc: &TraceContext{
+				TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:     "00f067aa0ba902b7",
+				Sampled:    pointer.To(true),
+				TraceState: "congo=t61rcWkgMzE",
+			},
+			exp: metadata.MD{
+				"traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+				"tracestate":  []string{"congo=t61rcWkgMzE"},
+			},
+		},
+		{
+			name: "google",
+			tc: &TraceContext{
+				TraceID: "105445aa",
+				SpanID:  "00000000000000ff",
+				Sampled: pointer.To(true),
+			},
+			exp: metadata.MD{
+				"x-cloud-trace-context": []string{"105445aa/255;o=1"},
+			},
+		},
+		{
+			name: "existing_header",
+			tc: &TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+			},
+			outgoing: map[string]string{
+				"traceparent": "00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-00",
+			},
+			exp: metadata.MD{
+				"traceparent": []string{"00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-00"},
+			},
+		},
+		{
+			name: "existing_other_format",
+			tc: &TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+			},
+			outgoing: map[string]string{
+				"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1",
+			},
+			exp: metadata.MD{
+				"b3": []string{"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1"},
+			},
+		},
+		{
+			name: "existing_tracestate",
+			tc: &TraceContext{
+				TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:     "00f067aa0ba902b7",
+				TraceState: "congo=t61rcWkgMzE",
+			},
+			outgoing: map[string]string{
+				"tracestate": "rojo=00f067aa0ba902b7",
+			},
+			exp: metadata.MD{
+				"tracestate": []string{"rojo=00f067aa0ba902b7"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			if tc.tc != nil {
+				ctx = WithTraceContext(ctx, tc.tc)
+			}
+			if tc.outgoing != nil {
+				ctx = metadata.NewOutgoingContext(ctx, metadata.New(tc.outgoing))
+			}
+
+			var gotUnary, gotStream metadata.MD
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				gotUnary, _ = metadata.FromOutgoingContext(ctx)
+				return nil
+			}
+			streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				gotStream, _ = metadata.FromOutgoingContext(ctx)
+				return nil, nil //nolint:nilnil // Test-only
+			}
+
+			if err := GRPCUnaryClientInterceptor()(ctx, "method", nil, nil, nil, invoker); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := GRPCStreamClientInterceptor()(ctx, &grpc.StreamDesc{}, nil, "method", streamer); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, got := range []metadata.MD{gotUnary, gotStream} {
+				if diff := cmp.Diff(tc.exp, got, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("outgoing metadata (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+// testServerStream is a [grpc.ServerStream] which only implements Context.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx // Test-only
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
 // testLogger creates a logger suitable for testing that writes log messages to
 // a buffer. It returns the logger and a pointer to the buffer.
 func testLogger(tb testing.TB) (*slog.Logger, *bytes.Buffer) {
@@ -0,0 +1,304 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/abcxyz/pkg/pointer"
+)
+
+// traceContextKey points to the value in the context where the trace context
+// of the incoming request is stored.
+const traceContextKey = contextKey("traceContext")
+
+var (
+	// w3cTraceparentHeader and w3cTracestateHeader are the headers defined by
+	// the W3C Trace Context specification.
+	w3cTraceparentHeader = "traceparent"
+	w3cTracestateHeader  = "tracestate"
+
+	// b3Header is the single header defined by the B3 propagation format.
+	b3Header = "b3"
+
+	// b3TraceIDHeader, b3SpanIDHeader, b3SampledHeader, and b3FlagsHeader are
+	// the multiple headers defined by the B3 propagation format.
+	b3TraceIDHeader = "X-B3-TraceId"
+	b3SpanIDHeader  = "X-B3-SpanId"
+	b3SampledHeader = "X-B3-Sampled"
+	b3FlagsHeader   = "X-B3-Flags"
+)
+
+// TraceContext is the trace context propagated with a request.
+type TraceContext struct {
+	// TraceID is the 32-character lowercase hex trace ID.
+	TraceID string
+
+	// SpanID is the 16-character lowercase hex ID of the caller's span, if
+	// known.
+	SpanID string
+
+	// Sampled indicates whether the caller sampled the trace. It is nil if the
+	// caller did not make a sampling decision.
+	Sampled *bool
+
+	// TraceState is the vendor-specific W3C tracestate, if any.
+	TraceState string
+}
+
+// WithTraceContext creates a new context with the provided trace context
+// attached. The interceptors attach the trace context of incoming requests, and
+// the client interceptors propagate it to outgoing requests.
+func WithTraceContext(ctx context.Context, tc *TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey, tc)
+}
+
+// TraceContextFromContext returns the trace context stored in the context, or
+// nil if there is none.
+func TraceContextFromContext(ctx context.Context) *TraceContext {
+	if tc, ok := ctx.Value(traceContextKey).(*TraceContext); ok {
+		return tc
+	}
+	return nil
+}
+
+// traceHeaders returns the headers which propagate the trace context to
+// outgoing requests. The W3C traceparent and tracestate headers are used if the
+// trace context is valid in that format; otherwise the Google Cloud
+// X-Cloud-Trace-Context header is used.
+func (tc *TraceContext) traceHeaders() map[string]string {
+	if tc == nil || tc.TraceID == "" {
+		return nil
+	}
+
+	if isHex(tc.TraceID, 32) && isHex(tc.SpanID, 16) {
+		var flags byte
+		if pointer.Deref(tc.Sampled) {
+			flags |= 0x01
+		}
+
+		headers := map[string]string{
+			w3cTraceparentHeader: fmt.Sprintf("00-%s-%s-%02x", tc.TraceID, tc.SpanID, flags),
+		}
+		if tc.TraceState != "" {
+			headers[w3cTracestateHeader] = tc.TraceState
+		}
+		return headers
+	}
+
+	header := tc.TraceID
+	if id, err := strconv.ParseUint(tc.SpanID, 16, 64); err == nil && id != 0 {
+		header += "/" + strconv.FormatUint(id, 10)
+		if pointer.Deref(tc.Sampled) {
+			header += ";o=1"
+		}
+	}
+	return map[string]string{
+		googleCloudTraceHeader: header,
+	}
+}
+
+// traceHeaderKeys returns the names of all headers which carry a trace context
+// in the supported propagation formats.
+func traceHeaderKeys() []string {
+	return []string{
+		w3cTraceparentHeader,
+		w3cTracestateHeader,
+		b3Header,
+		b3TraceIDHeader,
+		b3SpanIDHeader,
+		b3SampledHeader,
+		b3FlagsHeader,
+		googleCloudTraceHeader,
+	}
+}
+
+// TraceExtractor extracts the trace context from the headers of an incoming
+// request. The get function returns the first value of the header with the
+// given case-insensitive name, or the empty string if the header is not
+// present. It returns nil if the headers do not contain a valid trace context.
+type TraceExtractor func(get func(key string) string) *TraceContext
+
+// DefaultTraceExtractors returns the trace extractors used by the interceptors
+// by default, in order of precedence: [W3CTraceExtractor], [B3TraceExtractor],
+// and [GoogleCloudTraceExtractor].
+func DefaultTraceExtractors() []TraceExtractor {
+	return []TraceExtractor{
+		W3CTraceExtractor,
+		B3TraceExtractor,
+		GoogleCloudTraceExtractor,
+	}
+}
+
+// W3CTraceExtractor extracts the trace context from the W3C traceparent and
+// tracestate headers, as used by OpenTelemetry.
+//
+// See: https://www.w3.org/TR/trace-context/
+func W3CTraceExtractor(get func(key string) string) *TraceContext {
+	header := strings.TrimSpace(get(w3cTraceparentHeader))
+	if header == "" {
+		return nil
+	}
+
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 {
+		return nil
+	}
+
+	// Version "ff" is invalid, and version "00" has exactly four fields. Future
+	// versions may append fields, which are ignored.
+	version := parts[0]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return nil
+	}
+
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || isZero(traceID) ||
+		!isHex(spanID, 16) || isZero(spanID) ||
+		!isHex(flags, 2) {
+		return nil
+	}
+
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return nil
+	}
+
+	return &TraceContext{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		Sampled:    pointer.To(f&0x01 == 0x01),
+		TraceState: strings.TrimSpace(get(w3cTracestateHeader)),
+	}
+}
+
+// B3TraceExtractor extracts the trace context from the B3 single header, or
+// from the B3 multiple headers if the single header is not present. 64-bit
+// trace IDs are left-padded with zeros.
+//
+// See: https://github.com/openzipkin/b3-propagation
+func B3TraceExtractor(get func(key string) string) *TraceContext {
+	if header := strings.TrimSpace(get(b3Header)); header != "" {
+		// {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}, where the last two
+		// fields are optional. A header which only contains the sampling state has
+		// no trace context.
+		parts := strings.Split(header, "-")
+		if len(parts) < 2 || len(parts) > 4 {
+			return nil
+		}
+
+		var sampled string
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+		return newB3TraceContext(parts[0], parts[1], sampled, "")
+	}
+
+	return newB3TraceContext(
+		strings.TrimSpace(get(b3TraceIDHeader)),
+		strings.TrimSpace(get(b3SpanIDHeader)),
+		strings.TrimSpace(get(b3SampledHeader)),
+		strings.TrimSpace(get(b3FlagsHeader)))
+}
+
+// newB3TraceContext builds the trace context from the B3 fields, returning nil
+// if they are invalid.
+func newB3TraceContext(traceID, spanID, sampled, flags string) *TraceContext {
+	traceID = strings.ToLower(traceID)
+	spanID = strings.ToLower(spanID)
+
+	if isHex(traceID, 16) {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if !isHex(traceID, 32) || isZero(traceID) {
+		return nil
+	}
+	if !isHex(spanID, 16) || isZero(spanID) {
+		return nil
+	}
+
+	tc := &TraceContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}
+	if sampled != "" || flags != "" {
+		tc.Sampled = pointer.To(sampled == "1" || sampled == "d" || strings.EqualFold(sampled, "true") || flags == "1")
+	}
+	return tc
+}
+
+// GoogleCloudTraceExtractor extracts the trace context from the Google Cloud
+// X-Cloud-Trace-Context header. The decimal span ID is converted to hex.
+//
+// See: https://cloud.google.com/trace/docs/trace-context#legacy-http-header
+func GoogleCloudTraceExtractor(get func(key string) string) *TraceContext {
+	header := strings.TrimSpace(get(googleCloudTraceHeader))
+	if header == "" {
+		return nil
+	}
+
+	// TRACE_ID/SPAN_ID;o=OPTIONS, where everything after the trace ID is
+	// optional.
+	traceID, rest, _ := strings.Cut(header, "/")
+	if traceID == "" {
+		return nil
+	}
+	tc := &TraceContext{
+		TraceID: traceID,
+	}
+
+	spanID, options, _ := strings.Cut(rest, ";")
+	if id, err := strconv.ParseUint(spanID, 10, 64); err == nil && id != 0 {
+		tc.SpanID = fmt.Sprintf("%016x", id)
+	}
+	if v, ok := strings.CutPrefix(options, "o="); ok {
+		tc.Sampled = pointer.To(v == "1")
+	}
+
+	return tc
+}
+
+// extractTraceContext returns the trace context from the first extractor which
+// finds one, or nil if none do.
+func extractTraceContext(extractors []TraceExtractor, get func(key string) string) *TraceContext {
+	for _, extractor := range extractors {
+		if tc := extractor(get); tc != nil {
+			return tc
+		}
+	}
+	return nil
+}
+
+// isHex returns true if s is a lowercase hex string of the given length.
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isZero returns true if s only contains zeros.
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
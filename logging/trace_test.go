@@ -0,0 +1,227 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/abcxyz/pkg/pointer"
+)
+
+func TestTraceExtractors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		extractor TraceExtractor
+		headers   map[string]string
+		exp       *TraceContext
+	}{
+		{
+			name:      "w3c",
+			extractor: W3CTraceExtractor,
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"tracestate":  "congo=t61rcWkgMzE",
+			},
+			exp: &TraceContext{
+				TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:     "00f067aa0ba902b7",
+				Sampled:    pointer.To(true),
+				TraceState: "congo=t61rcWkgMzE",
+			},
+		},
+		{
+			name:      "w3c_future_version",
+			extractor: W3CTraceExtractor,
+			headers: map[string]string{
+				"traceparent": "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-02-extra",
+			},
+			exp: &TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: pointer.To(false),
+			},
+		},
+		{
+			name:      "w3c_invalid_version",
+			extractor: W3CTraceExtractor,
+			headers: map[string]string{
+				"traceparent": "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+		},
+		{
+			name:      "w3c_extra_fields",
+			extractor: W3CTraceExtractor,
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			},
+		},
+		{
+			name:      "w3c_uppercase",
+			extractor: W3CTraceExtractor,
+			headers: map[string]string{
+				"traceparent": "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01",
+			},
+		},
+		{
+			name:      "w3c_zero_span",
+			extractor: W3CTraceExtractor,
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			},
+		},
+		{
+			name:      "b3_single",
+			extractor: B3TraceExtractor,
+			headers: map[string]string{
+				"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90",
+			},
+			exp: &TraceContext{
+				TraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+				SpanID:  "e457b5a2e4d86bd1",
+				Sampled: pointer.To(true),
+			},
+		},
+		{
+			name:      "b3_single_64bit",
+			extractor: B3TraceExtractor,
+			headers: map[string]string{
+				"b3": "a3ce929d0e0e4736-e457b5a2e4d86bd1",
+			},
+			exp: &TraceContext{
+				TraceID: "0000000000000000a3ce929d0e0e4736",
+				SpanID:  "e457b5a2e4d86bd1",
+			},
+		},
+		{
+			name:      "b3_single_sampling_only",
+			extractor: B3TraceExtractor,
+			headers: map[string]string{
+				"b3": "0",
+			},
+		},
+		{
+			name:      "b3_multi",
+			extractor: B3TraceExtractor,
+			headers: map[string]string{
+				"X-B3-TraceId": "80F198EE56343BA864FE8B2A57D3EFF7",
+				"X-B3-SpanId":  "e457b5a2e4d86bd1",
+				"X-B3-Flags":   "1",
+			},
+			exp: &TraceContext{
+				TraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+				SpanID:  "e457b5a2e4d86bd1",
+				Sampled: pointer.To(true),
+			},
+		},
+		{
+			name:      "b3_multi_missing_span",
+			extractor: B3TraceExtractor,
+			headers: map[string]string{
+				"X-B3-TraceId": "80f198ee56343ba864fe8b2a57d3eff7",
+			},
+		},
+		{
+			name:      "google",
+			extractor: GoogleCloudTraceExtractor,
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/255;o=1",
+			},
+			exp: &TraceContext{
+				TraceID: "105445aa7843bc8bf206b12000100000",
+				SpanID:  "00000000000000ff",
+				Sampled: pointer.To(true),
+			},
+		},
+		{
+			name:      "google_not_sampled",
+			extractor: GoogleCloudTraceExtractor,
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/255;o=0",
+			},
+			exp: &TraceContext{
+				TraceID: "105445aa7843bc8bf206b12000100000",
+				SpanID:  "00000000000000ff",
+				Sampled: pointer.To(false),
+			},
+		},
+		{
+			name:      "google_trace_only",
+			extractor: GoogleCloudTraceExtractor,
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000",
+			},
+			exp: &TraceContext{
+				TraceID: "105445aa7843bc8bf206b12000100000",
+			},
+		},
+		{
+			name:      "google_missing_trace",
+			extractor: GoogleCloudTraceExtractor,
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "/1;o=1",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			header := make(http.Header)
+			for k, v := range tc.headers {
+				header.Set(k, v)
+			}
+
+			if diff := cmp.Diff(tc.exp, tc.extractor(header.Get)); diff != "" {
+				t.Errorf("trace context (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithTraceExtractors(t *testing.T) {
+	t.Parallel()
+
+	originalLogger, buf := testLogger(t)
+
+	custom := func(get func(key string) string) *TraceContext {
+		if v := get("X-Request-Trace"); v != "" {
+			return &TraceContext{TraceID: v}
+		}
+		return nil
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Trace", "abc123")
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r = r.Clone(WithLogger(t.Context(), originalLogger))
+
+	interceptor := HTTPInterceptor(originalLogger, "my-project", WithTraceExtractors(custom))
+	interceptor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).InfoContext(r.Context(), "test")
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	if got, want := strings.TrimSpace(buf.String()), "level=INFO msg=test "+
+		"logging.googleapis.com/trace=projects/my-project/traces/abc123"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}